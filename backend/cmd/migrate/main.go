@@ -10,6 +10,7 @@ import (
 
 	"blogapp/config"
 	"blogapp/database"
+	"blogapp/internal/fieldcrypt"
 	"blogapp/models"
 )
 
@@ -20,6 +21,9 @@ func main() {
 
 	// 設定読み込み
 	cfg := config.LoadConfig()
+	if err := fieldcrypt.SetKey(cfg.DataEncryptionKey); err != nil {
+		log.Fatalf("Failed to set data encryption key (DATA_ENCRYPTION_KEY is required): %v", err)
+	}
 
 	// DB接続
 	db, err := database.Connect(cfg.DatabaseURL)
@@ -64,8 +68,20 @@ func migrateDown(db *gorm.DB) {
 	
 	// テーブルを削除（逆順）
 	tables := []interface{}{
+		"post_media",
+		&models.ScanLog{},
+		&models.TusUpload{},
+		&models.MediaVariant{},
+		&models.Media{},
+		&models.Redirect{},
+		&models.SlugHistory{},
+		&models.PostRevision{},
+		&models.NotificationPreference{},
+		&models.SpamToken{},
+		&models.CommentNote{},
 		&models.Comment{},
 		&models.Post{},
+		&models.TagSynonym{},
 		&models.Tag{},
 		&models.Category{},
 		&models.User{},
@@ -111,7 +127,6 @@ func checkStatus(db *gorm.DB) {
 		{"posts", &models.Post{}},
 		{"tags", &models.Tag{}},
 		{"comments", &models.Comment{}},
-		{"post_categories", &models.Post{}},
 		{"post_tags", &models.Post{}},
 	}
 
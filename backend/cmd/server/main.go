@@ -5,10 +5,18 @@ import (
 	"blogapp/config"
 	"blogapp/database"
 	"blogapp/handlers"
+	"blogapp/internal/fieldcrypt"
+	"blogapp/internal/imagecache"
+	"blogapp/internal/jobs"
+	"blogapp/internal/scan"
+	"blogapp/internal/storage"
+	"blogapp/middleware"
 	"blogapp/routes"
+	"context"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -24,6 +32,15 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// コメントのメールアドレス・IP の暗号化鍵
+	if err := fieldcrypt.SetKey(cfg.DataEncryptionKey); err != nil {
+		log.Fatalf("Failed to set data encryption key (DATA_ENCRYPTION_KEY is required): %v", err)
+	}
+	// 公開していない投稿のメディアの URL の署名鍵（推測できる値に戻さない）
+	if cfg.UploadURLSecret == "" {
+		log.Fatal("UPLOAD_URL_SECRET is required")
+	}
+
 	// Initialize database
 	db, err := database.Connect(cfg.DatabaseURL)
 	if err != nil {
@@ -44,6 +61,44 @@ func main() {
 		}
 	}
 
+	// アップロードしたファイルの保存先（local / s3）
+	store, err := storage.New(context.Background(), cfg.StorageOptions())
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	log.Printf("Upload storage: %s", cfg.StorageDriver)
+
+	imageCache, err := imagecache.New(cfg.ImageCacheDir, cfg.ImageCacheSize)
+	if err != nil {
+		log.Fatalf("Failed to initialize image cache: %v", err)
+	}
+
+	// アップロードしたファイルのマルウェア検査（CLAMD_ADDRESS を設定した場合のみ）
+	var scanner scan.Scanner
+	if cfg.ClamdAddress != "" {
+		clamd, err := scan.NewClamd(cfg.ClamdAddress, cfg.ClamdTimeout)
+		if err != nil {
+			log.Fatalf("Failed to initialize malware scanner: %v", err)
+		}
+		scanner = clamd
+		log.Printf("Malware scanner: clamd (%s)", cfg.ClamdAddress)
+	}
+
+	// バックグラウンドジョブ（予約投稿の公開など）
+	// 複数レプリカで起動してもアドバイザリロックで1台だけが実行する
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jobs.Start(ctx, db,
+		jobs.PublishScheduledPosts(cfg.SchedulerInterval),
+		jobs.PrunePostRevisions(cfg.RevisionPruneInterval, database.RevisionRetention{
+			Keep:   cfg.RevisionKeep,
+			MaxAge: time.Duration(cfg.RevisionMaxAgeDays) * 24 * time.Hour,
+		}),
+		jobs.AnonymizeCommentIPs(time.Hour, time.Duration(cfg.CommentIPRetentionDays)*24*time.Hour),
+		jobs.CleanupOrphanMedia(cfg.MediaCleanupInterval, cfg.MediaOrphanAge, store, imageCache),
+		jobs.CleanupTusUploads(cfg.TusCleanupInterval, cfg.TusUploadDir),
+	)
+
 	// Ginのセットアップ
 	router := gin.Default()
 
@@ -58,14 +113,19 @@ func main() {
 	// CORS設定
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     allowedOrigins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH", "HEAD"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept", "Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata", "Upload-Defer-Length"},
+		ExposeHeaders:    []string{"Content-Length", "Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size", "Upload-Offset", "Upload-Length", "Upload-Expires", "Upload-Media-Id"},
 		AllowCredentials: true,
 		MaxAge:           12 * 3600, // 12時間
 	}))
 
 	// Setup routes
+	middleware.SetJWTSecret(cfg.JWTSecret)
+	handlers.SetConfig(cfg)
+	handlers.SetStorage(store)
+	handlers.SetImageCache(imageCache)
+	handlers.SetScanner(scanner)
 	routes.SetupRoutes(router)
 
 	// Start server
@@ -1,8 +1,17 @@
 package config
 
 import (
+	"blogapp/internal/imageproc"
+	"blogapp/internal/storage"
+	"blogapp/internal/upload"
 	"fmt"
+	"log"
 	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -16,6 +25,95 @@ type Config struct {
 	JWTSecret      string
 	Environment    string
 	AllowedOrigins string
+
+	// バックグラウンドジョブ
+	SchedulerInterval time.Duration // 予約投稿の公開チェック間隔（0 で無効）
+
+	// 投稿リビジョンの保持ポリシー
+	RevisionKeep          int           // 投稿ごとに残す最大件数（0 で無制限）
+	RevisionMaxAgeDays    int           // この日数より古いものを削除（0 で無制限、最新1件は常に残す）
+	RevisionPruneInterval time.Duration // 古いリビジョンの削除の間隔（0 で無効）
+
+	// コメント
+	CommentMaxDepth    int  // 返信の最大の深さ（トップレベルのコメントは 0、0 なら返信不可）
+	CommentAutoApprove bool // 同じメールアドレスの承認済みコメントがあれば新しいコメントも自動承認
+
+	// コメントのスパム判定
+	SpamThreshold        float64       // ルールのスコアの合計がこれ以上ならスパム（0 で判定しない）
+	SpamBlocklist        []string      // 禁止語（カンマ区切り）
+	SpamBayesMinTrained  int           // スパム・非スパムをそれぞれこの件数学習するまでベイズ判定を使わない
+	CommentMaxLinks      int           // これを超えるリンクにスコアを付ける
+	CommentMinSubmitTime time.Duration // フォーム表示からこれより早い送信にスコアを付ける
+	CommentTokenMaxAge   time.Duration // フォームトークンの有効期間
+	CommentTokenSecret   string        // フォームトークンの署名鍵（省略時は JWT_SECRET）
+
+	// メール通知
+	SiteURL            string // メール内のリンク先（フロントエンド）
+	APIBaseURL         string // 配信停止リンクの送信先（この API サーバー）
+	NotificationSecret string // 配信停止トークンの署名鍵（省略時は JWT_SECRET）
+
+	// 個人情報の保護
+	DataEncryptionKey      string // コメントのメールアドレス・IP の暗号化鍵（必須）
+	CommentIPRetentionDays int    // この日数を過ぎたコメントの IP・ユーザーエージェントを消す（0 で消さない）
+
+	// アップロードしたファイルの保存先
+	StorageDriver string // local / s3
+	UploadDir     string // local の保存先ディレクトリ
+	UploadBaseURL string // 公開 URL の先頭（省略時は local なら /uploads、s3 ならバケットの URL）
+	S3Endpoint    string
+	S3Region      string
+	S3Bucket      string
+	S3AccessKey   string
+	S3SecretKey   string
+	S3UseSSL      bool
+	S3PathStyle   bool // MinIO などバケット名をパスに入れる場合は true
+
+	// アップロードできるファイルの種類と大きさ（ロールごと、"" はその他のロール）
+	UploadPolicies map[string]upload.Policy
+
+	// ユーザーごとのアップロードの容量の上限（ロールごとの既定値、"" はその他のロール、0 で無制限）
+	StorageQuotas map[string]int64
+
+	// 再開可能なアップロード（tus）
+	TusUploadDir       string        // 受け取り途中のファイルの置き場所
+	TusMaxSize         int64         // 1ファイルの上限（ロールの UPLOAD_MAX_SIZE とのうち小さいほうを使う）
+	TusUploadExpiry    time.Duration // 最後にデータを受け取ってからこの期間が過ぎたら削除
+	TusCleanupInterval time.Duration // 期限切れのアップロードの削除の間隔（0 で無効）
+
+	// アップロードしたファイルの配信（/uploads）
+	UploadURLSecret    string        // 公開していない投稿のメディアの署名付き URL の署名鍵（必須）
+	UploadSignedURLTTL time.Duration // 署名付き URL の有効期間の既定値
+
+	// マルウェア検査（ClamdAddress が空なら検査しない）
+	ClamdAddress   string        // "tcp://127.0.0.1:3310" や "unix:///var/run/clamav/clamd.ctl"
+	ClamdTimeout   time.Duration // 1ファイルの検査の時間の上限
+	ScanQuarantine bool          // 検出したファイルを QuarantineDir に隔離する（false なら破棄）
+	ScanFailOpen   bool          // 検査できなかったファイルを受け付ける（false なら 503 を返す）
+	QuarantineDir  string        // 隔離したファイルの置き場所（公開しないディレクトリ）
+
+	// 画像の縮小（アップロード時に作る）
+	ImageVariants  []imageproc.Variant // 名前:幅 または 名前:幅x高さ（切り抜き）のカンマ区切り
+	ImageQuality   int
+	ImageMaxPixels int64 // これより画素数の多い画像は受け付けない
+
+	// 画像の変換（/img/{署名}/{パラメーター}/{キー}）
+	ImageURLSecret     string // URL の署名鍵（省略時は JWT_SECRET）
+	ImageSizes         []int  // 指定できる幅・高さ（これ以外の大きさには署名しない）
+	ImageMaxSourceSize int64  // これより大きいファイルは変換しない
+	ImageConcurrency   int    // 同時に変換する数
+	ImageCacheDir      string // 変換した画像のキャッシュ
+	ImageCacheSize     int64  // キャッシュの合計の上限（超えたら使われていないものから消す）
+
+	// フィード（RSS / Atom / JSON Feed）
+	FeedTitle       string
+	FeedDescription string
+	FeedLanguage    string
+	FeedItems       int  // 1つのフィードに含める投稿の数
+	FeedFullContent bool // 本文をすべて含める（false なら抜粋のみ、FEED_CONTENT=excerpt）
+
+	// メディアライブラリ
+	MediaCleanupInterval time.Duration // 参照されていないメディアの削除の間隔（0 で無効）
+	MediaOrphanAge       time.Duration // アップロードからこの期間が過ぎても参照されていなければ削除
 }
 
 func Load() *Config {
@@ -42,6 +140,78 @@ func Load() *Config {
 		JWTSecret:      getEnv("JWT_SECRET", "your-secret-key-change-this"),
 		Environment:    getEnv("ENVIRONMENT", "development"),
 		AllowedOrigins: getEnv("ALLOWED_ORIGINS", "*"),
+
+		SchedulerInterval: getEnvDuration("SCHEDULER_INTERVAL", time.Minute),
+
+		RevisionKeep:          getEnvInt("REVISION_KEEP", 50),
+		RevisionMaxAgeDays:    getEnvInt("REVISION_MAX_AGE_DAYS", 0),
+		RevisionPruneInterval: getEnvDuration("REVISION_PRUNE_INTERVAL", time.Hour),
+
+		CommentMaxDepth:    getEnvInt("COMMENT_MAX_DEPTH", 3),
+		CommentAutoApprove: getEnvBool("COMMENT_AUTO_APPROVE", false),
+
+		SpamThreshold:        getEnvFloat("SPAM_THRESHOLD", 5),
+		SpamBlocklist:        getEnvList("SPAM_BLOCKLIST"),
+		SpamBayesMinTrained:  getEnvInt("SPAM_BAYES_MIN_TRAINED", 10),
+		CommentMaxLinks:      getEnvInt("COMMENT_MAX_LINKS", 2),
+		CommentMinSubmitTime: getEnvDuration("COMMENT_MIN_SUBMIT_TIME", 3*time.Second),
+		CommentTokenMaxAge:   getEnvDuration("COMMENT_TOKEN_MAX_AGE", 24*time.Hour),
+		CommentTokenSecret:   getEnv("COMMENT_TOKEN_SECRET", getEnv("JWT_SECRET", "your-secret-key-change-this")),
+
+		SiteURL:            strings.TrimRight(getEnv("SITE_URL", "http://localhost:3000"), "/"),
+		APIBaseURL:         strings.TrimRight(getEnv("API_BASE_URL", "http://localhost:8080"), "/"),
+		NotificationSecret: getEnv("NOTIFICATION_SECRET", getEnv("JWT_SECRET", "your-secret-key-change-this")),
+
+		DataEncryptionKey:      os.Getenv("DATA_ENCRYPTION_KEY"),
+		CommentIPRetentionDays: getEnvInt("COMMENT_IP_RETENTION_DAYS", 30),
+
+		StorageDriver: getEnv("STORAGE_DRIVER", "local"),
+		UploadDir:     getEnv("UPLOAD_DIR", "./uploads"),
+		UploadBaseURL: getEnv("UPLOAD_BASE_URL", ""),
+		S3Endpoint:    getEnv("S3_ENDPOINT", ""),
+		S3Region:      getEnv("S3_REGION", ""),
+		S3Bucket:      getEnv("S3_BUCKET", ""),
+		S3AccessKey:   getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:   getEnv("S3_SECRET_KEY", ""),
+		S3UseSSL:      getEnvBool("S3_USE_SSL", true),
+		S3PathStyle:   getEnvBool("S3_PATH_STYLE", false),
+
+		UploadPolicies: loadUploadPolicies(),
+		StorageQuotas:  loadStorageQuotas(),
+
+		TusUploadDir:       getEnv("TUS_UPLOAD_DIR", "./tmp/tus"),
+		TusMaxSize:         getEnvSize("TUS_MAX_SIZE", 1<<30),
+		TusUploadExpiry:    getEnvDuration("TUS_UPLOAD_EXPIRY", 24*time.Hour),
+		TusCleanupInterval: getEnvDuration("TUS_CLEANUP_INTERVAL", time.Hour),
+
+		UploadURLSecret:    getEnv("UPLOAD_URL_SECRET", ""),
+		UploadSignedURLTTL: getEnvDuration("UPLOAD_SIGNED_URL_TTL", time.Hour),
+
+		ClamdAddress:   getEnv("CLAMD_ADDRESS", ""),
+		ClamdTimeout:   getEnvDuration("CLAMD_TIMEOUT", 30*time.Second),
+		ScanQuarantine: getEnvBool("SCAN_QUARANTINE", true),
+		ScanFailOpen:   getEnvBool("SCAN_FAIL_OPEN", false),
+		QuarantineDir:  getEnv("QUARANTINE_DIR", "./quarantine"),
+
+		ImageVariants:  loadImageVariants(),
+		ImageQuality:   getEnvInt("IMAGE_QUALITY", 85),
+		ImageMaxPixels: int64(getEnvInt("IMAGE_MAX_PIXELS", 25_000_000)),
+
+		ImageURLSecret:     getEnv("IMAGE_URL_SECRET", getEnv("JWT_SECRET", "your-secret-key-change-this")),
+		ImageSizes:         loadImageSizes(),
+		ImageMaxSourceSize: getEnvSize("IMAGE_MAX_SOURCE_SIZE", 50<<20),
+		ImageConcurrency:   getEnvInt("IMAGE_CONCURRENCY", runtime.NumCPU()),
+		ImageCacheDir:      getEnv("IMAGE_CACHE_DIR", "./cache/images"),
+		ImageCacheSize:     getEnvSize("IMAGE_CACHE_SIZE", 1<<30),
+
+		FeedTitle:       getEnv("FEED_TITLE", "Blog"),
+		FeedDescription: getEnv("FEED_DESCRIPTION", ""),
+		FeedLanguage:    getEnv("FEED_LANGUAGE", "ja"),
+		FeedItems:       getEnvInt("FEED_ITEMS", 20),
+		FeedFullContent: strings.ToLower(getEnv("FEED_CONTENT", "full")) != "excerpt",
+
+		MediaCleanupInterval: getEnvDuration("MEDIA_CLEANUP_INTERVAL", 6*time.Hour),
+		MediaOrphanAge:       getEnvDuration("MEDIA_ORPHAN_AGE", 7*24*time.Hour),
 	}
 }
 
@@ -56,3 +226,241 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid integer for %s: %q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Warning: invalid number for %s: %q, using %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return f
+}
+
+// getEnvList カンマ区切りの値を空要素を除いて返す
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Warning: invalid boolean for %s: %q, using %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: invalid duration for %s: %q, using %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// StorageOptions - アップロードの保存先の設定
+func (c *Config) StorageOptions() storage.Options {
+	return storage.Options{
+		Driver:          c.StorageDriver,
+		BaseURL:         c.UploadBaseURL,
+		Dir:             c.UploadDir,
+		Endpoint:        c.S3Endpoint,
+		Region:          c.S3Region,
+		Bucket:          c.S3Bucket,
+		AccessKeyID:     c.S3AccessKey,
+		SecretAccessKey: c.S3SecretKey,
+		UseSSL:          c.S3UseSSL,
+		PathStyle:       c.S3PathStyle,
+	}
+}
+
+// UploadPolicy - ロールのアップロードの制限
+func (c *Config) UploadPolicy(role string) upload.Policy {
+	if policy, ok := c.UploadPolicies[role]; ok {
+		return policy
+	}
+	return c.UploadPolicies[""]
+}
+
+// loadUploadPolicies - UPLOAD_ALLOWED_TYPES / UPLOAD_MAX_SIZE と、ロールごとの上書き
+// （UPLOAD_ALLOWED_TYPES_ADMIN、UPLOAD_MAX_SIZE_AUTHOR など）を読み込む
+// SVG は検査したうえで管理者だけに既定で許可する
+func loadUploadPolicies() map[string]upload.Policy {
+	base := upload.Policy{
+		AllowedTypes: uploadTypes("UPLOAD_ALLOWED_TYPES", []string{
+			"image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf", "video/mp4", "video/webm",
+		}),
+		MaxSize: getEnvSize("UPLOAD_MAX_SIZE", 10<<20),
+	}
+
+	defaults := map[string]upload.Policy{
+		"admin":  {AllowedTypes: append(append([]string{}, base.AllowedTypes...), "image/svg+xml"), MaxSize: base.MaxSize},
+		"editor": base,
+		"author": base,
+	}
+	policies := map[string]upload.Policy{"": base}
+	for role, policy := range defaults {
+		suffix := "_" + strings.ToUpper(role)
+		policies[role] = upload.Policy{
+			AllowedTypes: uploadTypes("UPLOAD_ALLOWED_TYPES"+suffix, policy.AllowedTypes),
+			MaxSize:      getEnvSize("UPLOAD_MAX_SIZE"+suffix, policy.MaxSize),
+		}
+	}
+	return policies
+}
+
+// StorageQuota - ロールの容量の上限の既定値（0 は無制限）
+func (c *Config) StorageQuota(role string) int64 {
+	if quota, ok := c.StorageQuotas[role]; ok {
+		return quota
+	}
+	return c.StorageQuotas[""]
+}
+
+// loadStorageQuotas - STORAGE_QUOTA と、ロールごとの上書き（STORAGE_QUOTA_ADMIN など）を読み込む
+// 管理者は既定で無制限
+func loadStorageQuotas() map[string]int64 {
+	base := getEnvSize("STORAGE_QUOTA", 1<<30)
+	return map[string]int64{
+		"":       base,
+		"admin":  getEnvSize("STORAGE_QUOTA_ADMIN", 0),
+		"editor": getEnvSize("STORAGE_QUOTA_EDITOR", base),
+		"author": getEnvSize("STORAGE_QUOTA_AUTHOR", base),
+	}
+}
+
+// uploadTypes - 受け付けるファイルの種類の一覧（upload.Types にないものは無視する）
+func uploadTypes(key string, defaultValue []string) []string {
+	values := getEnvList(key)
+	if len(values) == 0 {
+		return defaultValue
+	}
+	var types []string
+	for _, value := range values {
+		if _, ok := upload.Types[value]; !ok {
+			log.Printf("Warning: unsupported upload type in %s: %q", key, value)
+			continue
+		}
+		types = append(types, value)
+	}
+	return types
+}
+
+// ImageOptions - アップロードした画像の処理の設定
+func (c *Config) ImageOptions() imageproc.Options {
+	return imageproc.Options{
+		Variants:  c.ImageVariants,
+		Quality:   c.ImageQuality,
+		MaxPixels: c.ImageMaxPixels,
+	}
+}
+
+// imageVariantName - 縮小画像の名前（保存先のキーに使う）
+var imageVariantName = regexp.MustCompile(`^[a-z0-9_]{1,50}$`)
+
+// loadImageVariants - IMAGE_VARIANTS（"thumbnail:150x150,medium:768,large:1600" の形式、"none" で作らない）
+func loadImageVariants() []imageproc.Variant {
+	values := getEnvList("IMAGE_VARIANTS")
+	if len(values) == 0 {
+		values = []string{"thumbnail:150x150", "medium:768", "large:1600"}
+	}
+	if len(values) == 1 && values[0] == "none" {
+		return nil
+	}
+
+	var variants []imageproc.Variant
+	seen := make(map[string]bool)
+	for _, value := range values {
+		name, size, _ := strings.Cut(value, ":")
+		width, height, cropped := strings.Cut(size, "x")
+		variant := imageproc.Variant{Name: strings.TrimSpace(name)}
+		var err error
+		if variant.Width, err = strconv.Atoi(strings.TrimSpace(width)); err == nil && cropped {
+			variant.Height, err = strconv.Atoi(strings.TrimSpace(height))
+		}
+		if err != nil || variant.Width <= 0 || (cropped && variant.Height <= 0) ||
+			!imageVariantName.MatchString(variant.Name) || seen[variant.Name] {
+			log.Printf("Warning: invalid image variant in IMAGE_VARIANTS: %q", value)
+			continue
+		}
+		seen[variant.Name] = true
+		variants = append(variants, variant)
+	}
+	return variants
+}
+
+// loadImageSizes - IMAGE_SIZES（画像の変換 URL で指定できる幅・高さのカンマ区切り）
+func loadImageSizes() []int {
+	values := getEnvList("IMAGE_SIZES")
+	if len(values) == 0 {
+		values = []string{"160", "320", "480", "640", "768", "960", "1280", "1600", "1920"}
+	}
+
+	var sizes []int
+	for _, value := range values {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 || size > 8192 {
+			log.Printf("Warning: invalid image size in IMAGE_SIZES: %q", value)
+			continue
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes
+}
+
+// getEnvSize - "10MB" や "512KB" のようなバイト数（単位なしはバイト）
+func getEnvSize(key string, defaultValue int64) int64 {
+	value := strings.ToUpper(strings.TrimSpace(os.Getenv(key)))
+	if value == "" {
+		return defaultValue
+	}
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		log.Printf("Warning: invalid size for %s: %q, using %d", key, os.Getenv(key), defaultValue)
+		return defaultValue
+	}
+	return n * multiplier
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"gorm.io/gorm"
+)
+
+// CategoryDescendantIDs - カテゴリー自身とそのすべての子孫のIDを返す
+func CategoryDescendantIDs(db *gorm.DB, id uint) ([]uint, error) {
+	var ids []uint
+	err := db.Raw(`
+		WITH RECURSIVE descendants AS (
+			SELECT id FROM categories WHERE id = ? AND deleted_at IS NULL
+			UNION
+			SELECT c.id FROM categories c
+			JOIN descendants d ON c.parent_id = d.id
+			WHERE c.deleted_at IS NULL
+		)
+		SELECT id FROM descendants`, id).Scan(&ids).Error
+	return ids, err
+}
+
+// CategoryAncestorIDs - カテゴリー自身とそのすべての祖先のIDを返す（自身 → ルートの順）
+func CategoryAncestorIDs(db *gorm.DB, id uint) ([]uint, error) {
+	var ids []uint
+	err := db.Raw(`
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, 0 AS depth FROM categories WHERE id = ?
+			UNION
+			SELECT c.id, c.parent_id, a.depth + 1 FROM categories c
+			JOIN ancestors a ON c.id = a.parent_id
+			WHERE a.depth < 100
+		)
+		SELECT id FROM ancestors ORDER BY depth`, id).Scan(&ids).Error
+	return ids, err
+}
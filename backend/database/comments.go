@@ -0,0 +1,61 @@
+package database
+
+import (
+	"blogapp/models"
+
+	"gorm.io/gorm"
+)
+
+// commentThreadRoots - visible のコメントとその祖先をたどり、スレッドの最上位のコメントを集める CTE
+const commentThreadRoots = `
+	WITH RECURSIVE chain AS (
+		(?)
+		UNION
+		SELECT c.id, c.parent_id, c.created_at FROM comments c
+		JOIN chain ON c.id = chain.parent_id
+	)`
+
+// CommentThreadRoots - 投稿のコメントのうち visible に一致するものを含むスレッドの、最上位のコメントの ID
+// （古い順に offset から limit 件）と、スレッドの総数を返す
+func CommentThreadRoots(db *gorm.DB, postID uint, visible func(*gorm.DB) *gorm.DB, offset, limit int) ([]uint, int64, error) {
+	visibleComments := func() *gorm.DB {
+		return db.Model(&models.Comment{}).
+			Select("id", "parent_id", "created_at").
+			Where("post_id = ?", postID).
+			Scopes(visible)
+	}
+
+	var total int64
+	if err := db.Raw(commentThreadRoots+`
+		SELECT COUNT(*) FROM chain WHERE parent_id IS NULL`, visibleComments()).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var ids []uint
+	err := db.Raw(commentThreadRoots+`
+		SELECT id FROM chain WHERE parent_id IS NULL
+		ORDER BY created_at ASC, id ASC
+		LIMIT ? OFFSET ?`, visibleComments(), limit, offset).Scan(&ids).Error
+	return ids, total, err
+}
+
+// CommentThreads - rootIDs のコメントとそのすべての返信（削除済みを含む）を古い順に返す
+func CommentThreads(db *gorm.DB, rootIDs []uint) ([]models.Comment, error) {
+	if len(rootIDs) == 0 {
+		return nil, nil
+	}
+
+	var comments []models.Comment
+	err := db.Unscoped().
+		Where("id IN (?)", db.Raw(`
+			WITH RECURSIVE thread AS (
+				SELECT id FROM comments WHERE id IN ?
+				UNION
+				SELECT c.id FROM comments c
+				JOIN thread t ON c.parent_id = t.id
+			)
+			SELECT id FROM thread`, rootIDs)).
+		Order("created_at ASC, id ASC").
+		Find(&comments).Error
+	return comments, err
+}
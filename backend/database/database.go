@@ -19,6 +19,8 @@ func Connect(databaseURL string) (*gorm.DB, error) {
 	
 	DB, err = gorm.Open(postgres.Open(databaseURL), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
+		// 一意制約違反などを gorm.ErrDuplicatedKey に変換
+		TranslateError: true,
 	})
 	
 	if err != nil {
@@ -58,13 +60,60 @@ func AutoMigrate(db *gorm.DB) error {
 		&models.User{},
 		&models.Category{},
 		&models.Tag{},
+		&models.TagSynonym{},
+		&models.Media{},
+		&models.MediaVariant{},
+		&models.TusUpload{},
+		&models.ScanLog{},
 		&models.Post{},
 		&models.Comment{},
+		&models.CommentNote{},
+		&models.SpamToken{},
+		&models.NotificationPreference{},
+		&models.PostRevision{},
+		&models.SlugHistory{},
+		&models.Redirect{},
 	)
 	
 	if err != nil {
 		return fmt.Errorf("migration failed: %w", err)
 	}
+
+	// role カラム追加前のユーザーのロールを is_admin から決める
+	if err := db.Exec(
+		"UPDATE users SET role = CASE WHEN is_admin THEN ? ELSE ? END WHERE role IS NULL OR role = ''",
+		models.RoleAdmin, models.RoleAuthor,
+	).Error; err != nil {
+		return fmt.Errorf("user role backfill failed: %w", err)
+	}
+
+	// status カラム追加前に公開済みだった投稿を published に揃える
+	if err := db.Exec(
+		"UPDATE posts SET status = ?, published_at = COALESCE(published_at, created_at) WHERE published = ? AND status = ?",
+		models.PostStatusPublished, true, models.PostStatusDraft,
+	).Error; err != nil {
+		return fmt.Errorf("post status backfill failed: %w", err)
+	}
+
+	// status カラム追加前に承認済みだったコメントを approved に揃える
+	if err := db.Exec(
+		"UPDATE comments SET status = ? WHERE approved = ? AND status = ?",
+		models.CommentStatusApproved, true, models.CommentStatusPending,
+	).Error; err != nil {
+		return fmt.Errorf("comment status backfill failed: %w", err)
+	}
+
+	// name_key カラム追加前のタグに比較用の名前を設定
+	if err := BackfillTagNameKeys(db); err != nil {
+		return fmt.Errorf("tag name key backfill failed: %w", err)
+	}
+
+	// 暗号化を導入する前のコメントのメールアドレス・IP を暗号化
+	if encrypted, err := EncryptCommentPII(db); err != nil {
+		return fmt.Errorf("comment encryption backfill failed: %w", err)
+	} else if encrypted > 0 {
+		log.Printf("Encrypted personal data of %d comments", encrypted)
+	}
 	
 	log.Println("Migrations completed successfully")
 	return nil
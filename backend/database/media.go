@@ -0,0 +1,82 @@
+package database
+
+import (
+	"blogapp/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SyncPostMedia - 投稿のアイキャッチ画像と本文から使っているメディアを探し、参照を置き換える
+// 縮小画像を使っている場合は元のメディアを参照しているものとする
+func SyncPostMedia(tx *gorm.DB, post *models.Post) error {
+	media := []models.Media{}
+	if keys := models.MediaKeysIn(post.ImageURL, post.Content); len(keys) > 0 {
+		variants := tx.Model(&models.MediaVariant{}).Select("media_id").Where("key IN ?", keys)
+		if err := tx.Where("key IN ?", keys).Or("id IN (?)", variants).Find(&media).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(post).Association("Media").Replace(media)
+}
+
+// UnreferencedMedia - before より前にアップロードされ、どの投稿（削除済みを含む）からも参照されていないメディア
+// アップロードしてから投稿を保存するまでの間に消さないよう、新しいものは含めない
+func UnreferencedMedia(db *gorm.DB, before time.Time) *gorm.DB {
+	return db.Model(&models.Media{}).
+		Where("created_at < ?", before).
+		Where("NOT EXISTS (SELECT 1 FROM post_media WHERE post_media.media_id = media.id)")
+}
+
+// FindMediaByKey - 元のファイルまたは縮小画像のキーからメディアを探す（縮小画像なら variant も返す）
+func FindMediaByKey(db *gorm.DB, key string) (*models.Media, *models.MediaVariant, error) {
+	var media models.Media
+	err := db.Where("key = ?", key).First(&media).Error
+	if err == nil {
+		return &media, nil, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, err
+	}
+
+	var variant models.MediaVariant
+	if err := db.Where("key = ?", key).First(&variant).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := db.First(&media, variant.MediaID).Error; err != nil {
+		return nil, nil, err
+	}
+	return &media, &variant, nil
+}
+
+// MediaVisibility - メディアを誰に見せてよいか
+type MediaVisibility int
+
+const (
+	MediaUnreferenced MediaVisibility = iota // どの投稿にも使われていない（誰でも見られるが、後で下書きに使われるかもしれない）
+	MediaPublished                           // 公開された投稿に使われている
+	MediaRestricted                          // 投稿に使われていて、そのどれも公開されていない
+)
+
+// GetMediaVisibility - メディアを使っている投稿の公開状態から、メディアを誰に見せてよいかを返す
+func GetMediaVisibility(db *gorm.DB, mediaID uint) (MediaVisibility, error) {
+	var row struct {
+		Referenced bool
+		Published  bool
+	}
+	err := db.Raw(`SELECT EXISTS (SELECT 1 FROM post_media WHERE media_id = ?) AS referenced,
+		EXISTS (
+			SELECT 1 FROM post_media JOIN posts ON posts.id = post_media.post_id
+			WHERE post_media.media_id = ? AND posts.status = ? AND posts.deleted_at IS NULL
+		) AS published`, mediaID, mediaID, models.PostStatusPublished).Scan(&row).Error
+	switch {
+	case err != nil:
+		return MediaUnreferenced, err
+	case row.Published:
+		return MediaPublished, nil
+	case row.Referenced:
+		return MediaRestricted, nil
+	}
+	return MediaUnreferenced, nil
+}
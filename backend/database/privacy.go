@@ -0,0 +1,50 @@
+package database
+
+import (
+	"blogapp/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EncryptCommentPII - 暗号化を導入する前に保存されたコメントのメールアドレス・IP を暗号化し、検索用ハッシュを付ける
+// 読み込み時に平文はそのまま返されるので、保存し直すだけで暗号化される
+func EncryptCommentPII(db *gorm.DB) (int64, error) {
+	var updated int64
+	var batch []models.Comment
+	err := db.Unscoped().
+		Select("id", "email", "ip").
+		Where("email_hash IS NULL OR email_hash = ''").
+		FindInBatches(&batch, 200, func(_ *gorm.DB, _ int) error {
+			for i := range batch {
+				comment := &batch[i]
+				emailHash, err := models.CommentEmailHash(comment.Email)
+				if err != nil {
+					return err
+				}
+				if err := db.Unscoped().Model(&models.Comment{ID: comment.ID}).
+					Select("email", "ip", "email_hash").
+					UpdateColumns(&models.Comment{
+						Email:     comment.Email,
+						IP:        comment.IP,
+						EmailHash: emailHash,
+					}).Error; err != nil {
+					return err
+				}
+				updated++
+			}
+			return nil
+		}).Error
+	return updated, err
+}
+
+// AnonymizeCommentIPs - before より前に投稿されたコメントの IP とユーザーエージェントを消す
+func AnonymizeCommentIPs(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Unscoped().Model(&models.Comment{}).
+		Where("created_at < ? AND (ip <> '' OR user_agent <> '')", before).
+		UpdateColumns(map[string]interface{}{
+			"ip":         "",
+			"user_agent": "",
+		})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"blogapp/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StorageUsage - アップロードしたファイル（縮小画像を含む）の合計
+type StorageUsage struct {
+	Bytes int64 `json:"bytes"`
+	Files int64 `json:"files"`
+}
+
+// TypeStorageUsage - ファイルの種類ごとの合計
+type TypeStorageUsage struct {
+	MIME  string `json:"mime"`
+	Bytes int64  `json:"bytes"`
+	Files int64  `json:"files"`
+}
+
+// UserStorageUsage - ユーザーごとの合計
+type UserStorageUsage struct {
+	UserID       uint   `json:"user_id"`
+	Username     string `json:"username"`
+	Role         string `json:"role"`
+	StorageQuota *int64 `json:"-"`
+	Bytes        int64  `json:"bytes"`
+	Files        int64  `json:"files"`
+}
+
+// mediaSizes - メディアごとの大きさ（元のファイルと縮小画像の合計）を集計するクエリ
+func mediaSizes(db *gorm.DB) *gorm.DB {
+	return db.Table("media").
+		Joins("LEFT JOIN (SELECT media_id, SUM(size) AS size FROM media_variants GROUP BY media_id) variants ON variants.media_id = media.id")
+}
+
+const mediaSizeSum = "COALESCE(SUM(media.size + COALESCE(variants.size, 0)), 0) AS bytes, COUNT(*) AS files"
+
+// MediaStorageUsage - ユーザーがアップロードしたメディアの合計（userID が 0 なら全員、削除したメディアは含まない）
+func MediaStorageUsage(db *gorm.DB, userID uint) (StorageUsage, error) {
+	query := mediaSizes(db).Select(mediaSizeSum)
+	if userID != 0 {
+		query = query.Where("media.uploader_id = ?", userID)
+	}
+	var usage StorageUsage
+	err := query.Scan(&usage).Error
+	return usage, err
+}
+
+// MediaStorageUsageByType - 種類ごとの合計（userID が 0 なら全員）
+func MediaStorageUsageByType(db *gorm.DB, userID uint) ([]TypeStorageUsage, error) {
+	query := mediaSizes(db).Select("media.mime AS mime, " + mediaSizeSum)
+	if userID != 0 {
+		query = query.Where("media.uploader_id = ?", userID)
+	}
+	usage := []TypeStorageUsage{}
+	err := query.Group("media.mime").Order("bytes DESC").Scan(&usage).Error
+	return usage, err
+}
+
+// MediaStorageUsageByUser - ユーザーごとの合計（多い順）
+func MediaStorageUsageByUser(db *gorm.DB, offset, limit int) ([]UserStorageUsage, error) {
+	usage := []UserStorageUsage{}
+	err := mediaSizes(db).
+		Select("media.uploader_id AS user_id, users.username, users.role, users.storage_quota, " + mediaSizeSum).
+		Joins("LEFT JOIN users ON users.id = media.uploader_id").
+		Group("media.uploader_id, users.username, users.role, users.storage_quota").
+		Order("bytes DESC, media.uploader_id").
+		Offset(offset).Limit(limit).
+		Scan(&usage).Error
+	return usage, err
+}
+
+// CountMediaUploaders - メディアをアップロードしたユーザーの数
+func CountMediaUploaders(db *gorm.DB) (int64, error) {
+	var count int64
+	err := db.Model(&models.Media{}).Distinct("uploader_id").Count(&count).Error
+	return count, err
+}
+
+// PendingUploadBytes - 受け取り途中の再開可能なアップロードの大きさの合計（完了すると使う容量）
+func PendingUploadBytes(db *gorm.DB, userID uint) (int64, error) {
+	var bytes int64
+	err := db.Model(&models.TusUpload{}).
+		Select("COALESCE(SUM(length), 0)").
+		Where("user_id = ? AND media_id IS NULL AND error = '' AND expires_at > ?", userID, time.Now()).
+		Scan(&bytes).Error
+	return bytes, err
+}
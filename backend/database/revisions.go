@@ -0,0 +1,78 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RevisionRetention - 投稿リビジョンの保持ポリシー
+type RevisionRetention struct {
+	// Keep は投稿ごとに残す最大件数（0 で無制限）
+	Keep int
+	// MaxAge より古いリビジョンを削除する（0 で無制限）。各投稿の最新1件は常に残す
+	MaxAge time.Duration
+}
+
+// PrunePostRevisions - 1つの投稿のリビジョンを保持ポリシーに従って削除
+func PrunePostRevisions(db *gorm.DB, postID uint, policy RevisionRetention) error {
+	if policy.Keep > 0 {
+		if err := db.Exec(`
+			DELETE FROM post_revisions
+			WHERE post_id = ? AND id NOT IN (
+				SELECT id FROM post_revisions
+				WHERE post_id = ?
+				ORDER BY created_at DESC, id DESC
+				LIMIT ?
+			)`, postID, postID, policy.Keep).Error; err != nil {
+			return err
+		}
+	}
+
+	if policy.MaxAge > 0 {
+		if err := db.Exec(`
+			DELETE FROM post_revisions
+			WHERE post_id = ? AND created_at < ? AND id <> (
+				SELECT MAX(id) FROM post_revisions WHERE post_id = ?
+			)`, postID, time.Now().Add(-policy.MaxAge), postID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PruneAllPostRevisions - 全投稿のリビジョンを保持ポリシーに従って削除
+func PruneAllPostRevisions(db *gorm.DB, policy RevisionRetention) (int64, error) {
+	var deleted int64
+
+	if policy.Keep > 0 {
+		result := db.Exec(`
+			DELETE FROM post_revisions
+			WHERE id IN (
+				SELECT id FROM (
+					SELECT id, ROW_NUMBER() OVER (
+						PARTITION BY post_id ORDER BY created_at DESC, id DESC
+					) AS rn
+					FROM post_revisions
+				) ranked
+				WHERE ranked.rn > ?
+			)`, policy.Keep)
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+	}
+
+	if policy.MaxAge > 0 {
+		result := db.Exec(`
+			DELETE FROM post_revisions r
+			WHERE r.created_at < ? AND r.id <> (
+				SELECT MAX(id) FROM post_revisions WHERE post_id = r.post_id
+			)`, time.Now().Add(-policy.MaxAge))
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+	}
+	return deleted, nil
+}
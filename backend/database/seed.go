@@ -5,7 +5,6 @@ import (
 	"log"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -13,16 +12,11 @@ import (
 func SeedData(db *gorm.DB) error {
 	log.Println("Seeding database...")
 	
-	// 1. 管理者ユーザーの作成
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
-	if err != nil {
-		return err
-	}
-	
+	// 1. 管理者ユーザーの作成（パスワードは BeforeCreate でハッシュ化される）
 	adminUser := &models.User{
 		Email:             "admin@example.com",
 		Username:          "admin",
-		Password:          string(hashedPassword),
+		Password:          "admin123",
 		DisplayName:       "管理者",
 		IsAdmin:           true,
 		IsVerified:        true,
@@ -49,7 +43,7 @@ func SeedData(db *gorm.DB) error {
 	testUser := &models.User{
 		Email:             "user@example.com",
 		Username:          "testuser",
-		Password:          string(hashedPassword), // 実際は別のパスワードを設定
+		Password:          "admin123", // 実際は別のパスワードを設定
 		DisplayName:       "テストユーザー",
 		IsAdmin:           false,
 		IsVerified:        true,
@@ -126,7 +120,7 @@ func SeedData(db *gorm.DB) error {
 			Excerpt:   "Go言語の基本的な使い方を解説します。",
 			Published: true,
 			AuthorID:  admin.ID,
-			CategoryID: categoryIDs[0], // 技術カテゴリー
+			CategoryID: &categoryIDs[0], // 技術カテゴリー
 		},
 		{
 			Title:     "ReactとTypeScriptの組み合わせ",
@@ -135,7 +129,7 @@ func SeedData(db *gorm.DB) error {
 			Excerpt:   "React+TypeScriptの始め方",
 			Published: true,
 			AuthorID:  admin.ID,
-			CategoryID: categoryIDs[0], // 技術カテゴリー
+			CategoryID: &categoryIDs[0], // 技術カテゴリー
 		},
 		{
 			Title:     "おすすめの旅行先",
@@ -144,7 +138,7 @@ func SeedData(db *gorm.DB) error {
 			Excerpt:   "旅行好き必見のおすすめスポット",
 			Published: true,
 			AuthorID:  admin.ID,
-			CategoryID: categoryIDs[2], // 旅行カテゴリー
+			CategoryID: &categoryIDs[2], // 旅行カテゴリー
 		},
 	}
 	
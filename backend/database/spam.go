@@ -0,0 +1,59 @@
+package database
+
+import (
+	"blogapp/internal/spam"
+	"blogapp/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SpamTokenStore - spam_tokens テーブルに保存するスパム判定の学習データ
+type SpamTokenStore struct {
+	DB *gorm.DB
+}
+
+var _ spam.TokenStore = SpamTokenStore{}
+
+// Counts - トークンごとの出現回数
+func (s SpamTokenStore) Counts(ctx context.Context, tokens []string) (map[string]spam.TokenCount, error) {
+	var rows []models.SpamToken
+	if err := s.DB.WithContext(ctx).Where("token IN ?", tokens).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]spam.TokenCount, len(rows))
+	for _, row := range rows {
+		counts[row.Token] = spam.TokenCount{Spam: row.Spam, Ham: row.Ham}
+	}
+	return counts, nil
+}
+
+// Train - トークンの出現回数を増減する（0 未満にはしない）
+func (s SpamTokenStore) Train(ctx context.Context, tokens []string, spamDelta, hamDelta int64) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	rows := make([]models.SpamToken, len(tokens))
+	for i, token := range tokens {
+		rows[i] = models.SpamToken{
+			Token:     token,
+			Spam:      max(spamDelta, 0),
+			Ham:       max(hamDelta, 0),
+			UpdatedAt: now,
+		}
+	}
+
+	return s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "token"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"spam":       gorm.Expr("GREATEST(spam_tokens.spam + ?, 0)", spamDelta),
+			"ham":        gorm.Expr("GREATEST(spam_tokens.ham + ?, 0)", hamDelta),
+			"updated_at": now,
+		}),
+	}).CreateInBatches(rows, 500).Error
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"blogapp/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// FindTagByName - 名前または別名からタグを探す（大文字小文字・全角半角を区別しない）
+func FindTagByName(db *gorm.DB, name string) (*models.Tag, error) {
+	key := models.NormalizeTagName(name)
+	if key == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var tag models.Tag
+	err := db.Where("name_key = ?", key).First(&tag).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return &tag, err
+	}
+
+	err = db.Where("id = (?)",
+		db.Model(&models.TagSynonym{}).Select("tag_id").Where("key = ?", key),
+	).First(&tag).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// MergeTags - sourceIDs のタグを targetID のタグへ統合する
+// 投稿との関連付けを付け替えて重複を除き、元のタグ名は別名として、
+// 元のスラッグは旧スラッグとして残してから元のタグを削除する。付け替えた関連付けの数を返す
+func MergeTags(tx *gorm.DB, targetID uint, sourceIDs []uint) (int64, error) {
+	var sources []models.Tag
+	if err := tx.Where("id IN ? AND id <> ?", sourceIDs, targetID).Find(&sources).Error; err != nil {
+		return 0, err
+	}
+	if len(sources) == 0 {
+		return 0, nil
+	}
+	ids := make([]uint, len(sources))
+	for i, source := range sources {
+		ids[i] = source.ID
+	}
+
+	// 両方のタグが付いている投稿は主キー (post_id, tag_id) の重複になるので飛ばす
+	result := tx.Exec(`
+		INSERT INTO post_tags (post_id, tag_id)
+		SELECT post_id, ? FROM post_tags WHERE tag_id IN ?
+		ON CONFLICT DO NOTHING`, targetID, ids)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	moved := result.RowsAffected
+	if err := tx.Exec("DELETE FROM post_tags WHERE tag_id IN ?", ids).Error; err != nil {
+		return 0, err
+	}
+
+	// 元のタグの別名と旧スラッグも統合先へ付け替える
+	if err := tx.Model(&models.TagSynonym{}).
+		Where("tag_id IN ?", ids).
+		Update("tag_id", targetID).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&models.SlugHistory{}).
+		Where("entity_type = ? AND entity_id IN ?", models.SlugEntityTag, ids).
+		Update("entity_id", targetID).Error; err != nil {
+		return 0, err
+	}
+
+	var target models.Tag
+	if err := tx.First(&target, targetID).Error; err != nil {
+		return 0, err
+	}
+	for _, source := range sources {
+		if err := AddTagSynonym(tx, targetID, source.Name); err != nil {
+			return 0, err
+		}
+		if err := models.RecordSlugChange(tx, models.SlugEntityTag, targetID, source.Slug, target.Slug); err != nil {
+			return 0, err
+		}
+	}
+
+	// 名前・スラッグの一意制約は論理削除済みの行も対象になるため物理削除する
+	if err := tx.Unscoped().Delete(&models.Tag{}, ids).Error; err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+// AddTagSynonym - タグに別名を追加（既に同じ別名があればタグを付け替える）
+func AddTagSynonym(tx *gorm.DB, tagID uint, name string) error {
+	synonym := models.NewTagSynonym(tagID, name)
+	if synonym.Key == "" {
+		return nil
+	}
+	return tx.Where(models.TagSynonym{Key: synonym.Key}).
+		Assign(models.TagSynonym{TagID: tagID}).
+		FirstOrCreate(synonym).Error
+}
+
+// UnusedTags - どの投稿（下書き・削除済みを含む）にも付いていないタグのクエリ
+func UnusedTags(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Tag{}).
+		Where("NOT EXISTS (SELECT 1 FROM post_tags WHERE post_tags.tag_id = tags.id)")
+}
+
+// BackfillTagNameKeys - 比較用の名前が未設定のタグ（name_key カラム追加前に作成）に設定する
+func BackfillTagNameKeys(db *gorm.DB) error {
+	var batch []models.Tag
+	return db.Unscoped().
+		Select("id", "name").
+		Where("name_key IS NULL OR name_key = ''").
+		FindInBatches(&batch, 200, func(_ *gorm.DB, _ int) error {
+			for _, tag := range batch {
+				if err := db.Unscoped().Model(&models.Tag{}).Where("id = ?", tag.ID).
+					UpdateColumn("name_key", models.NormalizeTagName(tag.Name)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
@@ -1,9 +1,16 @@
 package handlers
 
 import (
+	"blogapp/database"
+	"blogapp/models"
+	"blogapp/utils"
+	"errors"
 	"net/http"
-	
+	"strconv"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // Register 新規登録
@@ -13,14 +20,14 @@ func Register(c *gin.Context) {
 		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
-	
+
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Invalid request",
 		})
 		return
 	}
-	
+
 	// TODO: 実際の登録ロジック
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User registered successfully",
@@ -31,28 +38,53 @@ func Register(c *gin.Context) {
 	})
 }
 
-// Login ログイン処理
+// Login メールアドレスとパスワードを確かめ、ユーザー ID とロールを入れたトークンを返す
+// ロールを変えた場合は、トークンの期限が切れて再ログインするまで前のロールのまま
 func Login(c *gin.Context) {
 	var req struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
 	}
-	
+
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Invalid request",
 		})
 		return
 	}
-	
-	// TODO: 実際の認証ロジック
-	// ここではダミーのトークンを返す
+
+	var user models.User
+	err := database.GetDB().Where("email = ?", strings.TrimSpace(req.Email)).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to log in",
+		})
+		return
+	}
+	// 登録されていないメールアドレスとパスワードの誤りは区別しない
+	if err != nil || !user.CheckPassword(req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid email or password",
+		})
+		return
+	}
+
+	role := user.EffectiveRole()
+	token, err := utils.NewJWT(getConfig().JWTSecret).GenerateToken(strconv.FormatUint(uint64(user.ID), 10), user.Email, role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate token",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"token": "dummy-jwt-token",
+		"token": token,
 		"user": gin.H{
-			"id":    1,
-			"email": req.Email,
-			"name":  "Test User",
+			"id":    user.ID,
+			"email": user.Email,
+			"name":  displayName(&user),
+			"role":  role,
 		},
 	})
 }
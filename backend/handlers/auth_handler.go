@@ -46,7 +46,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	
 	// TODO: 実際の認証ロジック
 	// ここではダミーデータを返す
-	token, err := h.jwt.GenerateToken("1", "test@example.com")
+	token, err := h.jwt.GenerateToken("1", "test@example.com", "author")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to generate token",
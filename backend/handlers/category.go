@@ -1,48 +1,104 @@
 package handlers
 
 import (
+	"blogapp/database"
+	"blogapp/models"
+	"errors"
 	"net/http"
-	
+
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// GetCategories すべてのカテゴリーを取得
+// categoryWithCount 公開済み投稿数つきのカテゴリー
+type categoryWithCount struct {
+	models.Category
+	PostCount int64 `json:"post_count"`
+}
+
+// GetCategories すべてのカテゴリーを取得（公開済み投稿数つき）
 func GetCategories(c *gin.Context) {
-	// TODO: 実際のデータベースから取得
+	db := database.GetDB()
+
+	var categories []models.Category
+	if err := db.Order("position, name").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch categories",
+		})
+		return
+	}
+
+	counts, err := publishedPostCounts(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count posts",
+		})
+		return
+	}
+
+	result := make([]categoryWithCount, len(categories))
+	for i, category := range categories {
+		result[i] = categoryWithCount{Category: category, PostCount: counts[category.ID]}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"categories": []gin.H{
-			{
-				"id":   1,
-				"name": "Technology",
-				"slug": "technology",
-			},
-			{
-				"id":   2,
-				"name": "Programming",
-				"slug": "programming",
-			},
-		},
+		"categories": result,
 	})
 }
 
 // GetCategory IDでカテゴリーを取得
 func GetCategory(c *gin.Context) {
-	id := c.Param("id")
-	// TODO: 実際のデータベースから取得
+	db := database.GetDB()
+
+	var category models.Category
+	if err := db.First(&category, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Category not found",
+		})
+		return
+	}
+
+	var count int64
+	if err := db.Model(&models.Post{}).
+		Where("category_id = ? AND status = ?", category.ID, models.PostStatusPublished).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count posts",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"category": gin.H{
-			"id":   id,
-			"name": "Technology",
-			"slug": "technology",
-		},
+		"category": categoryWithCount{Category: category, PostCount: count},
 	})
 }
 
+// GetCategoryPosts カテゴリーの公開済み投稿を取得（:id にはスラッグまたはID）
+func GetCategoryPosts(c *gin.Context) {
+	db := database.GetDB()
+
+	var category models.Category
+	if err := findBySlugOrID(db, &category, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Category not found",
+		})
+		return
+	}
+
+	query := db.Model(&models.Post{}).
+		Where("status = ? AND category_id = ?", models.PostStatusPublished, category.ID)
+	respondPosts(c, query, gin.H{"category": category})
+}
+
 // CreateCategory 新しいカテゴリーを作成
 func CreateCategory(c *gin.Context) {
 	var req struct {
-		Name string `json:"name" binding:"required"`
-		Slug string `json:"slug" binding:"required"`
+		Name        string `json:"name" binding:"required"`
+		Slug        string `json:"slug"` // 省略時は名前から生成
+		Description string `json:"description"`
+		ParentID    uint   `json:"parent_id"` // 0 ならルート
+		Position    *int   `json:"position"`  // 省略時は兄弟の末尾
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -52,23 +108,97 @@ func CreateCategory(c *gin.Context) {
 		return
 	}
 
-	// TODO: 実際のデータベースに保存
+	db := database.GetDB()
+	if taken, err := nameTaken(db, &models.Category{}, req.Name, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create category",
+		})
+		return
+	} else if taken {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Category name already exists",
+		})
+		return
+	}
+
+	parentID, msg := validateCategoryParent(db, 0, req.ParentID)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	resolveCategorySlug := func() (string, error) {
+		return resolveSlug(db, &models.Category{}, req.Slug, req.Name, 0)
+	}
+	slugValue, err := resolveCategorySlug()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate slug",
+		})
+		return
+	}
+	needsFallback := slugValue == ""
+	if needsFallback {
+		slugValue = pendingSlug()
+	}
+
+	category := models.Category{
+		Name:        req.Name,
+		Slug:        slugValue,
+		Description: req.Description,
+		ParentID:    parentID,
+	}
+	if req.Position != nil {
+		category.Position = *req.Position
+	} else if category.Position, err = nextCategoryPosition(db, parentID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create category",
+		})
+		return
+	}
+
+	err = retrySlugConflict(&category.Slug, resolveCategorySlug, func() error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&category).Error; err != nil {
+				return err
+			}
+			if needsFallback {
+				var err error
+				category.Slug, err = assignFallbackSlug(tx, &category, "category", category.ID)
+				return err
+			}
+			return nil
+		})
+	})
+	if isDuplicateKey(err) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Category name or slug already exists",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create category",
+		})
+		return
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Category created successfully",
-		"category": gin.H{
-			"id":   1,
-			"name": req.Name,
-			"slug": req.Slug,
-		},
+		"message":  "Category created successfully",
+		"category": category,
 	})
 }
 
 // UpdateCategory カテゴリーを更新
 func UpdateCategory(c *gin.Context) {
-	id := c.Param("id")
 	var req struct {
-		Name string `json:"name"`
-		Slug string `json:"slug"`
+		Name        *string `json:"name"`
+		Slug        *string `json:"slug"`
+		Description *string `json:"description"`
+		ParentID    *uint   `json:"parent_id"` // 0 でルートへ移動
+		Position    *int    `json:"position"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -78,24 +208,351 @@ func UpdateCategory(c *gin.Context) {
 		return
 	}
 
-	// TODO: 実際のデータベースを更新
+	db := database.GetDB()
+	var category models.Category
+	if err := db.First(&category, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Category not found",
+		})
+		return
+	}
+
+	if req.Name != nil && *req.Name != "" && *req.Name != category.Name {
+		if taken, err := nameTaken(db, &models.Category{}, *req.Name, category.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update category",
+			})
+			return
+		} else if taken {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Category name already exists",
+			})
+			return
+		}
+		category.Name = *req.Name
+	}
+	var resolveCategorySlug func() (string, error)
+	if req.Slug != nil && *req.Slug != "" {
+		resolveCategorySlug = func() (string, error) {
+			return resolveSlug(db, &models.Category{}, *req.Slug, category.Name, category.ID)
+		}
+		slugValue, err := resolveCategorySlug()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to generate slug",
+			})
+			return
+		}
+		if slugValue != "" {
+			category.Slug = slugValue
+		}
+	}
+	if req.Description != nil {
+		category.Description = *req.Description
+	}
+	if req.ParentID != nil {
+		parentID, msg := validateCategoryParent(db, category.ID, *req.ParentID)
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": msg,
+			})
+			return
+		}
+		category.ParentID = parentID
+	}
+	if req.Position != nil {
+		category.Position = *req.Position
+	}
+
+	err := retrySlugConflict(&category.Slug, resolveCategorySlug, func() error {
+		return db.Omit(clause.Associations).Save(&category).Error
+	})
+	if isDuplicateKey(err) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Category name or slug already exists",
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update category",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Category updated successfully",
-		"category": gin.H{
-			"id":   id,
-			"name": req.Name,
-			"slug": req.Slug,
-		},
+		"message":  "Category updated successfully",
+		"category": category,
 	})
 }
 
 // DeleteCategory カテゴリーを削除
+// 投稿が残っている場合は ?reassign_to=<id> で移動先を指定するか、?force=true で未分類にする
+// 子カテゴリーは削除するカテゴリーの親へ付け替える
 func DeleteCategory(c *gin.Context) {
-	id := c.Param("id")
-	
-	// TODO: 実際のデータベースから削除
+	db := database.GetDB()
+
+	var category models.Category
+	if err := db.First(&category, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Category not found",
+		})
+		return
+	}
+
+	var reassignTo *uint
+	if value := c.Query("reassign_to"); value != "" {
+		var target models.Category
+		if err := db.First(&target, parseID(value)).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Reassignment target not found",
+			})
+			return
+		}
+		if target.ID == category.ID {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Cannot reassign posts to the category being deleted",
+			})
+			return
+		}
+		reassignTo = &target.ID
+	}
+	force := c.Query("force") == "true"
+
+	// 下書きや削除済みの投稿も参照しているので Unscoped で数える
+	var postCount int64
+	if err := db.Unscoped().Model(&models.Post{}).
+		Where("category_id = ?", category.ID).
+		Count(&postCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count posts",
+		})
+		return
+	}
+	if postCount > 0 && reassignTo == nil && !force {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      "Category has posts; specify reassign_to or force=true",
+			"post_count": postCount,
+		})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if postCount > 0 {
+			if err := tx.Unscoped().Model(&models.Post{}).
+				Where("category_id = ?", category.ID).
+				UpdateColumn("category_id", reassignTo).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.Category{}).
+			Where("parent_id = ?", category.ID).
+			Update("parent_id", category.ParentID).Error; err != nil {
+			return err
+		}
+		// 名前・スラッグの一意制約は論理削除済みの行も対象になるため物理削除する
+		return tx.Unscoped().Delete(&category).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete category",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Category deleted successfully",
+		"id":             category.ID,
+		"affected_posts": postCount,
+	})
+}
+
+// categoryNode カテゴリーツリーの1ノード
+type categoryNode struct {
+	models.Category
+	PostCount      int64           `json:"post_count"`       // このカテゴリー直下の公開済み投稿数
+	TotalPostCount int64           `json:"total_post_count"` // 子孫カテゴリーを含む公開済み投稿数
+	Children       []*categoryNode `json:"children"`
+}
+
+// GetCategoryTree カテゴリーを階層構造で取得（公開済み投稿数つき）
+func GetCategoryTree(c *gin.Context) {
+	db := database.GetDB()
+
+	var categories []models.Category
+	if err := db.Order("position, name").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch categories",
+		})
+		return
+	}
+
+	counts, err := publishedPostCounts(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count posts",
+		})
+		return
+	}
+
+	nodes := make(map[uint]*categoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &categoryNode{
+			Category:  category,
+			PostCount: counts[category.ID],
+			Children:  []*categoryNode{},
+		}
+	}
+
+	roots := []*categoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if parent, ok := nodes[derefUint(category.ParentID)]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	for _, root := range roots {
+		sumPostCounts(root)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"categories": roots,
+	})
+}
+
+// ReorderCategories 兄弟カテゴリーの並び順を変更
+// ids に指定した順で position を 0, 1, 2 ... に振り直す
+func ReorderCategories(c *gin.Context) {
+	var req struct {
+		ParentID uint   `json:"parent_id"`
+		IDs      []uint `json:"ids" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		for position, id := range req.IDs {
+			query := tx.Model(&models.Category{}).Where("id = ?", id)
+			if req.ParentID == 0 {
+				query = query.Where("parent_id IS NULL")
+			} else {
+				query = query.Where("parent_id = ?", req.ParentID)
+			}
+			result := query.Update("position", position)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "All ids must be children of the given parent",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to reorder categories",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Category deleted successfully",
-		"id":      id,
+		"message": "Categories reordered successfully",
 	})
 }
+
+// validateCategoryParent 親カテゴリーを検証（0 ならルート）
+// 自分自身や子孫を親にすると循環するため拒否する
+func validateCategoryParent(db *gorm.DB, categoryID, parentID uint) (*uint, string) {
+	if parentID == 0 {
+		return nil, ""
+	}
+	if parentID == categoryID {
+		return nil, "A category cannot be its own parent"
+	}
+
+	var parent models.Category
+	if err := db.First(&parent, parentID).Error; err != nil {
+		return nil, "Parent category not found"
+	}
+
+	if categoryID != 0 {
+		ancestors, err := database.CategoryAncestorIDs(db, parentID)
+		if err != nil {
+			return nil, "Failed to validate parent category"
+		}
+		for _, id := range ancestors {
+			if id == categoryID {
+				return nil, "A category cannot be moved under its own descendant"
+			}
+		}
+	}
+	return &parent.ID, ""
+}
+
+// nextCategoryPosition 兄弟カテゴリーの末尾の position
+func nextCategoryPosition(db *gorm.DB, parentID *uint) (int, error) {
+	var maxPosition *int
+	query := db.Model(&models.Category{}).Select("MAX(position)")
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+	if err := query.Scan(&maxPosition).Error; err != nil {
+		return 0, err
+	}
+	if maxPosition == nil {
+		return 0, nil
+	}
+	return *maxPosition + 1, nil
+}
+
+// publishedPostCounts カテゴリーごとの公開済み投稿数
+func publishedPostCounts(db *gorm.DB) (map[uint]int64, error) {
+	var rows []struct {
+		CategoryID *uint
+		Count      int64
+	}
+	if err := db.Model(&models.Post{}).
+		Select("category_id, COUNT(*) AS count").
+		Where("status = ?", models.PostStatusPublished).
+		Group("category_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		// 未分類（category_id が NULL）は 0 に集計する
+		counts[derefUint(row.CategoryID)] = row.Count
+	}
+	return counts, nil
+}
+
+// sumPostCounts 子孫を含めた投稿数を集計
+func sumPostCounts(node *categoryNode) int64 {
+	node.TotalPostCount = node.PostCount
+	for _, child := range node.Children {
+		node.TotalPostCount += sumPostCounts(child)
+	}
+	return node.TotalPostCount
+}
+
+func derefUint(p *uint) uint {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
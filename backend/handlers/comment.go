@@ -1,83 +1,391 @@
-// handlers/comment.go
 package handlers
 
 import (
-	"net/http"
+	"blogapp/database"
+	"blogapp/internal/spam"
 	"blogapp/models"
-	"blogapp/utils"
-	"github.com/labstack/echo/v4"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-type CommentHandler struct {
-	db *gorm.DB
+// GetComments 投稿に対するコメントを取得
+// 一般の閲覧者には承認済みのコメントのみ、モデレーター（編集者・管理者）には承認待ちと承認済み
+// （?status=pending|approved|spam|trash で絞り込み、all で全件）を返す
+// ?format=tree（既定）なら返信を replies に入れたツリー、flat なら parent_id つきの作成日時順の一覧。
+// 削除された親コメントは返信を残したまま "[deleted]" として表示する
+func GetComments(c *gin.Context) {
+	db := database.GetDB()
+
+	var post models.Post
+	if err := db.First(&post, idParam(c, "id")).Error; err != nil || !canViewPost(c, &post) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Post not found",
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", "tree")
+	if format != "tree" && format != "flat" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "format must be tree or flat",
+		})
+		return
+	}
+
+	moderator := isStaff(c)
+	statuses, ok := visibleCommentStatuses(moderator, c.Query("status"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid status",
+		})
+		return
+	}
+	// visibleScope と visible は同じ条件（SQL での絞り込みと、読み込んだスレッドの中での判定）
+	visibleScope := func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("status IN ?", statuses)
+	}
+	visible := func(comment *models.Comment) bool {
+		return !comment.DeletedAt.Valid && slices.Contains(statuses, comment.Status)
+	}
+
+	// ページ分けはスレッド（トップレベルのコメントとその返信）単位
+	page, perPage := parsePagination(c)
+	var total int64
+	if err := db.Model(&models.Comment{}).Where("post_id = ?", post.ID).Scopes(visibleScope).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch comments",
+		})
+		return
+	}
+	rootIDs, threads, err := database.CommentThreadRoots(db, post.ID, visibleScope, (page-1)*perPage, perPage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch comments",
+		})
+		return
+	}
+
+	// 返信のつながりを保つため、削除済み・未承認のコメントも読み込んでから絞り込む
+	all, err := database.CommentThreads(db, rootIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch comments",
+		})
+		return
+	}
+
+	flat, roots := buildCommentThread(all, visible)
+	if !moderator {
+		for _, view := range flat {
+			view.Email = ""
+		}
+	}
+
+	comments := flat
+	if format == "tree" {
+		comments = roots
+	} else {
+		for _, view := range flat {
+			view.Replies = nil
+		}
+	}
+	if comments == nil {
+		comments = []*commentView{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"format":   format,
+		"comments": comments,
+		"total":    total, // 表示できるコメントの数（"[deleted]" の代わりのコメントは含まない）
+		"threads":  threads,
+		"page":     page,
+		"per_page": perPage,
+	})
 }
 
-func NewCommentHandler(db *gorm.DB) *CommentHandler {
-	return &CommentHandler{db: db}
+// visibleCommentStatuses 閲覧者に見せるコメントのステータス
+// 一般の閲覧者は承認済みのみ（status は無視する）。モデレーターは既定で承認待ちと承認済み、
+// status で1つに絞り込み、all ですべて。定義されていない status なら false
+func visibleCommentStatuses(moderator bool, status string) ([]string, bool) {
+	switch {
+	case !moderator:
+		return []string{models.CommentStatusApproved}, true
+	case status == "":
+		return []string{models.CommentStatusPending, models.CommentStatusApproved}, true
+	case status == "all":
+		return models.CommentStatuses(), true
+	case models.IsValidCommentStatus(status):
+		return []string{status}, true
+	}
+	return nil, false
 }
 
-func (h *CommentHandler) ListByPost(c echo.Context) error {
-	postID := c.Param("postId")
-	var comments []models.Comment
-	query := h.db.Where("post_id = ?", postID)
-	
-	if !isAdmin(c) {
-		query = query.Where("approved = ?", true)
+// CreateComment コメントを作成（承認されるまでは公開されない）
+// メールアドレスと IP は暗号化して保存し、レスポンスにはメールアドレスの代わりにアバターのハッシュを返す
+// COMMENT_AUTO_APPROVE が有効なら、同じメールアドレスの承認済みコメントがある投稿者は自動承認する
+func CreateComment(c *gin.Context) {
+	var req struct {
+		Author   string `json:"author" binding:"required,max=100"`
+		Email    string `json:"email" binding:"required,email,max=255"`
+		Content  string `json:"content" binding:"required,max=10000"`
+		ParentID uint   `json:"parent_id"` // 返信先のコメント（0 ならトップレベル）
+
+		NotifyReplies bool `json:"notify_replies"` // 返信があればメールで知らせてほしい
+
+		// スパム対策（GetCommentFormToken で発行したトークンと、フォームに隠しておく入力欄）
+		Token   string `json:"token"`
+		Website string `json:"website"`
 	}
-	
-	if err := query.Order("created_at desc").Find(&comments).Error; err != nil {
-		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch comments")
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var post models.Post
+	if err := db.First(&post, idParam(c, "id")).Error; err != nil || post.Status != models.PostStatusPublished {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Post not found",
+		})
+		return
 	}
-	return utils.SuccessResponse(c, http.StatusOK, comments)
+
+	comment := models.Comment{
+		PostID:  post.ID,
+		Author:  strings.TrimSpace(req.Author),
+		Email:   strings.TrimSpace(req.Email),
+		Content: strings.TrimSpace(req.Content),
+		Status:  models.CommentStatusPending,
+
+		NotifyReplies: req.NotifyReplies,
+
+		IP:        c.ClientIP(),
+		UserAgent: truncateUTF8(c.Request.UserAgent(), 255),
+	}
+	if comment.Author == "" || comment.Content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Author and content are required",
+		})
+		return
+	}
+
+	if req.ParentID != 0 {
+		var parent models.Comment
+		if err := db.Where("post_id = ? AND status = ?", post.ID, models.CommentStatusApproved).
+			First(&parent, req.ParentID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Parent comment not found",
+			})
+			return
+		}
+		if parent.Depth+1 > getConfig().CommentMaxDepth {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Replies are nested too deeply",
+			})
+			return
+		}
+		comment.ParentID = &parent.ID
+		comment.Depth = parent.Depth + 1
+	}
+
+	if getConfig().CommentAutoApprove {
+		emailHash, err := models.CommentEmailHash(comment.Email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to create comment",
+			})
+			return
+		}
+		var approvedCount int64
+		if err := db.Model(&models.Comment{}).
+			Where("email_hash = ? AND status = ?", emailHash, models.CommentStatusApproved).
+			Count(&approvedCount).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to create comment",
+			})
+			return
+		}
+		if approvedCount > 0 {
+			comment.Status = models.CommentStatusApproved
+		}
+	}
+
+	// スパムと判定されたコメントは自動承認より優先してスパムにする
+	report := commentSpamPipeline(db).Run(c.Request.Context(), &spam.Input{
+		PostID:    post.ID,
+		Author:    comment.Author,
+		Email:     comment.Email,
+		Content:   comment.Content,
+		IP:        comment.IP,
+		UserAgent: comment.UserAgent,
+		Honeypot:  req.Website,
+		Token:     req.Token,
+	})
+	comment.SpamScore = report.Score
+	comment.SpamReport = report
+	if report.Spam {
+		comment.Status = models.CommentStatusSpam
+	}
+
+	if err := db.Create(&comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create comment",
+		})
+		return
+	}
+
+	go notifyNewComment(comment, post)
+	if comment.Status == models.CommentStatusApproved {
+		go notifyReplies([]uint{comment.ID})
+	}
+
+	// スパムと判定したことは投稿者に知らせない（承認待ちと同じ応答にする）
+	message := "Comment submitted and awaiting moderation"
+	if comment.Status == models.CommentStatusApproved {
+		message = "Comment published successfully"
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"message": message,
+		"comment": gin.H{
+			"id":         comment.ID,
+			"post_id":    comment.PostID,
+			"parent_id":  comment.ParentID,
+			"author":     comment.Author,
+			"avatar":     comment.Avatar,
+			"content":    comment.Content,
+			"approved":   comment.Approved,
+			"created_at": comment.CreatedAt,
+		},
+	})
 }
 
-func (h *CommentHandler) Create(c echo.Context) error {
+// UpdateComment コメントを更新（モデレーター）
+func UpdateComment(c *gin.Context) {
 	var req struct {
-		Content string `json:"content" validate:"required"`
-		Author  string `json:"author" validate:"required"`
-		Email   string `json:"email" validate:"required,email"`
-		PostID  uint   `json:"post_id" validate:"required"`
+		Author   *string `json:"author" binding:"omitempty,max=100"`
+		Content  *string `json:"content" binding:"omitempty,max=10000"`
+		Status   *string `json:"status"`
+		Approved *bool   `json:"approved"` // status を省略した場合のみ使う（true で approved、false で pending）
 	}
-	if err := c.Bind(&req); err != nil {
-		return utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
 	}
 
-	comment := &models.Comment{
-		Content:  req.Content,
-		Author:   req.Author,
-		Email:    req.Email,
-		PostID:   req.PostID,
-		Approved: false,
+	db := database.GetDB()
+	var comment models.Comment
+	if err := db.First(&comment, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Comment not found",
+		})
+		return
+	}
+
+	previousStatus := comment.Status
+	if req.Author != nil && strings.TrimSpace(*req.Author) != "" {
+		comment.Author = strings.TrimSpace(*req.Author)
+	}
+	if req.Content != nil && strings.TrimSpace(*req.Content) != "" {
+		comment.Content = strings.TrimSpace(*req.Content)
+	}
+	if req.Status != nil {
+		if !models.IsValidCommentStatus(*req.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Unknown comment status",
+			})
+			return
+		}
+		comment.Status = *req.Status
+	} else if req.Approved != nil {
+		comment.Status = models.CommentStatusPending
+		if *req.Approved {
+			comment.Status = models.CommentStatusApproved
+		}
 	}
 
-	if err := h.db.Create(comment).Error; err != nil {
-		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create comment")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&comment).Error; err != nil {
+			return err
+		}
+		if comment.Status != previousStatus {
+			return trainSpamFilter(tx, []uint{comment.ID}, comment.Status)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update comment",
+		})
+		return
 	}
-	return utils.SuccessResponse(c, http.StatusCreated, comment)
+	if comment.Status == models.CommentStatusApproved && previousStatus != comment.Status {
+		go notifyReplies([]uint{comment.ID})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Comment updated successfully",
+		"comment": comment,
+	})
 }
 
-func (h *CommentHandler) Delete(c echo.Context) error {
-	id := c.Param("id")
-	if err := h.db.Delete(&models.Comment{}, id).Error; err != nil {
-		return utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete comment")
+// ApproveComment コメントを承認して公開（モデレーター）
+func ApproveComment(c *gin.Context) {
+	db := database.GetDB()
+	var comment models.Comment
+	if err := db.First(&comment, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Comment not found",
+		})
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		_, err := setCommentStatus(tx, []uint{comment.ID}, models.CommentStatusApproved)
+		return err
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to approve comment",
+		})
+		return
 	}
-	return utils.SuccessResponse(c, http.StatusOK, map[string]string{"message": "Comment deleted"})
+	comment.Status = models.CommentStatusApproved
+	comment.Approved = true
+	go notifyReplies([]uint{comment.ID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Comment approved successfully",
+		"comment": comment,
+	})
 }
 
-// isAdmin ユーザーが管理者かどうかを確認 (Echo用)
-func isAdmin(c echo.Context) bool {
-	// TODO: 実際の認証ロジック
-	// JWTミドルウェアでセットされたユーザー情報を確認
-	userRole := c.Get("user_role")
-	if userRole == nil {
-		return false
+// DeleteComment コメントを削除（モデレーター）
+func DeleteComment(c *gin.Context) {
+	result := database.GetDB().Delete(&models.Comment{}, idParam(c, "id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete comment",
+		})
+		return
 	}
-	
-	role, ok := userRole.(string)
-	if !ok {
-		return false
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Comment not found",
+		})
+		return
 	}
-	
-	return role == "admin"
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Comment deleted successfully",
+		"id":      idParam(c, "id"),
+	})
 }
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"blogapp/database"
+	"blogapp/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetModerationQueue モデレーション用のコメント一覧（モデレーター）
+// ?status=pending（既定）|approved|spam|trash|all、?post_id=、?q=（投稿者・メール・本文の部分一致）で絞り込み、
+// ステータスごとの件数も返す
+func GetModerationQueue(c *gin.Context) {
+	db := database.GetDB()
+
+	status := c.DefaultQuery("status", models.CommentStatusPending)
+	if status != "all" && !models.IsValidCommentStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Unknown comment status",
+		})
+		return
+	}
+
+	q := strings.TrimSpace(c.Query("q"))
+	emailHash, err := models.CommentEmailHash(q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch comments",
+		})
+		return
+	}
+
+	// ステータス以外の条件（件数の集計にも使う）
+	filtered := func() *gorm.DB {
+		query := db.Model(&models.Comment{})
+		if postID := c.Query("post_id"); postID != "" {
+			query = query.Where("post_id = ?", parseID(postID))
+		}
+		if q != "" {
+			// メールアドレスは暗号化しているため、部分一致ではなく検索用ハッシュとの完全一致で探す
+			pattern := "%" + escapeLike(q) + "%"
+			query = query.Where("author ILIKE ? OR email_hash = ? OR content ILIKE ?", pattern, emailHash, pattern)
+		}
+		return query
+	}
+
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := filtered().Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count comments",
+		})
+		return
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, s := range models.CommentStatuses() {
+		counts[s] = 0
+	}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+
+	query := filtered()
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+
+	page, perPage := parsePagination(c)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch comments",
+		})
+		return
+	}
+
+	var comments []models.Comment
+	if err := query.
+		Preload("Post", func(tx *gorm.DB) *gorm.DB {
+			return tx.Unscoped().Select("id", "title", "slug", "status")
+		}).
+		Order("created_at DESC, id DESC").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Find(&comments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch comments",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"comments": comments,
+		"counts":   counts,
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+	})
+}
+
+// GetComment コメントをメモつきで取得（モデレーター）
+func GetComment(c *gin.Context) {
+	var comment models.Comment
+	if err := database.GetDB().
+		Preload("Notes", func(tx *gorm.DB) *gorm.DB {
+			return tx.Order("created_at ASC")
+		}).
+		Preload("Notes.Author").
+		First(&comment, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Comment not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"comment": comment,
+	})
+}
+
+// ChangeCommentStatus コメントのステータスを変更（モデレーター）
+func ChangeCommentStatus(c *gin.Context) {
+	var req struct {
+		Status string `json:"status" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+	if !models.IsValidCommentStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Unknown comment status",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var comment models.Comment
+	if err := db.First(&comment, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Comment not found",
+		})
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		_, err := setCommentStatus(tx, []uint{comment.ID}, req.Status)
+		return err
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update comment",
+		})
+		return
+	}
+	comment.Status = req.Status
+	comment.Approved = req.Status == models.CommentStatusApproved
+	if comment.Approved {
+		go notifyReplies([]uint{comment.ID})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Comment status updated successfully",
+		"comment": comment,
+	})
+}
+
+// commentBulkActions 一括操作と変更後のステータス（delete は削除）
+var commentBulkActions = map[string]string{
+	"approve": models.CommentStatusApproved,
+	"pending": models.CommentStatusPending,
+	"spam":    models.CommentStatusSpam,
+	"trash":   models.CommentStatusTrash,
+	"delete":  "",
+}
+
+// BulkModerateComments コメントを一括で承認・スパム・ゴミ箱・削除（モデレーター）
+func BulkModerateComments(c *gin.Context) {
+	var req struct {
+		IDs    []uint `json:"ids" binding:"required"`
+		Action string `json:"action" binding:"required"` // approve / pending / spam / trash / delete
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+	status, ok := commentBulkActions[req.Action]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "action must be approve, pending, spam, trash or delete",
+		})
+		return
+	}
+	if len(req.IDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "ids must not be empty",
+		})
+		return
+	}
+
+	var affected int64
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if req.Action == "delete" {
+			result := tx.Where("id IN ?", req.IDs).Delete(&models.Comment{})
+			affected = result.RowsAffected
+			return result.Error
+		}
+		var err error
+		affected, err = setCommentStatus(tx, req.IDs, status)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update comments",
+		})
+		return
+	}
+	if status == models.CommentStatusApproved {
+		go notifyReplies(req.IDs)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Comments updated successfully",
+		"action":   req.Action,
+		"affected": affected,
+	})
+}
+
+// AddCommentNote コメントにモデレーター用のメモを追加（モデレーター）
+func AddCommentNote(c *gin.Context) {
+	var req struct {
+		Body string `json:"body" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Body) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var comment models.Comment
+	if err := db.First(&comment, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Comment not found",
+		})
+		return
+	}
+
+	note := models.CommentNote{
+		CommentID: comment.ID,
+		AuthorID:  currentUserID(c),
+		Body:      strings.TrimSpace(req.Body),
+	}
+	if err := db.Create(&note).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to add note",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Note added successfully",
+		"note":    note,
+	})
+}
+
+// setCommentStatus コメントのステータスを変更（Approved も合わせて更新する）
+// スパム・承認への変更はスパム判定の学習データにもなる
+func setCommentStatus(tx *gorm.DB, ids []uint, status string) (int64, error) {
+	if err := trainSpamFilter(tx, ids, status); err != nil {
+		return 0, err
+	}
+
+	result := tx.Model(&models.Comment{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{
+			"status":   status,
+			"approved": status == models.CommentStatusApproved,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// escapeLike LIKE のパターンで特別な意味を持つ文字をエスケープ
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
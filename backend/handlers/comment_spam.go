@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"blogapp/database"
+	"blogapp/internal/spam"
+	"blogapp/models"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// スパム判定ルールのスコア（合計が SPAM_THRESHOLD 以上ならスパム）
+const (
+	spamScoreHoneypot     = 10
+	spamScoreMissingToken = 2.5
+	spamScoreTooFast      = 4
+	spamScorePerLink      = 1.5
+	spamScorePerBlocked   = 3
+	spamScoreBayesMax     = 5
+)
+
+// GetCommentFormToken コメントフォーム用のトークンを発行（送信までの時間の確認に使う）
+func GetCommentFormToken(c *gin.Context) {
+	var post models.Post
+	if err := database.GetDB().First(&post, idParam(c, "id")).Error; err != nil || post.Status != models.PostStatusPublished {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Post not found",
+		})
+		return
+	}
+
+	cfg := getConfig()
+	c.JSON(http.StatusOK, gin.H{
+		"token":      spam.IssueToken([]byte(cfg.CommentTokenSecret), post.ID, time.Now()),
+		"expires_in": int(cfg.CommentTokenMaxAge.Seconds()),
+	})
+}
+
+// commentSpamPipeline 設定からコメントのスパム判定パイプラインを作成
+func commentSpamPipeline(db *gorm.DB) *spam.Pipeline {
+	cfg := getConfig()
+	return &spam.Pipeline{
+		Threshold: cfg.SpamThreshold,
+		Rules: []spam.Rule{
+			spam.Honeypot{Score: spamScoreHoneypot},
+			spam.SubmitTime{
+				Secret:       []byte(cfg.CommentTokenSecret),
+				MinAge:       cfg.CommentMinSubmitTime,
+				MaxAge:       cfg.CommentTokenMaxAge,
+				MissingScore: spamScoreMissingToken,
+				TooFastScore: spamScoreTooFast,
+			},
+			spam.LinkCount{Max: cfg.CommentMaxLinks, ScorePerLink: spamScorePerLink},
+			spam.Blocklist{Words: cfg.SpamBlocklist, ScorePerHit: spamScorePerBlocked},
+			spam.Bayes{
+				Store:      database.SpamTokenStore{DB: db},
+				MinTrained: int64(cfg.SpamBayesMinTrained),
+				MaxScore:   spamScoreBayesMax,
+			},
+		},
+	}
+}
+
+// trainSpamFilter モデレーターがスパム・承認にしたコメントを学習する
+// 以前と逆の判定で学習済みなら取り消してから学習し直す（同じ判定なら何もしない）
+func trainSpamFilter(tx *gorm.DB, ids []uint, status string) error {
+	var trainAs string
+	switch status {
+	case models.CommentStatusSpam:
+		trainAs = "spam"
+	case models.CommentStatusApproved:
+		trainAs = "ham"
+	default:
+		return nil
+	}
+
+	var comments []models.Comment
+	if err := tx.Select("id", "author", "content", "trained_as").
+		Where("id IN ? AND (trained_as IS NULL OR trained_as <> ?)", ids, trainAs).
+		Find(&comments).Error; err != nil {
+		return err
+	}
+
+	ctx := tx.Statement.Context
+	store := database.SpamTokenStore{DB: tx}
+	for _, comment := range comments {
+		if comment.TrainedAs != "" {
+			if err := spam.Unlearn(ctx, store, comment.Author, comment.Content, comment.TrainedAs == "spam"); err != nil {
+				return err
+			}
+		}
+		if err := spam.Learn(ctx, store, comment.Author, comment.Content, trainAs == "spam"); err != nil {
+			return err
+		}
+	}
+	if len(comments) == 0 {
+		return nil
+	}
+
+	trained := make([]uint, len(comments))
+	for i, comment := range comments {
+		trained[i] = comment.ID
+	}
+	return tx.Model(&models.Comment{}).Where("id IN ?", trained).UpdateColumn("trained_as", trainAs).Error
+}
+
+// truncateUTF8 バイト数の上限を超える文字列を文字の途中で切れないように切り詰める
+func truncateUTF8(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
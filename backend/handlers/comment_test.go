@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"blogapp/middleware"
+	"blogapp/models"
+	"blogapp/utils"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVisibleCommentStatuses(t *testing.T) {
+	tests := []struct {
+		moderator bool
+		status    string
+		want      []string
+		ok        bool
+	}{
+		{false, "", []string{models.CommentStatusApproved}, true},
+		{false, "pending", []string{models.CommentStatusApproved}, true},
+		{false, "all", []string{models.CommentStatusApproved}, true},
+		{false, "bogus", []string{models.CommentStatusApproved}, true},
+		{true, "", []string{models.CommentStatusPending, models.CommentStatusApproved}, true},
+		{true, "spam", []string{models.CommentStatusSpam}, true},
+		{true, "trash", []string{models.CommentStatusTrash}, true},
+		{true, "all", models.CommentStatuses(), true},
+		{true, "bogus", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := visibleCommentStatuses(tt.moderator, tt.status)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("visibleCommentStatuses(%v, %q) = %v, %v; want %v, %v", tt.moderator, tt.status, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// 公開のコメント一覧（OptionalAuth）でも、編集者・管理者のトークンならモデレーターとして扱う
+func TestCommentModeratorViewThroughOptionalAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	middleware.SetJWTSecret("secret")
+
+	router := gin.New()
+	router.GET("/posts/:id/comments", middleware.OptionalAuth(), func(c *gin.Context) {
+		statuses, ok := visibleCommentStatuses(isStaff(c), c.Query("status"))
+		if !ok {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.String(http.StatusOK, strings.Join(statuses, ","))
+	})
+
+	token := func(role string) string {
+		t.Helper()
+		token, err := utils.NewJWT("secret").GenerateToken("1", "user@example.com", role)
+		if err != nil {
+			t.Fatalf("GenerateToken error: %v", err)
+		}
+		return "Bearer " + token
+	}
+	tests := []struct {
+		name       string
+		auth       string
+		status     string
+		wantStatus int
+		want       string
+	}{
+		{"anonymous", "", "all", http.StatusOK, "approved"},
+		{"author", token(models.RoleAuthor), "all", http.StatusOK, "approved"},
+		{"editor default", token(models.RoleEditor), "", http.StatusOK, "pending,approved"},
+		{"editor spam", token(models.RoleEditor), "spam", http.StatusOK, "spam"},
+		{"admin all", token(models.RoleAdmin), "all", http.StatusOK, "pending,approved,spam,trash"},
+		{"admin invalid status", token(models.RoleAdmin), "bogus", http.StatusBadRequest, ""},
+		{"forged token", "Bearer dummy-jwt-token", "all", http.StatusOK, "approved"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/posts/1/comments?status="+tt.status, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus || w.Body.String() != tt.want {
+				t.Errorf("got %d %q, want %d %q", w.Code, w.Body.String(), tt.wantStatus, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"blogapp/models"
+	"time"
+)
+
+// deletedCommentText 削除された（または非公開になった）親コメントの代わりに表示する本文
+const deletedCommentText = "[deleted]"
+
+// commentView コメント一覧の1件（tree 形式では replies に返信が入る）
+type commentView struct {
+	ID        uint               `json:"id"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	PostID    uint               `json:"post_id"`
+	ParentID  *uint              `json:"parent_id"`
+	Depth     int                `json:"depth"`
+	Author    string             `json:"author"`
+	Email     string             `json:"email,omitempty"` // モデレーターのみ
+	Avatar    *models.AvatarHash `json:"avatar,omitempty"`
+	Content   string             `json:"content"`
+	Approved  bool               `json:"approved"`
+	Deleted   bool               `json:"deleted,omitempty"`
+
+	Replies []*commentView `json:"replies,omitempty"`
+}
+
+func newCommentView(comment *models.Comment) *commentView {
+	return &commentView{
+		ID:        comment.ID,
+		CreatedAt: comment.CreatedAt,
+		UpdatedAt: comment.UpdatedAt,
+		PostID:    comment.PostID,
+		ParentID:  comment.ParentID,
+		Depth:     comment.Depth,
+		Author:    comment.Author,
+		Email:     comment.Email,
+		Avatar:    models.NewAvatarHash(comment.Email),
+		Content:   comment.Content,
+		Approved:  comment.Approved,
+	}
+}
+
+// placeholderCommentView 表示できない親コメントの代わり（返信のつながりだけを残す）
+func placeholderCommentView(comment *models.Comment) *commentView {
+	return &commentView{
+		ID:        comment.ID,
+		CreatedAt: comment.CreatedAt,
+		UpdatedAt: comment.CreatedAt,
+		PostID:    comment.PostID,
+		ParentID:  comment.ParentID,
+		Depth:     comment.Depth,
+		Content:   deletedCommentText,
+		Deleted:   true,
+	}
+}
+
+// buildCommentThread 投稿のすべてのコメント（削除済みを含む）から表示用の一覧を作る
+// visible で表示するコメントを選び、表示するコメントの祖先で表示できないものは "[deleted]" に置き換えて残す。
+// 返り値は作成日時順のフラットな一覧と、トップレベルのコメントを根とするツリー
+func buildCommentThread(all []models.Comment, visible func(*models.Comment) bool) (flat, roots []*commentView) {
+	byID := make(map[uint]*models.Comment, len(all))
+	for i := range all {
+		byID[all[i].ID] = &all[i]
+	}
+
+	// 表示するコメントと、返信をつなぐために必要な祖先を集める
+	included := make(map[uint]bool, len(all))
+	for i := range all {
+		comment := &all[i]
+		if !visible(comment) {
+			continue
+		}
+		included[comment.ID] = true
+		for parentID := comment.ParentID; parentID != nil; {
+			parent, ok := byID[*parentID]
+			if !ok || included[parent.ID] {
+				break
+			}
+			included[parent.ID] = true
+			parentID = parent.ParentID
+		}
+	}
+
+	views := make(map[uint]*commentView, len(included))
+	for i := range all {
+		comment := &all[i]
+		if !included[comment.ID] {
+			continue
+		}
+		var view *commentView
+		if visible(comment) {
+			view = newCommentView(comment)
+		} else {
+			view = placeholderCommentView(comment)
+		}
+		views[comment.ID] = view
+		flat = append(flat, view)
+	}
+
+	for _, view := range flat {
+		if view.ParentID != nil {
+			if parent, ok := views[*view.ParentID]; ok {
+				parent.Replies = append(parent.Replies, view)
+				continue
+			}
+		}
+		roots = append(roots, view)
+	}
+	return flat, roots
+}
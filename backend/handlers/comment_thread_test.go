@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"blogapp/models"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func testComment(id uint, parentID uint, status string, deleted bool) models.Comment {
+	comment := models.Comment{
+		PostID:  1,
+		Author:  fmt.Sprintf("author%d", id),
+		Email:   fmt.Sprintf("author%d@example.com", id),
+		Content: fmt.Sprintf("comment %d", id),
+		Status:  status,
+	}
+	comment.ID = id
+	comment.CreatedAt = time.Unix(int64(id), 0)
+	if parentID != 0 {
+		comment.ParentID = &parentID
+	}
+	if deleted {
+		comment.DeletedAt = gorm.DeletedAt{Time: time.Unix(100, 0), Valid: true}
+	}
+	return comment
+}
+
+// describeThread ツリーを "1(2(3),4)" の形式で表す（"[deleted]" の代わりのコメントは "x1"）
+func describeThread(views []*commentView) string {
+	parts := make([]string, 0, len(views))
+	for _, view := range views {
+		s := fmt.Sprint(view.ID)
+		if view.Deleted {
+			s = "x" + s
+		}
+		if len(view.Replies) > 0 {
+			s += "(" + describeThread(view.Replies) + ")"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestBuildCommentThread(t *testing.T) {
+	approved := func(comment *models.Comment) bool {
+		return !comment.DeletedAt.Valid && comment.Status == models.CommentStatusApproved
+	}
+	const (
+		a = models.CommentStatusApproved
+		p = models.CommentStatusPending
+	)
+
+	tests := []struct {
+		name     string
+		comments []models.Comment
+		wantFlat []uint
+		wantTree string
+	}{
+		{"empty", nil, nil, ""},
+		{
+			"nested replies",
+			[]models.Comment{testComment(1, 0, a, false), testComment(2, 1, a, false), testComment(3, 2, a, false), testComment(4, 1, a, false), testComment(5, 0, a, false)},
+			[]uint{1, 2, 3, 4, 5},
+			"1(2(3),4),5",
+		},
+		{
+			"deleted parent with a reply becomes a placeholder",
+			[]models.Comment{testComment(1, 0, a, true), testComment(2, 1, a, false)},
+			[]uint{1, 2},
+			"x1(2)",
+		},
+		{
+			"deleted ancestors are kept up to the root",
+			[]models.Comment{testComment(1, 0, a, true), testComment(2, 1, a, true), testComment(3, 2, a, false)},
+			[]uint{1, 2, 3},
+			"x1(x2(3))",
+		},
+		{
+			"unapproved parent becomes a placeholder",
+			[]models.Comment{testComment(1, 0, p, false), testComment(2, 1, a, false)},
+			[]uint{1, 2},
+			"x1(2)",
+		},
+		{
+			"hidden comments without visible replies are dropped",
+			[]models.Comment{testComment(1, 0, a, false), testComment(2, 1, a, true), testComment(3, 1, p, false), testComment(4, 0, a, true)},
+			[]uint{1},
+			"1",
+		},
+		{
+			"hidden reply under a visible comment is dropped",
+			[]models.Comment{testComment(1, 0, a, false), testComment(2, 1, p, false), testComment(3, 2, p, false)},
+			[]uint{1},
+			"1",
+		},
+		// 親が読み込まれていない返信はトップレベルとして扱う
+		{
+			"orphaned reply",
+			[]models.Comment{testComment(2, 1, a, false), testComment(3, 2, a, false)},
+			[]uint{2, 3},
+			"2(3)",
+		},
+		// ページに含まれるスレッド（CommentThreads の結果）だけが根になる
+		{
+			"one page of threads",
+			[]models.Comment{testComment(5, 0, a, false), testComment(6, 5, a, false), testComment(7, 0, a, true), testComment(8, 7, a, false), testComment(9, 8, a, false)},
+			[]uint{5, 6, 7, 8, 9},
+			"5(6),x7(8(9))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flat, roots := buildCommentThread(tt.comments, approved)
+			var ids []uint
+			for _, view := range flat {
+				ids = append(ids, view.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantFlat) {
+				t.Errorf("flat = %v, want %v", ids, tt.wantFlat)
+			}
+			if got := describeThread(roots); got != tt.wantTree {
+				t.Errorf("tree = %q, want %q", got, tt.wantTree)
+			}
+		})
+	}
+}
+
+// "[deleted]" の代わりのコメントには投稿者・メールアドレス・本文を出さない
+func TestBuildCommentThreadPlaceholder(t *testing.T) {
+	comments := []models.Comment{testComment(1, 0, models.CommentStatusSpam, false), testComment(2, 1, models.CommentStatusApproved, false)}
+	flat, _ := buildCommentThread(comments, func(comment *models.Comment) bool {
+		return comment.Status == models.CommentStatusApproved
+	})
+	if len(flat) != 2 {
+		t.Fatalf("len(flat) = %d, want 2", len(flat))
+	}
+
+	placeholder := flat[0]
+	want := commentView{
+		ID:        1,
+		CreatedAt: comments[0].CreatedAt,
+		UpdatedAt: comments[0].CreatedAt,
+		PostID:    1,
+		Content:   deletedCommentText,
+		Deleted:   true,
+		Replies:   []*commentView{flat[1]},
+	}
+	if !reflect.DeepEqual(*placeholder, want) {
+		t.Errorf("placeholder = %+v, want %+v", *placeholder, want)
+	}
+	if reply := flat[1]; reply.Deleted || reply.Content != "comment 2" || reply.Author != "author2" || reply.Avatar == nil {
+		t.Errorf("reply = %+v, want the visible comment", *reply)
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"blogapp/models"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentUserID 認証ミドルウェアがセットしたユーザーIDを取得（未認証なら 0）
+func currentUserID(c *gin.Context) uint {
+	value, exists := c.Get("user_id")
+	if !exists {
+		return 0
+	}
+
+	id, err := strconv.ParseUint(fmt.Sprint(value), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
+// currentUserRole 認証ミドルウェアがセットしたロールを取得（未認証なら空文字）
+func currentUserRole(c *gin.Context) string {
+	return c.GetString("user_role")
+}
+
+// isStaff 編集者または管理者かどうか
+func isStaff(c *gin.Context) bool {
+	return models.IsStaffRole(currentUserRole(c))
+}
+
+// parseIDParam URLパラメータを ID として解釈
+func parseIDParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// parseID 文字列を ID として解釈（不正な値は 0）
+// GORM は数値でない文字列の条件を SQL として扱うため、ID は必ずこれを通してから渡す
+func parseID(s string) uint {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
+// idParam URLパラメータの ID（不正な値は 0 になり、検索しても見つからない）
+func idParam(c *gin.Context, name string) uint {
+	return parseID(c.Param(name))
+}
+
+// optionalID 0 を未指定（nil）として扱う
+func optionalID(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// parsePagination page / per_page クエリを解釈
+func parsePagination(c *gin.Context) (page, perPage int) {
+	page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ = strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+	return page, perPage
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"blogapp/database"
+	"blogapp/internal/feed"
+	"blogapp/internal/markdown"
+	"blogapp/models"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// フィードをキャッシュさせる秒数（それ以降は ETag / Last-Modified で確認させる）
+const feedMaxAge = 300
+
+// 抜粋のない投稿の要約の文字数
+const feedSummaryLength = 200
+
+type feedFormat struct {
+	contentType string
+	render      func(*feed.Feed) ([]byte, error)
+}
+
+var (
+	feedRSS  = feedFormat{"application/rss+xml; charset=utf-8", (*feed.Feed).RSS}
+	feedAtom = feedFormat{"application/atom+xml; charset=utf-8", (*feed.Feed).Atom}
+	feedJSON = feedFormat{"application/feed+json; charset=utf-8", (*feed.Feed).JSON}
+
+	// 本文中のサイト内の URL（フィードリーダーでは相対 URL を解決できないことがある）
+	relativeURLAttr = regexp.MustCompile(`(src|href)="(/[^"]*)"`)
+)
+
+// RSSFeed 公開済みの投稿の RSS 2.0（/feed.xml、/categories/:category/feed.xml など）
+func RSSFeed(c *gin.Context) {
+	serveFeed(c, feedRSS)
+}
+
+// AtomFeed 公開済みの投稿の Atom 1.0（/atom.xml、/categories/:category/atom.xml など）
+func AtomFeed(c *gin.Context) {
+	serveFeed(c, feedAtom)
+}
+
+// JSONFeed 公開済みの投稿の JSON Feed 1.1（/feed.json、/categories/:category/feed.json など）
+func JSONFeed(c *gin.Context) {
+	serveFeed(c, feedJSON)
+}
+
+// serveFeed 新しい順に FEED_ITEMS 件の投稿をフィードにして返す
+// パスの :category（子孫カテゴリーを含む）・:tag・:author（ユーザー名）で絞り込み、
+// 本文は FEED_CONTENT が excerpt なら抜粋のみにする。If-None-Match / If-Modified-Since には 304 を返す
+func serveFeed(c *gin.Context, format feedFormat) {
+	cfg := getConfig()
+	db := database.GetDB()
+
+	query := db.Model(&models.Post{}).Where("status = ?", models.PostStatusPublished)
+	title, link := cfg.FeedTitle, cfg.SiteURL
+	switch {
+	case c.Param("category") != "":
+		var category models.Category
+		if err := db.Where("slug = ?", c.Param("category")).First(&category).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Category not found",
+			})
+			return
+		}
+		ids, err := database.CategoryDescendantIDs(db, category.ID)
+		if err != nil || len(ids) == 0 {
+			ids = []uint{category.ID}
+		}
+		query = query.Where("category_id IN ?", ids)
+		title = cfg.FeedTitle + " - " + category.Name
+		link = cfg.SiteURL + "/category/" + url.PathEscape(category.Slug)
+	case c.Param("tag") != "":
+		var tag models.Tag
+		if err := db.Where("slug = ?", c.Param("tag")).First(&tag).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Tag not found",
+			})
+			return
+		}
+		query = query.Where("id IN (?)", db.Table("post_tags").Select("post_id").Where("tag_id = ?", tag.ID))
+		title = cfg.FeedTitle + " - " + tag.Name
+	case c.Param("author") != "":
+		var author models.User
+		if err := db.Where("username = ?", c.Param("author")).First(&author).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Author not found",
+			})
+			return
+		}
+		query = query.Where("author_id = ?", author.ID)
+		title = cfg.FeedTitle + " - " + displayName(&author)
+	}
+
+	limit := cfg.FeedItems
+	if limit <= 0 {
+		limit = 20
+	}
+	var posts []models.Post
+	if err := query.Preload("Author").Preload("Category").Preload("Tags").
+		Order("published_at DESC NULLS LAST").Order("id DESC").
+		Limit(limit).
+		Find(&posts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch posts",
+		})
+		return
+	}
+
+	enclosures, err := feedEnclosures(db, posts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch posts",
+		})
+		return
+	}
+
+	f := &feed.Feed{
+		Title:       title,
+		Description: cfg.FeedDescription,
+		Link:        link,
+		FeedURL:     cfg.APIBaseURL + c.Request.URL.Path,
+		Language:    cfg.FeedLanguage,
+		Items:       make([]feed.Item, 0, len(posts)),
+	}
+	for i := range posts {
+		post := &posts[i]
+		ensureRendered(post)
+		if post.UpdatedAt.After(f.Updated) {
+			f.Updated = post.UpdatedAt
+		}
+		f.Items = append(f.Items, feedItem(post, enclosures[post.ID], cfg.FeedFullContent))
+	}
+
+	body, err := format.render(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to render feed",
+		})
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	c.Header("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", feedMaxAge))
+	c.Header("Content-Type", format.contentType)
+	http.ServeContent(c.Writer, c.Request, "", f.Updated, bytes.NewReader(body))
+}
+
+// feedItem 投稿をフィードの1件にする
+// ID は slug を変えても変わらないよう投稿の ID から作る（tag: URI）
+func feedItem(post *models.Post, enclosure *feed.Enclosure, fullContent bool) feed.Item {
+	published := post.CreatedAt
+	if post.PublishedAt != nil {
+		published = *post.PublishedAt
+	}
+
+	item := feed.Item{
+		ID:        postTagURI(post),
+		Title:     post.Title,
+		Link:      postURL(post),
+		Summary:   strings.TrimSpace(post.Excerpt),
+		Author:    displayName(&post.Author),
+		Published: published,
+		Updated:   post.UpdatedAt,
+		Enclosure: enclosure,
+	}
+	if item.Summary == "" {
+		item.Summary = markdown.Excerpt(post.ContentHTML, feedSummaryLength)
+	}
+	if fullContent {
+		item.Content = absoluteURLs(post.ContentHTML)
+	}
+	if post.Category != nil {
+		item.Categories = append(item.Categories, post.Category.Name)
+	}
+	for _, tag := range post.Tags {
+		item.Categories = append(item.Categories, tag.Name)
+	}
+	return item
+}
+
+// postTagURI 投稿の変わらない識別子（tag:example.com,2024-05-01:posts/42）
+func postTagURI(post *models.Post) string {
+	host := getConfig().SiteURL
+	if u, err := url.Parse(host); err == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+	return fmt.Sprintf("tag:%s,%s:posts/%d", host, post.CreatedAt.UTC().Format("2006-01-02"), post.ID)
+}
+
+// feedEnclosures アイキャッチ画像（Post.ImageURL）を添付ファイルにする（投稿の ID → 添付ファイル）
+// メディアライブラリのファイルなら種類と大きさを付け、それ以外は拡張子から種類を推測する
+func feedEnclosures(db *gorm.DB, posts []models.Post) (map[uint]*feed.Enclosure, error) {
+	var keys []string
+	for _, post := range posts {
+		keys = append(keys, models.MediaKeysIn(post.ImageURL)...)
+	}
+
+	type fileInfo struct {
+		MIME string
+		Size int64
+	}
+	files := map[string]fileInfo{}
+	if len(keys) > 0 {
+		var media []models.Media
+		if err := db.Where("key IN ?", keys).Find(&media).Error; err != nil {
+			return nil, err
+		}
+		for _, m := range media {
+			files[m.Key] = fileInfo{m.MIME, m.Size}
+		}
+		var variants []models.MediaVariant
+		if err := db.Where("key IN ?", keys).Find(&variants).Error; err != nil {
+			return nil, err
+		}
+		for _, v := range variants {
+			files[v.Key] = fileInfo{v.MIME, v.Size}
+		}
+	}
+
+	enclosures := make(map[uint]*feed.Enclosure, len(posts))
+	for _, post := range posts {
+		if post.ImageURL == "" {
+			continue
+		}
+		enclosure := &feed.Enclosure{URL: absoluteURL(post.ImageURL)}
+		if keys := models.MediaKeysIn(post.ImageURL); len(keys) > 0 {
+			if file, ok := files[keys[0]]; ok {
+				enclosure.Type, enclosure.Length = file.MIME, file.Size
+			}
+		}
+		if enclosure.Type == "" {
+			if u, err := url.Parse(post.ImageURL); err == nil {
+				enclosure.Type, _, _ = mime.ParseMediaType(mime.TypeByExtension(path.Ext(u.Path)))
+			}
+		}
+		if enclosure.Type == "" {
+			enclosure.Type = "application/octet-stream"
+		}
+		enclosures[post.ID] = enclosure
+	}
+	return enclosures, nil
+}
+
+// absoluteURL サイト内の URL を絶対 URL にする（アップロードしたファイルと画像の変換はこの API サーバー、それ以外はフロントエンド）
+func absoluteURL(rawURL string) string {
+	switch {
+	case !strings.HasPrefix(rawURL, "/"), strings.HasPrefix(rawURL, "//"):
+		return rawURL
+	case strings.HasPrefix(rawURL, "/uploads/"), strings.HasPrefix(rawURL, "/img/"):
+		return getConfig().APIBaseURL + rawURL
+	}
+	return getConfig().SiteURL + rawURL
+}
+
+// absoluteURLs 本文の HTML の src / href のサイト内の URL を絶対 URL にする
+func absoluteURLs(html string) string {
+	return relativeURLAttr.ReplaceAllStringFunc(html, func(attr string) string {
+		match := relativeURLAttr.FindStringSubmatch(attr)
+		return match[1] + `="` + absoluteURL(match[2]) + `"`
+	})
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"blogapp/database"
+	"blogapp/internal/storage"
+	"blogapp/internal/upload"
+	"blogapp/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 署名付き URL の有効期間の上限
+const maxUploadSignedURLTTL = 7 * 24 * time.Hour
+
+// ServeUpload アップロードしたファイルを返す（/uploads/*path）
+// Range リクエスト（PDF や動画のシーク）に応じ、公開された投稿に使われているメディアだけ長期間キャッシュさせる。
+// 公開していない投稿だけに使われているメディアは、SignMediaURL で発行した期限付きの URL でしか返さない
+func ServeUpload(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("path"), "/")
+	if !models.IsMediaKey(key) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "File not found",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	media, variant, err := database.FindMediaByKey(db, key)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "File not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch file",
+		})
+		return
+	}
+
+	cacheControl, allowed, err := mediaCacheControl(c, db, media.ID, key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch file",
+		})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "A valid signed URL is required",
+		})
+		return
+	}
+
+	store, err := getStorage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Storage is not available",
+		})
+		return
+	}
+	f, info, err := store.Open(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "File not found",
+			})
+			return
+		}
+		log.Printf("Failed to open media file %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch file",
+		})
+		return
+	}
+	defer f.Close()
+
+	contentType, filename := media.MIME, media.OriginalName
+	if filename == "" {
+		filename = media.Filename
+	}
+	if variant != nil {
+		contentType, filename = variant.MIME, variantFilename(filename, variant)
+	}
+
+	// キーは中身から作るので、キーが同じなら中身も同じ（強い ETag にできる）
+	sum := sha256.Sum256([]byte(key))
+	c.Header("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	c.Header("Cache-Control", cacheControl)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", contentDisposition(contentType, filename, c.Query("download") == "1"))
+	c.Header("X-Content-Type-Options", "nosniff")
+	// SVG などをブラウザで直接開いてもスクリプトを動かさない
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	http.ServeContent(c.Writer, c.Request, "", info.ModTime, f)
+}
+
+// SignMediaURL メディア（と縮小画像）の期限付きの URL を発行（アップロードした人、メディアを使っている投稿の著者、編集者・管理者）
+// expires_in は秒数（省略時は UPLOAD_SIGNED_URL_TTL、最大 7 日）
+func SignMediaURL(c *gin.Context) {
+	var req struct {
+		ExpiresIn int64 `json:"expires_in"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+	ttl := getConfig().UploadSignedURLTTL
+	if req.ExpiresIn != 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if ttl <= 0 || ttl > maxUploadSignedURLTTL {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("expires_in must be between 1 and %d seconds", int(maxUploadSignedURLTTL.Seconds())),
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var media models.Media
+	if err := db.Preload("Variants", orderMediaVariants).First(&media, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Media not found",
+		})
+		return
+	}
+	if media.UploaderID != currentUserID(c) && !isStaff(c) {
+		var authored int64
+		if err := db.Table("post_media").
+			Joins("JOIN posts ON posts.id = post_media.post_id").
+			Where("post_media.media_id = ? AND posts.author_id = ?", media.ID, currentUserID(c)).
+			Count(&authored).Error; err != nil || authored == 0 {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "You are not allowed to access this media",
+			})
+			return
+		}
+	}
+
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	variants := map[string]string{}
+	for _, variant := range media.Variants {
+		variants[variant.Name] = signedUploadURL(variant.Key, expiresAt)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":        signedUploadURL(media.Key, expiresAt),
+		"variants":   variants,
+		"expires_at": expiresAt,
+	})
+}
+
+// signedUploadURL このサーバーの /uploads を通る期限付きの URL
+// 保存先の公開 URL（S3 や CDN）は署名を確かめないので使わない
+func signedUploadURL(key string, expiresAt time.Time) string {
+	cfg := getConfig()
+	return storage.SignedURL(cfg.APIBaseURL+"/uploads/"+key, []byte(cfg.UploadURLSecret), key, expiresAt)
+}
+
+// mediaCacheControl メディアを返してよいかと、返すときの Cache-Control
+func mediaCacheControl(c *gin.Context, db *gorm.DB, mediaID uint, signedKey string) (string, bool, error) {
+	visibility, err := database.GetMediaVisibility(db, mediaID)
+	if err != nil {
+		return "", false, err
+	}
+	return cacheControlFor(visibility, c.Query("expires"), c.Query("sig"), signedKey, time.Now())
+}
+
+// cacheControlFor 公開された投稿に使われているメディアだけを長期間キャッシュさせる。
+// 公開していない投稿だけに使われているメディアは signedKey に対する期限付きの署名が正しい場合だけ返し、
+// 期限が過ぎたら共有キャッシュからも返さないようにする。
+// どの投稿にも使われていないメディアは、後で下書きに使われても古いキャッシュから返されないように毎回確かめさせる
+func cacheControlFor(visibility database.MediaVisibility, expires, sig, signedKey string, now time.Time) (string, bool, error) {
+	switch visibility {
+	case database.MediaPublished:
+		return "public, max-age=31536000, immutable", true, nil
+	case database.MediaRestricted:
+		expiresAt, ok := storage.VerifySignedKey([]byte(getConfig().UploadURLSecret), signedKey, expires, sig, now)
+		if !ok {
+			return "", false, nil
+		}
+		return fmt.Sprintf("private, max-age=%d", int(expiresAt.Sub(now).Seconds())), true, nil
+	}
+	return "private, no-cache", true, nil
+}
+
+// contentDisposition ブラウザで表示できる種類は inline、それ以外（と download=1）は attachment にする
+// ファイル名は RFC 6266 の形式（ASCII 以外は filename* で送る）
+func contentDisposition(contentType, filename string, download bool) string {
+	disposition := "attachment"
+	if !download && (strings.HasPrefix(contentType, "image/") || strings.HasPrefix(contentType, "video/") || contentType == "application/pdf") {
+		disposition = "inline"
+	}
+	if value := mime.FormatMediaType(disposition, map[string]string{"filename": filename}); value != "" {
+		return value
+	}
+	return disposition
+}
+
+// variantFilename 縮小画像のファイル名（元のファイル名に縮小画像の名前と拡張子を付ける）
+func variantFilename(filename string, variant *models.MediaVariant) string {
+	ext := ""
+	if exts, ok := upload.Types[variant.MIME]; ok {
+		ext = exts[0]
+	}
+	base := strings.TrimSuffix(filename, path.Ext(filename))
+	return base + "-" + variant.Name + ext
+}
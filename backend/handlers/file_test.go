@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"blogapp/config"
+	"blogapp/database"
+	"blogapp/internal/storage"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCacheControlFor(t *testing.T) {
+	saved := appConfig
+	t.Cleanup(func() { appConfig = saved })
+	appConfig = &config.Config{UploadURLSecret: "secret"}
+
+	const key = "2024/05/0123456789abcdef.jpg"
+	now := time.Unix(1700000000, 0)
+	expiresAt := now.Add(time.Hour)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	sig := storage.SignKey([]byte("secret"), key, expiresAt)
+
+	tests := []struct {
+		name         string
+		visibility   database.MediaVisibility
+		expires, sig string
+		want         string
+		allowed      bool
+	}{
+		{"published", database.MediaPublished, "", "", "public, max-age=31536000, immutable", true},
+		{"published with signature", database.MediaPublished, expires, sig, "public, max-age=31536000, immutable", true},
+		// 後で下書きに使われるかもしれないので、長期間キャッシュさせない
+		{"unreferenced", database.MediaUnreferenced, "", "", "private, no-cache", true},
+		{"unreferenced with signature", database.MediaUnreferenced, expires, sig, "private, no-cache", true},
+		{"restricted without signature", database.MediaRestricted, "", "", "", false},
+		{"restricted with signature", database.MediaRestricted, expires, sig, "private, max-age=3600", true},
+		{"restricted with other key", database.MediaRestricted, expires, storage.SignKey([]byte("secret"), "other", expiresAt), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, allowed, err := cacheControlFor(tt.visibility, tt.expires, tt.sig, key, now)
+			if err != nil || got != tt.want || allowed != tt.allowed {
+				t.Errorf("cacheControlFor = %q, %v, %v; want %q, %v", got, allowed, err, tt.want, tt.allowed)
+			}
+		})
+	}
+}
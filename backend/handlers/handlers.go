@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"blogapp/config"
+	"blogapp/internal/imagecache"
+	"blogapp/internal/scan"
+	"blogapp/internal/storage"
+	"context"
+	"log"
+	"sync"
+)
+
+var (
+	appConfig     *config.Config
+	appStorage    storage.Storage
+	appImageCache *imagecache.Cache
+	appScanner    scan.Scanner
+
+	// 未設定の場合に一度だけ作成する（同時に届いたリクエストで二重に作らない）
+	storageOnce    sync.Once
+	storageErr     error
+	imageCacheOnce sync.Once
+	imageCacheErr  error
+	scannerOnce    sync.Once
+)
+
+// SetConfig ハンドラーが参照する設定をセット（サーバー起動時に呼ぶ）
+func SetConfig(cfg *config.Config) {
+	appConfig = cfg
+}
+
+// getConfig 設定を取得（未設定の場合は環境変数から読み込む）
+func getConfig() *config.Config {
+	if appConfig == nil {
+		appConfig = config.Load()
+	}
+	return appConfig
+}
+
+// SetStorage アップロードしたファイルの保存先をセット（サーバー起動時に呼ぶ）
+func SetStorage(s storage.Storage) {
+	appStorage = s
+}
+
+// getStorage 保存先を取得（未設定の場合は設定から作成する）
+func getStorage() (storage.Storage, error) {
+	storageOnce.Do(func() {
+		if appStorage != nil {
+			return
+		}
+		appStorage, storageErr = storage.New(context.Background(), getConfig().StorageOptions())
+		if storageErr != nil {
+			log.Printf("Failed to initialize storage: %v", storageErr)
+		}
+	})
+	return appStorage, storageErr
+}
+
+// SetImageCache 変換した画像のキャッシュをセット（サーバー起動時に呼ぶ）
+func SetImageCache(cache *imagecache.Cache) {
+	appImageCache = cache
+}
+
+// getImageCache 変換した画像のキャッシュを取得（未設定の場合は設定から作成する）
+func getImageCache() (*imagecache.Cache, error) {
+	imageCacheOnce.Do(func() {
+		if appImageCache != nil {
+			return
+		}
+		cfg := getConfig()
+		appImageCache, imageCacheErr = imagecache.New(cfg.ImageCacheDir, cfg.ImageCacheSize)
+		if imageCacheErr != nil {
+			log.Printf("Failed to initialize image cache: %v", imageCacheErr)
+		}
+	})
+	return appImageCache, imageCacheErr
+}
+
+// SetScanner アップロードしたファイルのマルウェア検査をセット（サーバー起動時に呼ぶ、nil なら検査しない）
+func SetScanner(scanner scan.Scanner) {
+	appScanner = scanner
+}
+
+// getScanner マルウェア検査を取得（未設定の場合は CLAMD_ADDRESS から作成し、空なら nil）
+func getScanner() scan.Scanner {
+	scannerOnce.Do(func() {
+		cfg := getConfig()
+		if appScanner != nil || cfg.ClamdAddress == "" {
+			return
+		}
+		scanner, err := scan.NewClamd(cfg.ClamdAddress, cfg.ClamdTimeout)
+		if err != nil {
+			log.Printf("Failed to initialize malware scanner: %v", err)
+			return
+		}
+		appScanner = scanner
+	})
+	return appScanner
+}
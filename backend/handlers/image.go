@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"blogapp/database"
+	"blogapp/internal/imageproc"
+	"blogapp/internal/storage"
+	"blogapp/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	errNotImage          = errors.New("not an image")
+	errImageSourceTooBig = errors.New("image source too large")
+
+	// 同時に変換する数の制限（IMAGE_CONCURRENCY）
+	imageSlots     chan struct{}
+	imageSlotsOnce sync.Once
+)
+
+// ServeImage 署名付きの URL で指定された大きさ・切り抜きに変換した画像を返す（/img/:sig/:params/*path）
+// パラメーターは w（幅）, h（高さ）, fit（contain / cover / fill）, f（jpeg / png、エンコーダーを登録した場合は webp）, q（品質）。
+// 変換した画像はディスクにキャッシュし、公開された投稿の画像だけ長期間キャッシュさせる（mediaCacheControl）。
+// 公開していない投稿だけに使われている画像は、/uploads と同じく期限付きの署名（?expires=&sig=）がある場合だけ返す
+func ServeImage(c *gin.Context) {
+	cfg := getConfig()
+	rawParams := c.Param("params")
+	key := strings.TrimPrefix(c.Param("path"), "/")
+
+	if !imageproc.VerifySignature([]byte(cfg.ImageURLSecret), c.Param("sig"), rawParams, key) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Invalid signature",
+		})
+		return
+	}
+	params, err := imageproc.ParseParams(rawParams, cfg.ImageSizes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid image parameters",
+		})
+		return
+	}
+	if !models.IsMediaKey(key) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Image not found",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	media, _, err := database.FindMediaByKey(db, key)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Image not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to process image",
+		})
+		return
+	}
+	cacheControl, allowed, err := mediaCacheControl(c, db, media.ID, params.String()+"/"+key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to process image",
+		})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "A valid signed URL is required",
+		})
+		return
+	}
+
+	sum := sha256.Sum256([]byte(key + "/" + params.String()))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if match := c.GetHeader("If-None-Match"); match != "" && (match == "*" || strings.Contains(match, etag)) {
+		c.Header("ETag", etag)
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	store, err := getStorage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Storage is not available",
+		})
+		return
+	}
+	cache, err := getImageCache()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Image cache is not available",
+		})
+		return
+	}
+
+	// 同じ画像を同時に要求した他のリクエストと変換を共有するので、接続が切れても変換は続ける
+	ctx := context.WithoutCancel(c.Request.Context())
+	f, err := cache.Open(key, params.String(), func() ([]byte, error) {
+		return renderImage(ctx, store, key, params)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotExist), errors.Is(err, errNotImage):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Image not found",
+			})
+		case errors.Is(err, imageproc.ErrTooLarge), errors.Is(err, errImageSourceTooBig):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": "Image is too large to process",
+			})
+		default:
+			log.Printf("Failed to process image %s (%s): %v", key, params, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to process image",
+			})
+		}
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to process image",
+		})
+		return
+	}
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", cacheControl)
+	c.Header("X-Content-Type-Options", "nosniff")
+	// Content-Type は中身から判定される（キャッシュのファイル名には拡張子がない）
+	http.ServeContent(c.Writer, c.Request, "", info.ModTime(), f)
+}
+
+// SignImageURL 画像の変換 URL に署名して返す（認証必要、アップロードしたユーザーと編集者・管理者のみ）
+// 幅・高さは IMAGE_SIZES のものだけを受け付け、1つの画像から作られる変換の数を限る。
+// 公開していない投稿だけに使われている画像は、UPLOAD_SIGNED_URL_TTL までの期限を付ける
+func SignImageURL(c *gin.Context) {
+	var req struct {
+		Key     string `json:"key" binding:"required"` // メディアまたは縮小画像のキー
+		Width   int    `json:"w"`
+		Height  int    `json:"h"`
+		Fit     string `json:"fit"`
+		Format  string `json:"f"`
+		Quality int    `json:"q"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+	if !models.IsMediaKey(req.Key) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Unknown media key",
+		})
+		return
+	}
+
+	media, _, err := database.FindMediaByKey(database.GetDB(), req.Key)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Media not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch media",
+		})
+		return
+	}
+	if media.UploaderID != currentUserID(c) && !isStaff(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "You can only sign URLs for your own media",
+		})
+		return
+	}
+
+	// 正規化した文字列を解析し直して範囲を確かめる
+	cfg := getConfig()
+	params, err := imageproc.ParseParams(imageproc.Params{
+		Width:   req.Width,
+		Height:  req.Height,
+		Fit:     req.Fit,
+		Format:  req.Format,
+		Quality: req.Quality,
+	}.String(), cfg.ImageSizes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid image parameters",
+		})
+		return
+	}
+
+	visibility, err := database.GetMediaVisibility(database.GetDB(), media.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch media",
+		})
+		return
+	}
+	if visibility == database.MediaRestricted {
+		expiresAt := time.Now().Add(cfg.UploadSignedURLTTL).Truncate(time.Second)
+		c.JSON(http.StatusOK, gin.H{
+			"url":        storage.SignedURL(imageURL(params, req.Key), []byte(cfg.UploadURLSecret), params.String()+"/"+req.Key, expiresAt),
+			"params":     params.String(),
+			"expires_at": expiresAt,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":    imageURL(params, req.Key),
+		"params": params.String(),
+	})
+}
+
+// imageURL 変換した画像の署名付き URL
+func imageURL(params imageproc.Params, key string) string {
+	cfg := getConfig()
+	signature := imageproc.Sign([]byte(cfg.ImageURLSecret), params.String(), key)
+	return cfg.APIBaseURL + "/img/" + signature + "/" + params.String() + "/" + key
+}
+
+// renderImage 保存先から元の画像を読み込んで変換する
+// 展開後の画素数（IMAGE_MAX_PIXELS）とファイルの大きさ（IMAGE_MAX_SOURCE_SIZE）で、展開するとメモリを使い切る画像を防ぐ
+func renderImage(ctx context.Context, store storage.Storage, key string, params imageproc.Params) ([]byte, error) {
+	cfg := getConfig()
+	imageSlotsOnce.Do(func() {
+		imageSlots = make(chan struct{}, max(1, cfg.ImageConcurrency))
+	})
+	imageSlots <- struct{}{}
+	defer func() { <-imageSlots }()
+
+	src, info, err := store.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	if info.Size > cfg.ImageMaxSourceSize {
+		return nil, errImageSourceTooBig
+	}
+	data, err := io.ReadAll(io.LimitReader(src, cfg.ImageMaxSourceSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > cfg.ImageMaxSourceSize {
+		return nil, errImageSourceTooBig
+	}
+
+	// 保存先の Content-Type ではなく中身で判定する
+	mimeType := http.DetectContentType(data)
+	if !imageproc.Supports(mimeType) {
+		return nil, errNotImage
+	}
+	image, err := imageproc.Transform(data, mimeType, params, cfg.ImageOptions())
+	if err != nil {
+		return nil, err
+	}
+	return image.Data, nil
+}
+
+// purgeImageCache 削除したファイルから変換した画像をキャッシュから消す
+func purgeImageCache(keys ...string) {
+	cache, err := getImageCache()
+	if err != nil {
+		return
+	}
+	for _, key := range keys {
+		if err := cache.RemoveSource(key); err != nil {
+			log.Printf("Failed to purge image cache for %s: %v", key, err)
+		}
+	}
+}
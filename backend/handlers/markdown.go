@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"blogapp/database"
+	"blogapp/internal/markdown"
+	"blogapp/models"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PreviewMarkdown 保存前の本文をレンダリングしてプレビュー用に返す
+func PreviewMarkdown(c *gin.Context) {
+	var req struct {
+		Content string `json:"content"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	result, err := markdown.Render(req.Content)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "Failed to render markdown",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"html": result.HTML,
+		"toc":  result.TOC,
+	})
+}
+
+// GetHighlightCSS コードブロックのシンタックスハイライト用 CSS を返す
+func GetHighlightCSS(c *gin.Context) {
+	css, err := markdown.HighlightCSS()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate stylesheet",
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "text/css; charset=utf-8", []byte(css))
+}
+
+// ensureRendered 古いレンダラーでキャッシュされた投稿を再レンダリングして保存
+func ensureRendered(post *models.Post) {
+	if !post.NeedsRender() {
+		return
+	}
+	if err := post.Render(); err != nil {
+		log.Printf("Failed to render post %d: %v", post.ID, err)
+		return
+	}
+
+	if err := database.GetDB().Model(post).UpdateColumns(map[string]interface{}{
+		"content_html":   post.ContentHTML,
+		"toc":            post.TOC,
+		"render_version": post.RenderVersion,
+	}).Error; err != nil {
+		log.Printf("Failed to cache rendered post %d: %v", post.ID, err)
+	}
+}
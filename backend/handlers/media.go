@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"blogapp/database"
+	"blogapp/internal/storage"
+	"blogapp/models"
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListMedia メディアライブラリの一覧（新しい順）
+// ?q= でファイル名・代替テキスト・キャプションを検索、?type=image または image/png で種類、
+// ?uploader_id= または ?mine=true でアップロードした人、?unused=true で投稿から参照されていないものに絞り込む
+func ListMedia(c *gin.Context) {
+	db := database.GetDB()
+	query := db.Model(&models.Media{})
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + escapeLike(q) + "%"
+		query = query.Where("original_name ILIKE ? OR alt_text ILIKE ? OR caption ILIKE ?", pattern, pattern, pattern)
+	}
+	if mimeType := c.Query("type"); mimeType != "" {
+		if strings.Contains(mimeType, "/") {
+			query = query.Where("mime = ?", mimeType)
+		} else {
+			query = query.Where("mime LIKE ?", escapeLike(mimeType)+"/%")
+		}
+	}
+	if c.Query("mine") == "true" {
+		query = query.Where("uploader_id = ?", currentUserID(c))
+	} else if uploaderID := c.Query("uploader_id"); uploaderID != "" {
+		query = query.Where("uploader_id = ?", parseID(uploaderID))
+	}
+	if c.Query("unused") == "true" {
+		query = query.Where("NOT EXISTS (SELECT 1 FROM post_media WHERE post_media.media_id = media.id)")
+	}
+
+	page, perPage := parsePagination(c)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch media",
+		})
+		return
+	}
+
+	var media []models.Media
+	if err := query.Preload("Uploader").Preload("Variants", orderMediaVariants).
+		Order("created_at DESC, id DESC").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Find(&media).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch media",
+		})
+		return
+	}
+	urls := make([]*models.Media, len(media))
+	for i := range media {
+		urls[i] = &media[i]
+	}
+	if err := setMediaURLs(urls...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Storage is not available",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"media":    media,
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+	})
+}
+
+// GetMedia メディアの詳細（参照している投稿つき）
+func GetMedia(c *gin.Context) {
+	var media models.Media
+	if err := database.GetDB().
+		Preload("Uploader").
+		Preload("Variants", orderMediaVariants).
+		Preload("Posts", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "title", "slug", "status", "author_id")
+		}).
+		First(&media, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Media not found",
+		})
+		return
+	}
+	if err := setMediaURLs(&media); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Storage is not available",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"media": media,
+	})
+}
+
+// UpdateMedia 代替テキストとキャプションを更新（アップロードした人または編集者・管理者）
+func UpdateMedia(c *gin.Context) {
+	var req struct {
+		AltText *string `json:"alt_text"`
+		Caption *string `json:"caption"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	media, ok := loadEditableMedia(c, db)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.AltText != nil {
+		updates["alt_text"] = truncateUTF8(strings.TrimSpace(*req.AltText), 500)
+	}
+	if req.Caption != nil {
+		updates["caption"] = strings.TrimSpace(*req.Caption)
+	}
+	if len(updates) > 0 {
+		if err := db.Model(media).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update media",
+			})
+			return
+		}
+	}
+	if err := db.Preload("Variants", orderMediaVariants).First(media, media.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update media",
+		})
+		return
+	}
+	if err := setMediaURLs(media); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Storage is not available",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Media updated successfully",
+		"media":   media,
+	})
+}
+
+// DeleteMedia メディアとファイルを削除（アップロードした人または編集者・管理者）
+// 投稿から参照されている場合は ?force=true がなければ 409 を返す
+func DeleteMedia(c *gin.Context) {
+	db := database.GetDB()
+	media, ok := loadEditableMedia(c, db)
+	if !ok {
+		return
+	}
+
+	postCount := db.Model(media).Association("Posts").Count()
+	if postCount > 0 && c.Query("force") != "true" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      "Media is used by posts; pass force=true to delete it anyway",
+			"post_count": postCount,
+		})
+		return
+	}
+
+	store, err := getStorage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Storage is not available",
+		})
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return deleteMedia(c.Request.Context(), tx, store, media)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete media",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Media deleted successfully",
+		"id":      media.ID,
+	})
+}
+
+// loadEditableMedia パスの ID のメディアを取得し、変更できるか確認する（できなければレスポンスを書いて false）
+func loadEditableMedia(c *gin.Context, db *gorm.DB) (*models.Media, bool) {
+	var media models.Media
+	if err := db.Preload("Variants").First(&media, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Media not found",
+		})
+		return nil, false
+	}
+	if media.UploaderID != currentUserID(c) && !isStaff(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "You are not allowed to modify this media",
+		})
+		return nil, false
+	}
+	return &media, true
+}
+
+// deleteMedia 参照・メディア・ファイルの順に削除（元のファイルを消せなければロールバックする）
+// 縮小画像の行も一緒に削除し、縮小画像のファイルは消せなくても孤立するだけなので続ける
+func deleteMedia(ctx context.Context, tx *gorm.DB, store storage.Storage, media *models.Media) error {
+	if err := tx.Model(media).Association("Posts").Clear(); err != nil {
+		return err
+	}
+	if err := tx.Select("Variants").Delete(media).Error; err != nil {
+		return err
+	}
+	if err := store.Delete(ctx, media.Key); err != nil {
+		log.Printf("Failed to delete media file %s: %v", media.Key, err)
+		return err
+	}
+	deleteVariantFiles(ctx, store, media)
+	purgeImageCache(mediaKeys(media)...)
+	return nil
+}
+
+// deleteMediaFiles 元のファイルと縮小画像のファイルを削除（失敗はログに残すだけ）
+func deleteMediaFiles(ctx context.Context, store storage.Storage, media *models.Media) {
+	if err := store.Delete(ctx, media.Key); err != nil {
+		log.Printf("Failed to delete media file %s: %v", media.Key, err)
+	}
+	deleteVariantFiles(ctx, store, media)
+}
+
+// mediaKeys 元のファイルと縮小画像のキー
+func mediaKeys(media *models.Media) []string {
+	keys := []string{media.Key}
+	for _, variant := range media.Variants {
+		keys = append(keys, variant.Key)
+	}
+	return keys
+}
+
+func deleteVariantFiles(ctx context.Context, store storage.Storage, media *models.Media) {
+	for _, variant := range media.Variants {
+		if err := store.Delete(ctx, variant.Key); err != nil {
+			log.Printf("Failed to delete media file %s: %v", variant.Key, err)
+		}
+	}
+}
+
+// orderMediaVariants 縮小画像を幅の小さい順に読み込む
+func orderMediaVariants(db *gorm.DB) *gorm.DB {
+	return db.Order("width ASC, id ASC")
+}
+
+// setMediaURLs 保存先の設定から公開 URL と srcset をセット
+func setMediaURLs(media ...*models.Media) error {
+	store, err := getStorage()
+	if err != nil {
+		return err
+	}
+	for _, m := range media {
+		m.SetURLs(store.URL)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"blogapp/database"
+	"blogapp/internal/notify"
+	"blogapp/models"
+	"blogapp/utils"
+	"bytes"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// notificationSender 通知メールの送信に使う関数
+var notificationSender notify.Sender = utils.SendEmail
+
+// GetNotificationPreferences 自分のメール通知の設定を取得
+func GetNotificationPreferences(c *gin.Context) {
+	pref, err := loadNotificationPreference(database.GetDB(), currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch notification preferences",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preferences": pref,
+	})
+}
+
+// UpdateNotificationPreferences 自分のメール通知の設定を更新
+func UpdateNotificationPreferences(c *gin.Context) {
+	var req struct {
+		NewComments  *bool `json:"new_comments"`
+		SpamComments *bool `json:"spam_comments"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	pref, err := loadNotificationPreference(db, currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch notification preferences",
+		})
+		return
+	}
+	if req.NewComments != nil {
+		pref.NewComments = *req.NewComments
+	}
+	if req.SpamComments != nil {
+		pref.SpamComments = *req.SpamComments
+	}
+
+	if err := saveNotificationPreference(db, &pref); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update notification preferences",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Notification preferences updated successfully",
+		"preferences": pref,
+	})
+}
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head><meta charset="utf-8"><title>メール配信の停止</title></head>
+<body>
+	{{if .Done}}
+	<p>メールの配信を停止しました。</p>
+	{{else}}
+	<p>{{.Description}}を停止しますか？</p>
+	<form method="post" action="{{.Action}}">
+		<input type="hidden" name="List-Unsubscribe" value="One-Click">
+		<button type="submit">配信を停止する</button>
+	</form>
+	{{end}}
+</body>
+</html>`))
+
+// ShowUnsubscribe 配信停止の確認ページ（メール本文のリンク）
+// リンクの先読みで停止されないよう、GET では停止せずフォームを表示する
+func ShowUnsubscribe(c *gin.Context) {
+	token := c.Query("token")
+	kind, _, err := notify.VerifyUnsubscribeToken([]byte(getConfig().NotificationSecret), token)
+	if err != nil {
+		c.String(http.StatusBadRequest, "配信停止のリンクが正しくありません")
+		return
+	}
+
+	description := "コメントへの返信の通知"
+	if kind == notify.KindUser {
+		description = "投稿への新着コメントの通知"
+	}
+	renderUnsubscribePage(c, gin.H{
+		"Description": description,
+		"Action":      "?token=" + url.QueryEscape(token),
+	})
+}
+
+// Unsubscribe 配信を停止（List-Unsubscribe-Post によるワンクリック停止と確認ページのフォーム）
+func Unsubscribe(c *gin.Context) {
+	kind, id, err := notify.VerifyUnsubscribeToken([]byte(getConfig().NotificationSecret), c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid unsubscribe token",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	switch kind {
+	case notify.KindCommenter:
+		// 同じメールアドレスのコメントすべての返信通知を止める
+		var comment models.Comment
+		if err := db.Unscoped().First(&comment, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Subscription not found",
+			})
+			return
+		}
+		err = db.Model(&models.Comment{}).Unscoped().
+			Where("email_hash = ? AND notify_replies = ?", comment.EmailHash, true).
+			Update("notify_replies", false).Error
+	case notify.KindUser:
+		var pref models.NotificationPreference
+		if pref, err = loadNotificationPreference(db, id); err == nil {
+			pref.NewComments = false
+			pref.SpamComments = false
+			err = saveNotificationPreference(db, &pref)
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to unsubscribe",
+		})
+		return
+	}
+
+	// 確認ページのフォームから送られた場合はページで結果を返す
+	if strings.Contains(c.GetHeader("Accept"), "text/html") {
+		renderUnsubscribePage(c, gin.H{"Done": true})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Unsubscribed successfully",
+	})
+}
+
+func renderUnsubscribePage(c *gin.Context, data gin.H) {
+	var page bytes.Buffer
+	if err := unsubscribePage.Execute(&page, data); err != nil {
+		c.String(http.StatusInternalServerError, "Failed to render page")
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
+
+// loadNotificationPreference ユーザーの通知設定を取得（保存されていなければ既定値）
+func loadNotificationPreference(db *gorm.DB, userID uint) (models.NotificationPreference, error) {
+	var pref models.NotificationPreference
+	err := db.Where("user_id = ?", userID).First(&pref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultNotificationPreference(userID), nil
+	}
+	return pref, err
+}
+
+// saveNotificationPreference 通知設定を保存（ユーザーごとに1行）
+func saveNotificationPreference(db *gorm.DB, pref *models.NotificationPreference) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"new_comments", "spam_comments", "updated_at"}),
+	}).Create(pref).Error
+}
+
+// notifyNewComment 投稿の著者に新着コメントを通知（goroutine で呼ぶ）
+func notifyNewComment(comment models.Comment, post models.Post) {
+	db := database.GetDB()
+
+	var author models.User
+	if err := db.First(&author, post.AuthorID).Error; err != nil {
+		log.Printf("Failed to load author of post %d for comment notification: %v", post.ID, err)
+		return
+	}
+	if strings.EqualFold(author.Email, comment.Email) {
+		return
+	}
+
+	pref, err := loadNotificationPreference(db, author.ID)
+	if err != nil {
+		log.Printf("Failed to load notification preferences of user %d: %v", author.ID, err)
+		return
+	}
+	if comment.Status == models.CommentStatusSpam && !pref.SpamComments ||
+		comment.Status != models.CommentStatusSpam && !pref.NewComments {
+		return
+	}
+
+	sendNotification(notify.NewCommentMessage(author.Email, &notify.CommentMail{
+		Recipient:      displayName(&author),
+		PostTitle:      post.Title,
+		PostURL:        postURL(&post),
+		Author:         comment.Author,
+		Content:        comment.Content,
+		Status:         comment.Status,
+		UnsubscribeURL: unsubscribeURL(notify.KindUser, author.ID),
+	}))
+}
+
+// notifyReplies 承認された返信を、通知を希望した親コメントの投稿者に知らせる（goroutine で呼ぶ）
+// 一度通知した返信は承認し直しても再送しない
+func notifyReplies(ids []uint) {
+	db := database.GetDB()
+
+	var replies []models.Comment
+	if err := db.Preload("Post").
+		Where("id IN ? AND status = ? AND parent_id IS NOT NULL AND reply_notified_at IS NULL", ids, models.CommentStatusApproved).
+		Find(&replies).Error; err != nil {
+		log.Printf("Failed to load replies for notification: %v", err)
+		return
+	}
+
+	for _, reply := range replies {
+		var parent models.Comment
+		if err := db.Where("notify_replies = ?", true).First(&parent, *reply.ParentID).Error; err != nil {
+			continue
+		}
+		if strings.EqualFold(parent.Email, reply.Email) || reply.Post == nil {
+			continue
+		}
+
+		// 同時に承認された場合に二重に送らないよう、先に通知済みにする
+		result := db.Model(&models.Comment{}).
+			Where("id = ? AND reply_notified_at IS NULL", reply.ID).
+			Update("reply_notified_at", time.Now())
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		sendNotification(notify.ReplyMessage(parent.Email, &notify.CommentMail{
+			Recipient:      parent.Author,
+			PostTitle:      reply.Post.Title,
+			PostURL:        postURL(reply.Post),
+			Author:         reply.Author,
+			Content:        reply.Content,
+			Status:         reply.Status,
+			ParentContent:  parent.Content,
+			UnsubscribeURL: unsubscribeURL(notify.KindCommenter, parent.ID),
+		}))
+	}
+}
+
+func sendNotification(msg *notify.Message, err error) {
+	if err == nil {
+		err = notificationSender.Send(msg)
+	}
+	if err != nil {
+		log.Printf("Failed to send notification email: %v", err)
+	}
+}
+
+// unsubscribeURL メールに載せる配信停止リンク（List-Unsubscribe にも使う）
+func unsubscribeURL(kind notify.Kind, id uint) string {
+	cfg := getConfig()
+	token := notify.IssueUnsubscribeToken([]byte(cfg.NotificationSecret), kind, id)
+	return cfg.APIBaseURL + "/api/unsubscribe?token=" + url.QueryEscape(token)
+}
+
+// postURL フロントエンドの投稿ページの URL
+func postURL(post *models.Post) string {
+	return getConfig().SiteURL + "/posts/" + url.PathEscape(post.Slug)
+}
+
+func displayName(user *models.User) string {
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	return user.Username
+}
@@ -1,57 +1,178 @@
 package handlers
 
 import (
+	"blogapp/database"
+	"blogapp/models"
+	"errors"
 	"net/http"
-	
+	"net/url"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // GetPosts すべての投稿を取得
+// 未認証・作者ロールでは公開済みの投稿のみ。編集者・管理者は ?status= で絞り込める（all で全件）
 func GetPosts(c *gin.Context) {
-	// TODO: 実際のデータベースから取得
-	c.JSON(http.StatusOK, gin.H{
-		"posts": []gin.H{
-			{
-				"id":    1,
-				"title": "Sample Post",
-				"slug":  "sample-post",
-			},
-		},
-	})
+	db := database.GetDB()
+
+	query := db.Model(&models.Post{})
+	status := c.Query("status")
+	switch {
+	case isStaff(c) && status == "all":
+	case isStaff(c) && status != "":
+		query = query.Where("status = ?", status)
+	default:
+		query = query.Where("status = ?", models.PostStatusPublished)
+	}
+	if categoryIDs, ok := categoryFilter(c, db); !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Category not found",
+		})
+		return
+	} else if categoryIDs != nil {
+		query = query.Where("category_id IN ?", categoryIDs)
+	}
+	if authorID := c.Query("author_id"); authorID != "" {
+		query = query.Where("author_id = ?", authorID)
+	}
+
+	respondPosts(c, query, nil)
+}
+
+// respondPosts 絞り込み済みのクエリをページングして投稿一覧を返す（extra はレスポンスに追加する項目）
+func respondPosts(c *gin.Context, query *gorm.DB, extra gin.H) {
+	page, perPage := parsePagination(c)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch posts",
+		})
+		return
+	}
+
+	var posts []models.Post
+	if err := query.
+		Preload("Author").Preload("Category").Preload("Tags").
+		Order("published_at DESC NULLS LAST").Order("created_at DESC").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Find(&posts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch posts",
+		})
+		return
+	}
+	for i := range posts {
+		ensureRendered(&posts[i])
+	}
+
+	response := gin.H{
+		"posts":    posts,
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// categoryFilter ?category_id= または ?category=<slug> からカテゴリーの絞り込み条件を作る
+// ?include_descendants=true なら子孫カテゴリーの投稿も含める（指定がなければ nil）
+func categoryFilter(c *gin.Context, db *gorm.DB) ([]uint, bool) {
+	var category models.Category
+	switch {
+	case c.Query("category_id") != "":
+		if err := db.First(&category, parseID(c.Query("category_id"))).Error; err != nil {
+			return nil, false
+		}
+	case c.Query("category") != "":
+		if err := db.Where("slug = ?", c.Query("category")).First(&category).Error; err != nil {
+			return nil, false
+		}
+	default:
+		return nil, true
+	}
+
+	if c.Query("include_descendants") != "true" {
+		return []uint{category.ID}, true
+	}
+	ids, err := database.CategoryDescendantIDs(db, category.ID)
+	if err != nil || len(ids) == 0 {
+		return []uint{category.ID}, true
+	}
+	return ids, true
 }
 
 // GetPost IDで投稿を取得
 func GetPost(c *gin.Context) {
-	id := c.Param("id")
-	// TODO: 実際のデータベースから取得
+	var post models.Post
+	if err := database.GetDB().
+		Preload("Author").Preload("Category").Preload("Tags").
+		First(&post, idParam(c, "id")).Error; err != nil || !canViewPost(c, &post) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Post not found",
+		})
+		return
+	}
+	ensureRendered(&post)
+
 	c.JSON(http.StatusOK, gin.H{
-		"post": gin.H{
-			"id":    id,
-			"title": "Sample Post",
-			"slug":  "sample-post",
-		},
+		"post": post,
 	})
 }
 
 // GetPostBySlug スラッグで投稿を取得
+// 変更前のスラッグでアクセスされた場合は現在のスラッグへ 301 リダイレクトする
+// （?redirect=hint なら 200 で投稿と redirect_to を返す）
 func GetPostBySlug(c *gin.Context) {
-	slug := c.Param("slug")
-	// TODO: 実際のデータベースから取得
+	db := database.GetDB()
+	slugParam := c.Param("slug")
+
+	var post models.Post
+	err := db.
+		Preload("Author").Preload("Category").Preload("Tags").
+		Where("slug = ?", slugParam).
+		First(&post).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if postID, historyErr := models.FindSlugHistory(db, models.SlugEntityPost, slugParam); historyErr == nil {
+			err = db.Preload("Author").Preload("Category").Preload("Tags").First(&post, postID).Error
+			if err == nil && canViewPost(c, &post) {
+				redirectToCurrentSlug(c, &post)
+				return
+			}
+		}
+	}
+	if err != nil || !canViewPost(c, &post) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Post not found",
+		})
+		return
+	}
+	ensureRendered(&post)
+
 	c.JSON(http.StatusOK, gin.H{
-		"post": gin.H{
-			"id":    1,
-			"title": "Sample Post",
-			"slug":  slug,
-		},
+		"post": post,
 	})
 }
 
 // CreatePost 新しい投稿を作成
 func CreatePost(c *gin.Context) {
 	var req struct {
-		Title   string `json:"title" binding:"required"`
-		Content string `json:"content" binding:"required"`
-		Slug    string `json:"slug" binding:"required"`
+		Title       string     `json:"title" binding:"required"`
+		Content     string     `json:"content" binding:"required"`
+		Slug        string     `json:"slug"` // 省略時はタイトルから生成
+		Excerpt     string     `json:"excerpt"`
+		ImageURL    string     `json:"image_url"`
+		CategoryID  uint       `json:"category_id"`
+		TagIDs      []uint     `json:"tag_ids"`
+		Tags        []string   `json:"tags"` // タグ名（別名は正規のタグに置き換え、未登録なら作成）
+		Status      string     `json:"status"`
+		PublishedAt *time.Time `json:"published_at"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -61,25 +182,90 @@ func CreatePost(c *gin.Context) {
 		return
 	}
 
-	// TODO: 実際のデータベースに保存
+	db := database.GetDB()
+	resolvePostSlug := func() (string, error) {
+		return resolveSlug(db, &models.Post{}, req.Slug, req.Title, 0)
+	}
+	slugValue, err := resolvePostSlug()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate slug",
+		})
+		return
+	}
+	needsFallback := slugValue == ""
+	if needsFallback {
+		slugValue = pendingSlug()
+	}
+
+	post := models.Post{
+		Title:      req.Title,
+		Slug:       slugValue,
+		Content:    req.Content,
+		Excerpt:    req.Excerpt,
+		ImageURL:   req.ImageURL,
+		CategoryID: optionalID(req.CategoryID),
+		AuthorID:   currentUserID(c),
+		Status:     models.PostStatusDraft,
+	}
+
+	// 作成時に下書き以外のステータスを指定した場合は draft からの遷移として検証
+	if req.Status != "" && req.Status != models.PostStatusDraft {
+		if code, msg := checkPostStatusChange(c, &post, req.Status, req.PublishedAt); code != 0 {
+			c.JSON(code, gin.H{
+				"error": msg,
+			})
+			return
+		}
+		post.ApplyStatus(req.Status, req.PublishedAt, time.Now())
+	}
+
+	err = retrySlugConflict(&post.Slug, resolvePostSlug, func() error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if post.Tags, err = postTags(tx, req.TagIDs, req.Tags); err != nil {
+				return err
+			}
+			if err := tx.Create(&post).Error; err != nil {
+				return err
+			}
+			if needsFallback {
+				var err error
+				if post.Slug, err = assignFallbackSlug(tx, &post, "post", post.ID); err != nil {
+					return err
+				}
+			}
+			if err := database.SyncPostMedia(tx, &post); err != nil {
+				return err
+			}
+			return savePostRevision(tx, nil, &post, post.AuthorID)
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create post",
+		})
+		return
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Post created successfully",
-		"post": gin.H{
-			"id":      1,
-			"title":   req.Title,
-			"content": req.Content,
-			"slug":    req.Slug,
-		},
+		"post":    post,
 	})
 }
 
-// UpdatePost 投稿を更新
+// UpdatePost 投稿を更新（本文などの変更はリビジョンとして記録）
+// ステータスの変更は ChangePostStatus で行う
 func UpdatePost(c *gin.Context) {
-	id := c.Param("id")
 	var req struct {
-		Title   string `json:"title"`
-		Content string `json:"content"`
-		Slug    string `json:"slug"`
+		Title      *string  `json:"title"`
+		Content    *string  `json:"content"`
+		Slug       *string  `json:"slug"`
+		Excerpt    *string  `json:"excerpt"`
+		ImageURL   *string  `json:"image_url"`
+		CategoryID *uint    `json:"category_id"` // 0 で未分類
+		TagIDs     []uint   `json:"tag_ids"`
+		Tags       []string `json:"tags"` // タグ名（tag_ids と合わせて置き換える）
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -89,25 +275,209 @@ func UpdatePost(c *gin.Context) {
 		return
 	}
 
-	// TODO: 実際のデータベースを更新
+	db := database.GetDB()
+	var post models.Post
+	if err := db.First(&post, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Post not found",
+		})
+		return
+	}
+	if !canEditPost(c, &post) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "You are not allowed to edit this post",
+		})
+		return
+	}
+
+	before := post
+	if req.Title != nil {
+		post.Title = *req.Title
+	}
+	if req.Content != nil {
+		post.Content = *req.Content
+	}
+	var resolvePostSlug func() (string, error)
+	if req.Slug != nil && *req.Slug != "" {
+		resolvePostSlug = func() (string, error) {
+			return resolveSlug(db, &models.Post{}, *req.Slug, post.Title, post.ID)
+		}
+		slugValue, err := resolvePostSlug()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to generate slug",
+			})
+			return
+		}
+		if slugValue != "" {
+			post.Slug = slugValue
+		}
+	}
+	if req.Excerpt != nil {
+		post.Excerpt = *req.Excerpt
+	}
+	if req.ImageURL != nil {
+		post.ImageURL = *req.ImageURL
+	}
+	if req.CategoryID != nil {
+		post.CategoryID = optionalID(*req.CategoryID)
+	}
+
+	err := retrySlugConflict(&post.Slug, resolvePostSlug, func() error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Omit(clause.Associations).Save(&post).Error; err != nil {
+				return err
+			}
+			if err := savePostRevision(tx, &before, &post, currentUserID(c)); err != nil {
+				return err
+			}
+			if err := database.SyncPostMedia(tx, &post); err != nil {
+				return err
+			}
+			if req.TagIDs == nil && req.Tags == nil {
+				return nil
+			}
+			tags, err := postTags(tx, req.TagIDs, req.Tags)
+			if err != nil {
+				return err
+			}
+			return tx.Model(&post).Association("Tags").Replace(tags)
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update post",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Post updated successfully",
-		"post": gin.H{
-			"id":      id,
-			"title":   req.Title,
-			"content": req.Content,
-			"slug":    req.Slug,
-		},
+		"post":    post,
+	})
+}
+
+// ChangePostStatus 投稿のステータスを変更（下書き → レビュー待ち → 予約/公開 → アーカイブ）
+func ChangePostStatus(c *gin.Context) {
+	var req struct {
+		Status      string     `json:"status" binding:"required"`
+		PublishedAt *time.Time `json:"published_at"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var post models.Post
+	if err := db.First(&post, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Post not found",
+		})
+		return
+	}
+
+	if code, msg := checkPostStatusChange(c, &post, req.Status, req.PublishedAt); code != 0 {
+		c.JSON(code, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	post.ApplyStatus(req.Status, req.PublishedAt, time.Now())
+	if err := db.Model(&post).Select("status", "published", "published_at").Updates(&post).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update post status",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Post status updated successfully",
+		"post":    post,
 	})
 }
 
 // DeletePost 投稿を削除
 func DeletePost(c *gin.Context) {
-	id := c.Param("id")
-	
-	// TODO: 実際のデータベースから削除
+	db := database.GetDB()
+	var post models.Post
+	if err := db.First(&post, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Post not found",
+		})
+		return
+	}
+	if !canEditPost(c, &post) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "You are not allowed to delete this post",
+		})
+		return
+	}
+
+	if err := db.Delete(&post).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete post",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Post deleted successfully",
-		"id":      id,
+		"id":      post.ID,
 	})
 }
+
+// redirectToCurrentSlug 旧スラッグへのアクセスを現在のスラッグへ案内する
+func redirectToCurrentSlug(c *gin.Context, post *models.Post) {
+	location := "/api/posts/slug/" + url.PathEscape(post.Slug)
+
+	if c.Query("redirect") == "hint" {
+		ensureRendered(post)
+		c.JSON(http.StatusOK, gin.H{
+			"redirect_to": post.Slug,
+			"post":        post,
+		})
+		return
+	}
+
+	c.Header("Location", location)
+	c.JSON(http.StatusMovedPermanently, gin.H{
+		"redirect_to": post.Slug,
+		"location":    location,
+	})
+}
+
+// canViewPost 公開済みでない投稿は作者と編集者・管理者のみ閲覧可能
+func canViewPost(c *gin.Context, post *models.Post) bool {
+	return post.Status == models.PostStatusPublished || canEditPost(c, post)
+}
+
+// canEditPost 作者本人か編集者・管理者なら編集可能
+func canEditPost(c *gin.Context, post *models.Post) bool {
+	if isStaff(c) {
+		return true
+	}
+	userID := currentUserID(c)
+	return userID != 0 && post.AuthorID == userID
+}
+
+// checkPostStatusChange ステータス変更の可否を検証（問題なければ code は 0）
+func checkPostStatusChange(c *gin.Context, post *models.Post, status string, publishAt *time.Time) (int, string) {
+	if !models.IsValidPostStatus(status) {
+		return http.StatusBadRequest, "Unknown post status"
+	}
+	if !canEditPost(c, post) {
+		return http.StatusForbidden, "You are not allowed to change this post"
+	}
+	if !models.CanTransitionPost(post.Status, status, currentUserRole(c)) {
+		return http.StatusForbidden, "Transition from " + post.Status + " to " + status + " is not allowed"
+	}
+	if status == models.PostStatusScheduled && (publishAt == nil || !publishAt.After(time.Now())) {
+		return http.StatusBadRequest, "published_at must be in the future for scheduled posts"
+	}
+	return 0, ""
+}
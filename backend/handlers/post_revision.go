@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"blogapp/database"
+	"blogapp/internal/diff"
+	"blogapp/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ListPostRevisions 投稿のリビジョン一覧を取得（本文は含まない）
+func ListPostRevisions(c *gin.Context) {
+	post, ok := loadEditablePost(c)
+	if !ok {
+		return
+	}
+
+	var revisions []models.PostRevision
+	if err := database.GetDB().
+		Omit("content").
+		Preload("Editor").
+		Where("post_id = ?", post.ID).
+		Order("created_at DESC, id DESC").
+		Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch revisions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"revisions": revisions,
+	})
+}
+
+// GetPostRevision リビジョンを1件取得
+func GetPostRevision(c *gin.Context) {
+	post, ok := loadEditablePost(c)
+	if !ok {
+		return
+	}
+
+	revision, ok := loadRevision(c, post.ID, c.Param("revisionId"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"revision": revision,
+	})
+}
+
+// DiffPostRevisions 2つのリビジョンの差分を取得
+// ?from=<id>&to=<id|current>&mode=unified|word
+func DiffPostRevisions(c *gin.Context) {
+	post, ok := loadEditablePost(c)
+	if !ok {
+		return
+	}
+
+	mode := c.DefaultQuery("mode", "unified")
+	if mode != "unified" && mode != "word" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "mode must be unified or word",
+		})
+		return
+	}
+
+	from, ok := loadRevision(c, post.ID, c.Query("from"))
+	if !ok {
+		return
+	}
+
+	// to を省略または current にすると現在の投稿内容と比較
+	to := models.NewPostRevision(post, post.AuthorID)
+	toName := "current"
+	if toParam := c.Query("to"); toParam != "" && toParam != "current" {
+		if to, ok = loadRevision(c, post.ID, toParam); !ok {
+			return
+		}
+		toName = fmt.Sprintf("revision %d", to.ID)
+	}
+	fromName := fmt.Sprintf("revision %d", from.ID)
+
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"title", from.Title, to.Title},
+		{"excerpt", from.Excerpt, to.Excerpt},
+		{"content", from.Content, to.Content},
+	}
+
+	result := gin.H{}
+	for _, f := range fields {
+		if mode == "word" {
+			result[f.name] = diff.Words(f.from, f.to)
+		} else {
+			result[f.name] = diff.Unified(f.from, f.to, fromName+"/"+f.name, toName+"/"+f.name, 3)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from": from.ID,
+		"to":   toName,
+		"mode": mode,
+		"diff": result,
+	})
+}
+
+// RestorePostRevision リビジョンの内容で投稿を復元（復元自体も新しいリビジョンとして記録）
+func RestorePostRevision(c *gin.Context) {
+	post, ok := loadEditablePost(c)
+	if !ok {
+		return
+	}
+
+	revision, ok := loadRevision(c, post.ID, c.Param("revisionId"))
+	if !ok {
+		return
+	}
+
+	before := *post
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.Excerpt = revision.Excerpt
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(post).Error; err != nil {
+			return err
+		}
+		if err := database.SyncPostMedia(tx, post); err != nil {
+			return err
+		}
+		return savePostRevision(tx, &before, post, currentUserID(c))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to restore revision",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Revision restored successfully",
+		"post":    post,
+	})
+}
+
+// savePostRevision 更新後の投稿内容をリビジョンとして保存し、保持ポリシーに従って古いものを削除
+// before が nil でなく、まだリビジョンがない投稿なら更新前の内容も残す
+func savePostRevision(tx *gorm.DB, before, after *models.Post, editorID uint) error {
+	if before != nil {
+		var latest models.PostRevision
+		err := tx.Where("post_id = ?", after.ID).Order("created_at DESC, id DESC").First(&latest).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			baseline := models.NewPostRevision(before, before.AuthorID)
+			baseline.CreatedAt = before.UpdatedAt
+			if err := tx.Create(baseline).Error; err != nil {
+				return err
+			}
+			latest = *baseline
+		case err != nil:
+			return err
+		}
+
+		// タグやカテゴリーだけの変更ではリビジョンを増やさない
+		if latest.SameContent(after) {
+			return nil
+		}
+	}
+
+	if err := tx.Create(models.NewPostRevision(after, editorID)).Error; err != nil {
+		return err
+	}
+	return database.PrunePostRevisions(tx, after.ID, revisionRetention())
+}
+
+// revisionRetention 設定からリビジョンの保持ポリシーを作成
+func revisionRetention() database.RevisionRetention {
+	cfg := getConfig()
+	return database.RevisionRetention{
+		Keep:   cfg.RevisionKeep,
+		MaxAge: time.Duration(cfg.RevisionMaxAgeDays) * 24 * time.Hour,
+	}
+}
+
+// loadEditablePost URLの :id の投稿を取得し、編集権限を確認（失敗時はレスポンス済み）
+func loadEditablePost(c *gin.Context) (*models.Post, bool) {
+	var post models.Post
+	if err := database.GetDB().First(&post, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Post not found",
+		})
+		return nil, false
+	}
+	if !canEditPost(c, &post) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "You are not allowed to access this post",
+		})
+		return nil, false
+	}
+	return &post, true
+}
+
+// loadRevision 投稿に属するリビジョンを取得（失敗時はレスポンス済み）
+func loadRevision(c *gin.Context, postID uint, idParam string) (*models.PostRevision, bool) {
+	id, err := strconv.ParseUint(idParam, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid revision id",
+		})
+		return nil, false
+	}
+
+	var revision models.PostRevision
+	if err := database.GetDB().
+		Preload("Editor").
+		Where("post_id = ?", postID).
+		First(&revision, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Revision not found",
+		})
+		return nil, false
+	}
+	return &revision, true
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"blogapp/database"
+	"blogapp/models"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxRedirectHops リダイレクトの連鎖をたどる上限（ループ検出用）
+const maxRedirectHops = 10
+
+// GetRedirects リダイレクト一覧を取得
+func GetRedirects(c *gin.Context) {
+	var redirects []models.Redirect
+	if err := database.GetDB().Order("from_path").Find(&redirects).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch redirects",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"redirects": redirects,
+	})
+}
+
+// CreateRedirect リダイレクトを作成
+func CreateRedirect(c *gin.Context) {
+	var req struct {
+		FromPath   string `json:"from_path" binding:"required"`
+		ToPath     string `json:"to_path" binding:"required"`
+		StatusCode int    `json:"status_code"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	redirect := models.Redirect{
+		FromPath:   normalizeRedirectPath(req.FromPath),
+		ToPath:     strings.TrimSpace(req.ToPath),
+		StatusCode: req.StatusCode,
+	}
+	if msg := validateRedirect(database.GetDB(), &redirect); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	if err := database.GetDB().Create(&redirect).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A redirect for this path already exists",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Redirect created successfully",
+		"redirect": redirect,
+	})
+}
+
+// UpdateRedirect リダイレクトを更新
+func UpdateRedirect(c *gin.Context) {
+	var req struct {
+		FromPath   *string `json:"from_path"`
+		ToPath     *string `json:"to_path"`
+		StatusCode *int    `json:"status_code"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var redirect models.Redirect
+	if err := db.First(&redirect, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Redirect not found",
+		})
+		return
+	}
+
+	if req.FromPath != nil {
+		redirect.FromPath = normalizeRedirectPath(*req.FromPath)
+	}
+	if req.ToPath != nil {
+		redirect.ToPath = strings.TrimSpace(*req.ToPath)
+	}
+	if req.StatusCode != nil {
+		redirect.StatusCode = *req.StatusCode
+	}
+	if msg := validateRedirect(db, &redirect); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	if err := db.Save(&redirect).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A redirect for this path already exists",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Redirect updated successfully",
+		"redirect": redirect,
+	})
+}
+
+// DeleteRedirect リダイレクトを削除
+func DeleteRedirect(c *gin.Context) {
+	result := database.GetDB().Delete(&models.Redirect{}, idParam(c, "id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete redirect",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Redirect not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Redirect deleted successfully",
+		"id":      c.Param("id"),
+	})
+}
+
+// ResolveRedirect パスに対するリダイレクト先を返す（フロントエンドのルーティング用）
+// GET /api/redirects/resolve?path=/old-path
+func ResolveRedirect(c *gin.Context) {
+	redirect, ok := lookupRedirect(c.Query("path"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Redirect not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"to_path":     redirect.ToPath,
+		"status_code": redirect.StatusCode,
+	})
+}
+
+// RedirectFallback ルートが見つからないリクエストにリダイレクトを適用する（NoRoute 用）
+func RedirectFallback(c *gin.Context) {
+	if redirect, ok := lookupRedirect(c.Request.URL.Path); ok {
+		c.Redirect(redirect.StatusCode, redirect.ToPath)
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{
+		"error": "Not found",
+	})
+}
+
+// lookupRedirect パスに一致するリダイレクトを探し、アクセス数を加算
+func lookupRedirect(path string) (*models.Redirect, bool) {
+	if path == "" {
+		return nil, false
+	}
+
+	db := database.GetDB()
+	var redirect models.Redirect
+	if err := db.Where("from_path = ?", normalizeRedirectPath(path)).First(&redirect).Error; err != nil {
+		return nil, false
+	}
+
+	db.Model(&redirect).UpdateColumns(map[string]interface{}{
+		"hits":        gorm.Expr("hits + 1"),
+		"last_hit_at": time.Now(),
+	})
+	return &redirect, true
+}
+
+// normalizeRedirectPath 先頭の / を補い、クエリと末尾の / を取り除く
+func normalizeRedirectPath(path string) string {
+	path = strings.TrimSpace(path)
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	return path
+}
+
+// validateRedirect 入力を検証（問題なければ空文字）
+func validateRedirect(db *gorm.DB, redirect *models.Redirect) string {
+	switch redirect.StatusCode {
+	case 0:
+		redirect.StatusCode = http.StatusMovedPermanently
+	case http.StatusMovedPermanently, http.StatusFound,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return "status_code must be 301, 302, 307 or 308"
+	}
+
+	if strings.HasPrefix(redirect.ToPath, "/") {
+		if strings.HasPrefix(redirect.ToPath, "//") {
+			return "to_path must be a path or an absolute http(s) URL"
+		}
+	} else if u, err := url.Parse(redirect.ToPath); err != nil ||
+		(u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "to_path must be a path or an absolute http(s) URL"
+	}
+
+	// 連鎖をたどって元のパスに戻るならループ
+	next := redirect.ToPath
+	for i := 0; i < maxRedirectHops && strings.HasPrefix(next, "/"); i++ {
+		next = normalizeRedirectPath(next)
+		if next == redirect.FromPath {
+			return "Redirect would create a loop"
+		}
+		var target models.Redirect
+		if err := db.Where("from_path = ? AND id <> ?", next, redirect.ID).First(&target).Error; err != nil {
+			break
+		}
+		next = target.ToPath
+	}
+	return ""
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"blogapp/database"
+	"blogapp/internal/scan"
+	"blogapp/internal/upload"
+	"blogapp/models"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errMalwareFound マルウェア検査で検出した
+var errMalwareFound = errors.New("malware found")
+
+// scanUnavailableError 検査できなかった（503 を返し、tus のアップロードはやり直せるように残す）
+type scanUnavailableError struct {
+	err error
+}
+
+func (e *scanUnavailableError) Error() string { return "malware scan failed: " + e.err.Error() }
+func (e *scanUnavailableError) Unwrap() error { return e.err }
+
+// scanUpload 保存する前にファイルをマルウェア検査し、結果を ScanLog に記録する（検査が無効なら何もしない）
+// 検出した場合は隔離（SCAN_QUARANTINE）して拒否し、検査できなかった場合は SCAN_FAIL_OPEN でなければ拒否する
+// 記録できなかった場合もファイルは受け付けない
+func scanUpload(ctx context.Context, src io.ReadSeeker, u mediaUpload, result *upload.Result, key string) error {
+	scanner := getScanner()
+	if scanner == nil {
+		return nil
+	}
+	entry, scanErr := runScan(ctx, scanner, src, u, result, key)
+	if entry == nil {
+		return scanErr
+	}
+
+	if err := database.GetDB().Create(entry).Error; err != nil {
+		log.Printf("Failed to record malware scan: %v", err)
+		if scanErr == nil {
+			return err
+		}
+	}
+	return scanErr
+}
+
+// runScan scanner でファイルを検査し、記録する内容と、受け付けない場合のエラーを返す
+// ファイルを先頭に戻せなかった場合は記録する内容を nil にする
+func runScan(ctx context.Context, scanner scan.Scanner, src io.ReadSeeker, u mediaUpload, result *upload.Result, key string) (*models.ScanLog, error) {
+	cfg := getConfig()
+	entry := &models.ScanLog{
+		UserID:   u.UploaderID,
+		Key:      key,
+		Filename: truncateUTF8(u.Filename, 255),
+		MIME:     result.MIME,
+		Size:     result.Size,
+		Hash:     result.Hash,
+		Scanner:  scanner.Name(),
+	}
+	scanned, err := scanner.Scan(ctx, src)
+	if _, seekErr := src.Seek(0, io.SeekStart); seekErr != nil {
+		return nil, seekErr
+	}
+
+	var scanErr error
+	switch {
+	case err != nil:
+		log.Printf("Malware scan failed for %s: %v", key, err)
+		entry.Result = models.ScanResultError
+		entry.Error = truncateUTF8(err.Error(), 500)
+		entry.Action = models.ScanActionAccepted
+		if !cfg.ScanFailOpen {
+			entry.Action = models.ScanActionRejected
+			scanErr = &scanUnavailableError{err: err}
+		}
+	case scanned.Infected:
+		log.Printf("Malware found in upload by user %d (%s): %s", u.UploaderID, result.Hash, scanned.Signature)
+		entry.Result = models.ScanResultInfected
+		entry.Signature = truncateUTF8(scanned.Signature, 255)
+		entry.Action = models.ScanActionRejected
+		if cfg.ScanQuarantine {
+			path, err := quarantineFile(src, result)
+			if err != nil {
+				log.Printf("Failed to quarantine %s: %v", result.Hash, err)
+			} else {
+				entry.Action = models.ScanActionQuarantined
+				entry.QuarantinePath = path
+			}
+		}
+		scanErr = &uploadRejectedError{err: errMalwareFound, message: "File contains malware"}
+	default:
+		entry.Result = models.ScanResultClean
+		entry.Action = models.ScanActionAccepted
+	}
+	return entry, scanErr
+}
+
+// quarantineFile ファイルを QUARANTINE_DIR にコピーし、そこからの相対パスを返す
+// 隔離したファイルは公開せず、実行もできない権限・拡張子で置く
+func quarantineFile(src io.ReadSeeker, result *upload.Result) (string, error) {
+	dir := getConfig().QuarantineDir
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	name := time.Now().UTC().Format("20060102T150405Z") + "-" + result.Hash[:32] + ".quarantine"
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if _, seekErr := src.Seek(0, io.SeekStart); err == nil {
+		err = seekErr
+	}
+	if err != nil {
+		os.Remove(filepath.Join(dir, name))
+		return "", err
+	}
+	return name, nil
+}
+
+// GetScanLogs マルウェア検査の記録の一覧（新しい順、管理者のみ）
+// ?result=clean|infected|error、?action=accepted|rejected|quarantined、?user_id= で絞り込む
+func GetScanLogs(c *gin.Context) {
+	query := database.GetDB().Model(&models.ScanLog{})
+	if result := c.Query("result"); result != "" {
+		query = query.Where("result = ?", result)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", parseID(userID))
+	}
+
+	page, perPage := parsePagination(c)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch scan logs",
+		})
+		return
+	}
+
+	var logs []models.ScanLog
+	if err := query.Order("created_at DESC, id DESC").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch scan logs",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scans":    logs,
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+	})
+}
+
+// DeleteQuarantinedFile 隔離したファイルを削除（記録は残す、管理者のみ）
+func DeleteQuarantinedFile(c *gin.Context) {
+	db := database.GetDB()
+	var entry models.ScanLog
+	if err := db.First(&entry, idParam(c, "id")).Error; err != nil || entry.QuarantinePath == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Quarantined file not found",
+		})
+		return
+	}
+
+	// 記録のパスはファイル名だけなので、ディレクトリの外を指すことはない
+	path := filepath.Join(getConfig().QuarantineDir, filepath.Base(entry.QuarantinePath))
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete quarantined file",
+		})
+		return
+	}
+	if err := db.Model(&entry).UpdateColumn("quarantine_path", "").Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete quarantined file",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Quarantined file deleted successfully",
+	})
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"blogapp/config"
+	"blogapp/internal/scan"
+	"blogapp/internal/upload"
+	"blogapp/models"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startClamd INSTREAM を受け取って reply を返すテスト用の clamd（reply が "" なら応答せずに切断する）
+func startClamd(t *testing.T, reply string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if _, err := io.ReadFull(conn, make([]byte, len("zINSTREAM\x00"))); err != nil {
+					return
+				}
+				for {
+					var size [4]byte
+					if _, err := io.ReadFull(conn, size[:]); err != nil {
+						return
+					}
+					n := int64(binary.BigEndian.Uint32(size[:]))
+					if n == 0 {
+						break
+					}
+					if _, err := io.CopyN(io.Discard, conn, n); err != nil {
+						return
+					}
+				}
+				if reply != "" {
+					conn.Write([]byte(reply + "\x00"))
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestRunScan(t *testing.T) {
+	saved := appConfig
+	t.Cleanup(func() { appConfig = saved })
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name       string
+		address    string
+		failOpen   bool
+		quarantine bool
+		result     string
+		action     string
+		err        any // 返すエラーの型（nil なら受け付ける）
+	}{
+		{"clean", startClamd(t, "stream: OK"), false, true, models.ScanResultClean, models.ScanActionAccepted, nil},
+		{"found and quarantined", startClamd(t, "stream: Eicar-Test-Signature FOUND"), false, true, models.ScanResultInfected, models.ScanActionQuarantined, &uploadRejectedError{}},
+		{"found and discarded", startClamd(t, "stream: Eicar-Test-Signature FOUND"), true, false, models.ScanResultInfected, models.ScanActionRejected, &uploadRejectedError{}},
+		{"error reply fails closed", startClamd(t, "stream: INSTREAM size limit exceeded. ERROR"), false, true, models.ScanResultError, models.ScanActionRejected, &scanUnavailableError{}},
+		{"error reply fails open", startClamd(t, "stream: INSTREAM size limit exceeded. ERROR"), true, true, models.ScanResultError, models.ScanActionAccepted, nil},
+		{"no reply fails closed", startClamd(t, ""), false, true, models.ScanResultError, models.ScanActionRejected, &scanUnavailableError{}},
+		{"unreachable fails closed", unreachable, false, true, models.ScanResultError, models.ScanActionRejected, &scanUnavailableError{}},
+		{"unreachable fails open", unreachable, true, true, models.ScanResultError, models.ScanActionAccepted, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			appConfig = &config.Config{ScanFailOpen: tt.failOpen, ScanQuarantine: tt.quarantine, QuarantineDir: dir}
+			scanner, err := scan.NewClamd(tt.address, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			content := []byte("uploaded file")
+			src := bytes.NewReader(content)
+			result := &upload.Result{MIME: "application/pdf", Size: int64(len(content)), Hash: strings.Repeat("ab", 32)}
+			entry, err := runScan(context.Background(), scanner, src, mediaUpload{UploaderID: 7, Filename: "a.pdf"}, result, "2024/05/a.pdf")
+
+			switch want := tt.err.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("runScan error = %v, want nil", err)
+				}
+			case *uploadRejectedError:
+				if !errors.As(err, &want) || !errors.Is(err, errMalwareFound) {
+					t.Fatalf("runScan error = %v, want malware rejection", err)
+				}
+			case *scanUnavailableError:
+				if !errors.As(err, &want) {
+					t.Fatalf("runScan error = %v, want scanUnavailableError", err)
+				}
+			}
+			if entry == nil {
+				t.Fatal("runScan returned no scan log")
+			}
+			if entry.Result != tt.result || entry.Action != tt.action || entry.UserID != 7 || entry.Key != "2024/05/a.pdf" {
+				t.Errorf("scan log = %+v, want result %s and action %s", entry, tt.result, tt.action)
+			}
+			if (entry.Error != "") != (tt.result == models.ScanResultError) {
+				t.Errorf("scan log error = %q", entry.Error)
+			}
+
+			// 隔離したファイルは中身が同じで、検査のあとも元のファイルは先頭から読める
+			if tt.action == models.ScanActionQuarantined {
+				quarantined, err := os.ReadFile(filepath.Join(dir, entry.QuarantinePath))
+				if err != nil || !bytes.Equal(quarantined, content) {
+					t.Errorf("quarantined file = %q, %v", quarantined, err)
+				}
+			}
+			if rest, _ := io.ReadAll(src); !bytes.Equal(rest, content) {
+				t.Errorf("source after scan = %q, want it rewound", rest)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"blogapp/internal/slug"
+	"errors"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// resolveSlug 保存するスラッグを決定する
+// requested が指定されていれば正規化して使い、なければ source（タイトル・名前）から生成する。
+// 既存のスラッグと重複する場合は -2, -3 ... を付ける。
+// どちらからも生成できない場合（漢字を含むタイトルなど）は空文字を返すので、
+// 作成後に assignFallbackSlug で ID ベースのスラッグを割り当てる。
+func resolveSlug(db *gorm.DB, model interface{}, requested, source string, excludeID uint) (string, error) {
+	base := slug.Make(requested)
+	if base == "" {
+		base = slug.Make(source)
+	}
+	if base == "" {
+		return "", nil
+	}
+	return slug.Unique(db, model, base, excludeID)
+}
+
+// slugRetries 保存時にスラッグが重複した場合に決め直す回数
+const slugRetries = 3
+
+// retrySlugConflict save を実行し、一意制約違反ならスラッグを決め直して再実行する
+// resolveSlug で空きを確認してから保存するまでの間に、別のリクエストが同じスラッグで保存した場合のため。
+// save がトランザクションの途中で失敗してもやり直せるよう、save 自体をトランザクション（入れ子ならセーブポイント）にすること。
+// resolve が nil か、同じスラッグを返した場合は名前などスラッグ以外の重複なので、そのままエラーを返す。
+func retrySlugConflict(slugValue *string, resolve func() (string, error), save func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := save()
+		if !isDuplicateKey(err) || resolve == nil || attempt == slugRetries {
+			return err
+		}
+		next, resolveErr := resolve()
+		if resolveErr != nil {
+			return resolveErr
+		}
+		if next == "" || next == *slugValue {
+			return err
+		}
+		*slugValue = next
+	}
+}
+
+// pendingSlug ID が決まるまでの一時的なスラッグ（一意インデックスを満たすためランダム）
+func pendingSlug() string {
+	token, err := generateSecureToken(8)
+	if err != nil {
+		return "pending"
+	}
+	return "pending-" + token
+}
+
+// assignFallbackSlug 作成済みのレコードに ID ベースのスラッグ（post-12 など）を割り当てる
+func assignFallbackSlug(tx *gorm.DB, model interface{}, prefix string, id uint) (string, error) {
+	value, err := slug.Unique(tx, model, slug.Fallback(prefix, id), id)
+	if err != nil {
+		return "", err
+	}
+	if err := tx.Model(model).Where("id = ?", id).UpdateColumn("slug", value).Error; err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// findBySlugOrID スラッグで検索し、見つからず数値ならIDとして検索する
+func findBySlugOrID(db *gorm.DB, dest interface{}, value string) error {
+	err := db.Where("slug = ?", value).First(dest).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	id, convErr := strconv.ParseUint(value, 10, 64)
+	if convErr != nil || id == 0 {
+		return err
+	}
+	return db.First(dest, id).Error
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"blogapp/database"
+	"blogapp/models"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// storageQuotaError アップロードすると容量の上限を超える（413 を返す）
+type storageQuotaError struct {
+	Used  int64
+	Quota int64
+	Size  int64
+}
+
+func (e *storageQuotaError) Error() string {
+	return fmt.Sprintf("storage quota exceeded (used %d + %d > %d bytes)", e.Used, e.Size, e.Quota)
+}
+
+// checkStorageQuota size バイトを追加すると上限を超えるなら storageQuotaError を返す（quota が 0 なら無制限）
+func checkStorageQuota(db *gorm.DB, userID uint, size, quota int64) error {
+	if quota <= 0 {
+		return nil
+	}
+	usage, err := database.MediaStorageUsage(db, userID)
+	if err != nil {
+		return err
+	}
+	if usage.Bytes+size > quota {
+		return &storageQuotaError{Used: usage.Bytes, Quota: quota, Size: size}
+	}
+	return nil
+}
+
+// storageQuota ログイン中のユーザーの容量の上限（ユーザーごとの設定、なければ DB に保存しているロールの既定値。0 は無制限）
+// トークンのロールは発行後に変わっている場合があるので使わない（読めなければ投稿者の既定値）
+func storageQuota(c *gin.Context) int64 {
+	var user models.User
+	database.GetDB().Select("id", "role", "is_admin", "storage_quota").Where("id = ?", currentUserID(c)).Limit(1).Find(&user)
+	return userStorageQuota(user.StorageQuota, user.EffectiveRole())
+}
+
+// userStorageQuota ユーザーの容量の上限（ユーザーごとの設定、なければ保存しているロールの既定値）
+func userStorageQuota(override *int64, role string) int64 {
+	if override != nil {
+		return *override
+	}
+	if role == "" {
+		role = models.RoleAuthor
+	}
+	return getConfig().StorageQuota(role)
+}
+
+// optionalQuota 無制限（0）を null として返す
+func optionalQuota(quota int64) *int64 {
+	if quota <= 0 {
+		return nil
+	}
+	return &quota
+}
+
+// GetMyStorage 自分の使用容量と上限、種類ごとの内訳
+// pending は受け取り途中の再開可能なアップロードの大きさ（完了すると used に加わる）
+func GetMyStorage(c *gin.Context) {
+	db := database.GetDB()
+	userID := currentUserID(c)
+
+	usage, err := database.MediaStorageUsage(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch storage usage",
+		})
+		return
+	}
+	byType, err := database.MediaStorageUsageByType(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch storage usage",
+		})
+		return
+	}
+	pending, err := database.PendingUploadBytes(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch storage usage",
+		})
+		return
+	}
+
+	quota := storageQuota(c)
+	var remaining *int64
+	if quota > 0 {
+		rest := max(quota-usage.Bytes, 0)
+		remaining = &rest
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"used":      usage.Bytes,
+		"files":     usage.Files,
+		"quota":     optionalQuota(quota),
+		"remaining": remaining,
+		"pending":   pending,
+		"by_type":   byType,
+	})
+}
+
+// GetStorageReport ユーザーごと（多い順）と種類ごとの使用容量（管理者のみ）
+func GetStorageReport(c *gin.Context) {
+	db := database.GetDB()
+	page, perPage := parsePagination(c)
+
+	total, err := database.MediaStorageUsage(db, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch storage usage",
+		})
+		return
+	}
+	byType, err := database.MediaStorageUsageByType(db, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch storage usage",
+		})
+		return
+	}
+	users, err := database.CountMediaUploaders(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch storage usage",
+		})
+		return
+	}
+	byUser, err := database.MediaStorageUsageByUser(db, (page-1)*perPage, perPage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch storage usage",
+		})
+		return
+	}
+
+	type userUsage struct {
+		database.UserStorageUsage
+		Quota *int64 `json:"quota"`
+	}
+	report := make([]userUsage, len(byUser))
+	for i, usage := range byUser {
+		report[i] = userUsage{
+			UserStorageUsage: usage,
+			Quota:            optionalQuota(userStorageQuota(usage.StorageQuota, usage.Role)),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"used":     total.Bytes,
+		"files":    total.Files,
+		"by_type":  byType,
+		"by_user":  report,
+		"total":    users,
+		"page":     page,
+		"per_page": perPage,
+	})
+}
+
+// UpdateUserStorageQuota ユーザーの容量の上限を設定（管理者のみ）
+// quota はバイト数（0 で無制限）、null でロールの既定値に戻す
+func UpdateUserStorageQuota(c *gin.Context) {
+	var req struct {
+		Quota *int64 `json:"quota"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Quota != nil && *req.Quota < 0) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var user models.User
+	if err := db.First(&user, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+	if err := db.Model(&user).UpdateColumn("storage_quota", req.Quota).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update storage quota",
+		})
+		return
+	}
+	user.StorageQuota = req.Quota
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Storage quota updated successfully",
+		"user_id": user.ID,
+		"quota":   optionalQuota(userStorageQuota(user.StorageQuota, user.EffectiveRole())),
+	})
+}
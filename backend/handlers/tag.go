@@ -1,48 +1,112 @@
 package handlers
 
 import (
+	"blogapp/database"
+	"blogapp/models"
 	"net/http"
-	
+	"net/url"
+
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// GetTags すべてのタグを取得
+// tagWithCount 公開済み投稿数つきのタグ
+type tagWithCount struct {
+	models.Tag
+	PostCount int64 `json:"post_count"`
+}
+
+// GetTags すべてのタグを取得（公開済み投稿数つき）
 func GetTags(c *gin.Context) {
-	// TODO: 実際のデータベースから取得
+	db := database.GetDB()
+
+	var tags []models.Tag
+	if err := db.Order("name").Find(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch tags",
+		})
+		return
+	}
+
+	counts, err := publishedTagCounts(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count posts",
+		})
+		return
+	}
+
+	result := make([]tagWithCount, len(tags))
+	for i, tag := range tags {
+		result[i] = tagWithCount{Tag: tag, PostCount: counts[tag.ID]}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"tags": []gin.H{
-			{
-				"id":   1,
-				"name": "Go",
-				"slug": "go",
-			},
-			{
-				"id":   2,
-				"name": "TypeScript",
-				"slug": "typescript",
-			},
-		},
+		"tags": result,
 	})
 }
 
 // GetTag IDでタグを取得
 func GetTag(c *gin.Context) {
-	id := c.Param("id")
-	// TODO: 実際のデータベースから取得
+	db := database.GetDB()
+
+	var tag models.Tag
+	if err := db.Preload("Synonyms").First(&tag, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Tag not found",
+		})
+		return
+	}
+
+	counts, err := publishedTagCounts(db.Where("post_tags.tag_id = ?", tag.ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count posts",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"tag": gin.H{
-			"id":   id,
-			"name": "Go",
-			"slug": "go",
-		},
+		"tag": tagWithCount{Tag: tag, PostCount: counts[tag.ID]},
 	})
 }
 
+// GetTagPosts タグの公開済み投稿を取得（:id にはスラッグまたはID）
+func GetTagPosts(c *gin.Context) {
+	db := database.GetDB()
+
+	var tag models.Tag
+	if err := findBySlugOrID(db, &tag, c.Param("id")); err != nil {
+		// 名前の変更や統合で使われなくなったスラッグなら現在のスラッグへ案内する
+		if tagID, historyErr := models.FindSlugHistory(db, models.SlugEntityTag, c.Param("id")); historyErr == nil {
+			if db.First(&tag, tagID).Error == nil {
+				location := "/api/tags/" + url.PathEscape(tag.Slug) + "/posts"
+				c.Header("Location", location)
+				c.JSON(http.StatusMovedPermanently, gin.H{
+					"redirect_to": tag.Slug,
+					"location":    location,
+				})
+				return
+			}
+		}
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Tag not found",
+		})
+		return
+	}
+
+	query := db.Model(&models.Post{}).
+		Where("status = ?", models.PostStatusPublished).
+		Where("id IN (?)", db.Table("post_tags").Select("post_id").Where("tag_id = ?", tag.ID))
+	respondPosts(c, query, gin.H{"tag": tag})
+}
+
 // CreateTag 新しいタグを作成
 func CreateTag(c *gin.Context) {
 	var req struct {
 		Name string `json:"name" binding:"required"`
-		Slug string `json:"slug" binding:"required"`
+		Slug string `json:"slug"` // 省略時は名前から生成
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -52,23 +116,78 @@ func CreateTag(c *gin.Context) {
 		return
 	}
 
-	// TODO: 実際のデータベースに保存
+	db := database.GetDB()
+	if msg, err := tagNameConflict(db, req.Name, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create tag",
+		})
+		return
+	} else if msg != "" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	resolveTagSlug := func() (string, error) {
+		return resolveSlug(db, &models.Tag{}, req.Slug, req.Name, 0)
+	}
+	slugValue, err := resolveTagSlug()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate slug",
+		})
+		return
+	}
+	needsFallback := slugValue == ""
+	if needsFallback {
+		slugValue = pendingSlug()
+	}
+
+	tag := models.Tag{
+		Name: req.Name,
+		Slug: slugValue,
+	}
+
+	err = retrySlugConflict(&tag.Slug, resolveTagSlug, func() error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&tag).Error; err != nil {
+				return err
+			}
+			if needsFallback {
+				var err error
+				tag.Slug, err = assignFallbackSlug(tx, &tag, "tag", tag.ID)
+				return err
+			}
+			return nil
+		})
+	})
+	if isDuplicateKey(err) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Tag name or slug already exists",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create tag",
+		})
+		return
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Tag created successfully",
-		"tag": gin.H{
-			"id":   1,
-			"name": req.Name,
-			"slug": req.Slug,
-		},
+		"tag":     tag,
 	})
 }
 
 // UpdateTag タグを更新
+// 名前を変えてスラッグを指定しなかった場合は新しい名前からスラッグを作り直し、
+// 旧スラッグはリダイレクト用に、旧名は別名として残す
 func UpdateTag(c *gin.Context) {
-	id := c.Param("id")
 	var req struct {
-		Name string `json:"name"`
-		Slug string `json:"slug"`
+		Name *string `json:"name"`
+		Slug *string `json:"slug"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -78,24 +197,135 @@ func UpdateTag(c *gin.Context) {
 		return
 	}
 
-	// TODO: 実際のデータベースを更新
+	db := database.GetDB()
+	var tag models.Tag
+	if err := db.First(&tag, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Tag not found",
+		})
+		return
+	}
+
+	oldName := tag.Name
+	renamed := req.Name != nil && *req.Name != "" && *req.Name != tag.Name
+	if renamed {
+		if msg, err := tagNameConflict(db, *req.Name, tag.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update tag",
+			})
+			return
+		} else if msg != "" {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": msg,
+			})
+			return
+		}
+		tag.Name = *req.Name
+	}
+	requestedSlug := ""
+	if req.Slug != nil {
+		requestedSlug = *req.Slug
+	}
+	var resolveTagSlug func() (string, error)
+	if requestedSlug != "" || renamed {
+		resolveTagSlug = func() (string, error) {
+			return resolveSlug(db, &models.Tag{}, requestedSlug, tag.Name, tag.ID)
+		}
+		slugValue, err := resolveTagSlug()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to generate slug",
+			})
+			return
+		}
+		if slugValue != "" {
+			tag.Slug = slugValue
+		}
+	}
+
+	err := retrySlugConflict(&tag.Slug, resolveTagSlug, func() error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Omit(clause.Associations).Save(&tag).Error; err != nil {
+				return err
+			}
+			if renamed && models.NormalizeTagName(oldName) != models.NormalizeTagName(tag.Name) {
+				return database.AddTagSynonym(tx, tag.ID, oldName)
+			}
+			return nil
+		})
+	})
+	if isDuplicateKey(err) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Tag name or slug already exists",
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update tag",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Tag updated successfully",
-		"tag": gin.H{
-			"id":   id,
-			"name": req.Name,
-			"slug": req.Slug,
-		},
+		"tag":     tag,
 	})
 }
 
-// DeleteTag タグを削除
+// DeleteTag タグを削除（投稿との関連付けも外す）
 func DeleteTag(c *gin.Context) {
-	id := c.Param("id")
-	
-	// TODO: 実際のデータベースから削除
+	db := database.GetDB()
+
+	var tag models.Tag
+	if err := db.First(&tag, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Tag not found",
+		})
+		return
+	}
+
+	var affected int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("DELETE FROM post_tags WHERE tag_id = ?", tag.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		affected = result.RowsAffected
+		// 名前・スラッグの一意制約は論理削除済みの行も対象になるため物理削除する
+		return tx.Unscoped().Delete(&tag).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete tag",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Tag deleted successfully",
-		"id":      id,
+		"message":        "Tag deleted successfully",
+		"id":             tag.ID,
+		"affected_posts": affected,
 	})
 }
+
+// publishedTagCounts タグごとの公開済み投稿数（db に条件を付けて対象のタグを絞り込める）
+func publishedTagCounts(db *gorm.DB) (map[uint]int64, error) {
+	var rows []struct {
+		TagID uint
+		Count int64
+	}
+	if err := db.Table("post_tags").
+		Select("post_tags.tag_id, COUNT(*) AS count").
+		Joins("JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL").
+		Where("posts.status = ?", models.PostStatusPublished).
+		Group("post_tags.tag_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.TagID] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"blogapp/database"
+	"blogapp/models"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// MergeTags 複数のタグを :id のタグへ統合（編集者・管理者）
+// 投稿との関連付けは重複を除いて付け替え、元のタグ名は別名、元のスラッグはリダイレクト用に残す
+func MergeTags(c *gin.Context) {
+	var req struct {
+		SourceIDs []uint `json:"source_ids" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var target models.Tag
+	if err := db.First(&target, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Tag not found",
+		})
+		return
+	}
+
+	var moved int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		moved, err = database.MergeTags(tx, target.ID, req.SourceIDs)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to merge tags",
+		})
+		return
+	}
+
+	if err := db.Preload("Synonyms").First(&target, target.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch tag",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Tags merged successfully",
+		"tag":         target,
+		"moved_posts": moved,
+	})
+}
+
+// AddTagSynonym タグに別名を追加（編集者・管理者）
+func AddTagSynonym(c *gin.Context) {
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var tag models.Tag
+	if err := db.First(&tag, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Tag not found",
+		})
+		return
+	}
+
+	// 他のタグの名前や別名と同じ別名は入力の解決先が曖昧になるため拒否する
+	if existing, err := database.FindTagByName(db, req.Name); err == nil {
+		if existing.ID != tag.ID {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Name already belongs to tag: " + existing.Name,
+			})
+			return
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to add synonym",
+		})
+		return
+	}
+
+	synonym := models.NewTagSynonym(tag.ID, req.Name)
+	if synonym.Key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Synonym name is empty",
+		})
+		return
+	}
+	if err := db.Where(models.TagSynonym{Key: synonym.Key}).FirstOrCreate(synonym).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to add synonym",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Synonym added successfully",
+		"synonym": synonym,
+	})
+}
+
+// DeleteTagSynonym タグの別名を削除（編集者・管理者）
+func DeleteTagSynonym(c *gin.Context) {
+	result := database.GetDB().
+		Where("id = ? AND tag_id = ?", idParam(c, "synonymId"), idParam(c, "id")).
+		Delete(&models.TagSynonym{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete synonym",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Synonym not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Synonym deleted successfully",
+	})
+}
+
+// GetUnusedTags どの投稿にも付いていないタグの一覧（編集者・管理者）
+func GetUnusedTags(c *gin.Context) {
+	var tags []models.Tag
+	if err := database.UnusedTags(database.GetDB()).Order("name").Find(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch tags",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tags": tags,
+	})
+}
+
+// DeleteUnusedTags 未使用のタグをまとめて削除（編集者・管理者）
+// ids を指定した場合はその中の未使用のタグのみ削除する（使用中のものは skipped に返す）
+func DeleteUnusedTags(c *gin.Context) {
+	var req struct {
+		IDs []uint `json:"ids"`
+	}
+
+	// ボディを省略した場合はすべての未使用タグが対象
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	var deleted []uint
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		query := database.UnusedTags(tx)
+		if len(req.IDs) > 0 {
+			query = query.Where("id IN ?", req.IDs)
+		}
+		if err := query.Pluck("id", &deleted).Error; err != nil {
+			return err
+		}
+		if len(deleted) == 0 {
+			return nil
+		}
+		return tx.Unscoped().Delete(&models.Tag{}, deleted).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete tags",
+		})
+		return
+	}
+
+	deletedSet := make(map[uint]bool, len(deleted))
+	for _, id := range deleted {
+		deletedSet[id] = true
+	}
+	skipped := []uint{}
+	for _, id := range req.IDs {
+		if !deletedSet[id] {
+			skipped = append(skipped, id)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Unused tags deleted successfully",
+		"deleted": deleted,
+		"skipped": skipped,
+	})
+}
+
+// tagNameConflict 作成・名前変更しようとしているタグ名が既存のタグ名や別名と衝突するか
+// 衝突する場合はエラーメッセージを返す
+func tagNameConflict(db *gorm.DB, name string, excludeID uint) (string, error) {
+	taken, err := nameTaken(db, &models.Tag{}, name, excludeID)
+	if err != nil {
+		return "", err
+	}
+	if taken {
+		return "Tag name already exists", nil
+	}
+
+	existing, err := database.FindTagByName(db, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if existing.ID == excludeID {
+		return "", nil
+	}
+	return "Name already belongs to tag: " + existing.Name, nil
+}
+
+// postTags 投稿に付けるタグを ID とタグ名から集める
+// タグ名は別名を含めて既存のタグに解決し、見つからなければ新しく作成する
+func postTags(tx *gorm.DB, ids []uint, names []string) ([]models.Tag, error) {
+	var tags []models.Tag
+	if len(ids) > 0 {
+		if err := tx.Where("id IN ?", ids).Find(&tags).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[uint]bool, len(tags)+len(names))
+	for _, tag := range tags {
+		seen[tag.ID] = true
+	}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		tag, err := findOrCreateTag(tx, name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag.ID] {
+			seen[tag.ID] = true
+			tags = append(tags, *tag)
+		}
+	}
+	return tags, nil
+}
+
+// findOrCreateTag タグ名（別名）からタグを探し、なければ作成する
+func findOrCreateTag(tx *gorm.DB, name string) (*models.Tag, error) {
+	tag, err := database.FindTagByName(tx, name)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return tag, err
+	}
+
+	resolveTagSlug := func() (string, error) {
+		return resolveSlug(tx, &models.Tag{}, "", name, 0)
+	}
+	slugValue, err := resolveTagSlug()
+	if err != nil {
+		return nil, err
+	}
+	needsFallback := slugValue == ""
+	if needsFallback {
+		slugValue = pendingSlug()
+	}
+
+	// 呼び出し元のトランザクションの中なので、やり直せるようセーブポイント（入れ子のトランザクション）で作成
+	tag = &models.Tag{Name: name, Slug: slugValue}
+	err = retrySlugConflict(&tag.Slug, resolveTagSlug, func() error {
+		return tx.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(tag).Error; err != nil {
+				return err
+			}
+			if needsFallback {
+				var err error
+				tag.Slug, err = assignFallbackSlug(tx, tag, "tag", tag.ID)
+				return err
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"blogapp/database"
+	"blogapp/internal/tagmatch"
+	"blogapp/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tagCandidate 補完・提案の結果として返すタグ
+type tagCandidate struct {
+	ID      uint     `json:"id"`
+	Name    string   `json:"name"`
+	Slug    string   `json:"slug"`
+	Usage   int64    `json:"usage"`
+	Match   string   `json:"match,omitempty"`   // exact / prefix / contains / fuzzy
+	Matched []string `json:"matched,omitempty"` // 一致した名前・別名
+	Score   float64  `json:"score,omitempty"`
+}
+
+// AutocompleteTags タグ名の入力補完（?q=&limit=、使用数の多い順）
+func AutocompleteTags(c *gin.Context) {
+	query := c.Query("q")
+	limit := queryLimit(c, 10, 50)
+
+	candidates, slugs, err := loadTagCandidates(database.GetDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch tags",
+		})
+		return
+	}
+
+	result := []tagCandidate{}
+	for _, m := range tagmatch.Complete(query, candidates, limit) {
+		result = append(result, tagCandidate{
+			ID:      m.ID,
+			Name:    m.Name,
+			Slug:    slugs[m.ID],
+			Usage:   m.Usage,
+			Match:   m.Kind,
+			Matched: []string{m.Matched},
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query": query,
+		"tags":  result,
+	})
+}
+
+// SuggestTags 投稿のタイトルと本文から既存のタグを提案
+// post_id を指定した場合、title・content を省略するとその投稿の内容を使い、既に付いているタグは除く
+func SuggestTags(c *gin.Context) {
+	var req struct {
+		PostID  uint   `json:"post_id"`
+		Title   string `json:"title"`
+		Content string `json:"content"`
+		TagIDs  []uint `json:"tag_ids"` // 既に選択済みのタグ（提案から除く）
+		Limit   int    `json:"limit"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	exclude := make(map[uint]bool, len(req.TagIDs))
+	for _, id := range req.TagIDs {
+		exclude[id] = true
+	}
+
+	if req.PostID != 0 {
+		var post models.Post
+		if err := db.Preload("Tags").First(&post, req.PostID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Post not found",
+			})
+			return
+		}
+		if !canEditPost(c, &post) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "You are not allowed to access this post",
+			})
+			return
+		}
+		if req.Title == "" && req.Content == "" {
+			req.Title, req.Content = post.Title, post.Content
+		}
+		for _, tag := range post.Tags {
+			exclude[tag.ID] = true
+		}
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > 20 {
+		limit = 10
+	}
+
+	candidates, slugs, err := loadTagCandidates(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch tags",
+		})
+		return
+	}
+
+	result := []tagCandidate{}
+	for _, s := range tagmatch.Suggest(req.Title, req.Content, candidates, exclude, limit) {
+		result = append(result, tagCandidate{
+			ID:      s.ID,
+			Name:    s.Name,
+			Slug:    slugs[s.ID],
+			Usage:   s.Usage,
+			Matched: s.Matched,
+			Score:   s.Score,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tags": result,
+	})
+}
+
+// loadTagCandidates すべてのタグを別名と使用数（削除されていない投稿の数）つきで読み込む
+func loadTagCandidates(db *gorm.DB) ([]tagmatch.Candidate, map[uint]string, error) {
+	var tags []models.Tag
+	if err := db.Preload("Synonyms").Find(&tags).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var rows []struct {
+		TagID uint
+		Count int64
+	}
+	if err := db.Table("post_tags").
+		Select("post_tags.tag_id, COUNT(*) AS count").
+		Joins("JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL").
+		Group("post_tags.tag_id").
+		Scan(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	usage := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		usage[row.TagID] = row.Count
+	}
+
+	candidates := make([]tagmatch.Candidate, len(tags))
+	slugs := make(map[uint]string, len(tags))
+	for i, tag := range tags {
+		synonyms := make([]string, len(tag.Synonyms))
+		for j, synonym := range tag.Synonyms {
+			synonyms[j] = synonym.Name
+		}
+		candidates[i] = tagmatch.Candidate{
+			ID:       tag.ID,
+			Name:     tag.Name,
+			Synonyms: synonyms,
+			Usage:    usage[tag.ID],
+		}
+		slugs[tag.ID] = tag.Slug
+	}
+	return candidates, slugs, nil
+}
+
+// queryLimit ?limit= を解釈（範囲外なら既定値）
+func queryLimit(c *gin.Context, defaultLimit, maxLimit int) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 || limit > maxLimit {
+		return defaultLimit
+	}
+	return limit
+}
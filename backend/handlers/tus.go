@@ -0,0 +1,571 @@
+package handlers
+
+import (
+	"blogapp/database"
+	"blogapp/internal/upload"
+	"blogapp/models"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tus 1.0 の再開可能なアップロード（https://tus.io/protocols/resumable-upload）
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,creation-with-upload,expiration,termination"
+	tusContentType = "application/offset+octet-stream"
+)
+
+var (
+	errTusLengthRequired = errors.New("tus: invalid Upload-Length")
+	errTusOffsetRequired = errors.New("tus: invalid Upload-Offset")
+	errTusOffsetMismatch = errors.New("tus: Upload-Offset does not match the current offset")
+	errTusChunkTooLarge  = errors.New("tus: chunk exceeds Upload-Length")
+
+	// errTusNotLocked アップロードを取得・ロックできなかった（レスポンスは書いてあるのでロールバックするだけ）
+	errTusNotLocked = errors.New("tus: upload not locked")
+)
+
+// TusOptions 対応しているバージョン・拡張・大きさの上限を返す（OPTIONS /api/uploads）
+func TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(tusUploadPolicy(c).MaxSize, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// CreateTusUpload アップロードを作成（POST /api/uploads、Upload-Length と Upload-Metadata の filename が必須）
+// 本文にデータがあれば最初のチャンクとして受け取る（creation-with-upload）
+func CreateTusUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	cfg := getConfig()
+
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Upload-Defer-Length is not supported",
+		})
+		return
+	}
+	policy := tusUploadPolicy(c)
+	length, err := parseTusLength(c.GetHeader("Upload-Length"), policy.MaxSize)
+	if errors.Is(err, upload.ErrTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("File size exceeds maximum limit of %s", formatSize(policy.MaxSize)),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Upload-Length is required",
+		})
+		return
+	}
+
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid Upload-Metadata",
+		})
+		return
+	}
+	filename := strings.TrimSpace(metadata["filename"])
+	if filename == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "filename metadata is required",
+		})
+		return
+	}
+	// 中身はすべて受け取ってから検査するが、許可されていない拡張子はここで断る
+	if !allowsExtension(policy, filepath.Ext(filename)) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":         "File type not allowed",
+			"allowed_types": policy.AllowedTypes,
+		})
+		return
+	}
+
+	// 受け取り途中のアップロードも含めて容量の上限を超えるなら作成しない
+	if quota := storageQuota(c); quota > 0 {
+		db := database.GetDB()
+		pending, err := database.PendingUploadBytes(db, currentUserID(c))
+		if err == nil {
+			err = checkStorageQuota(db, currentUserID(c), pending+length, quota)
+		}
+		if err != nil {
+			respondUploadError(c, err, policy)
+			return
+		}
+	}
+
+	id, err := generateSecureToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create upload",
+		})
+		return
+	}
+	if err := os.MkdirAll(cfg.TusUploadDir, 0o755); err != nil {
+		log.Printf("Failed to create tus upload directory: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create upload",
+		})
+		return
+	}
+	f, err := os.OpenFile(tusFilePath(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create upload",
+		})
+		return
+	}
+	f.Close()
+
+	tusUpload := models.TusUpload{
+		ID:        id,
+		UserID:    currentUserID(c),
+		Length:    length,
+		Filename:  truncateUTF8(filename, 255),
+		AltText:   truncateUTF8(metadata["alt_text"], 500),
+		Caption:   metadata["caption"],
+		ExpiresAt: time.Now().Add(cfg.TusUploadExpiry),
+	}
+	if err := database.GetDB().Create(&tusUpload).Error; err != nil {
+		os.Remove(tusFilePath(id))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create upload",
+		})
+		return
+	}
+
+	c.Header("Location", cfg.APIBaseURL+"/api/uploads/"+id)
+	if c.ContentType() != tusContentType || c.Request.ContentLength == 0 {
+		setTusOffsetHeaders(c, &tusUpload)
+		c.Status(http.StatusCreated)
+		return
+	}
+	lockTusUpload(c, id, func(tx *gorm.DB, tusUpload *models.TusUpload) {
+		if writeTusChunk(c, tx, tusUpload) {
+			setTusOffsetHeaders(c, tusUpload)
+			c.Status(http.StatusCreated)
+		}
+	})
+}
+
+// HeadTusUpload 受け取ったバイト数を返す（HEAD /api/uploads/:id）
+func HeadTusUpload(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Cache-Control", "no-store")
+	tusUpload, ok := loadTusUpload(c, database.GetDB(), c.Param("id"))
+	if !ok {
+		return
+	}
+	setTusOffsetHeaders(c, tusUpload)
+	c.Status(http.StatusOK)
+}
+
+// PatchTusUpload チャンクを受け取って追記する（PATCH /api/uploads/:id）
+// Upload-Offset が受け取ったバイト数と一致しない・完了済みなら 409、同じアップロードへの別のリクエストを処理中なら 423、
+// すべて受け取ったら検査してメディアとして保存する
+func PatchTusUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	if c.ContentType() != tusContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": "Content-Type must be " + tusContentType,
+		})
+		return
+	}
+
+	lockTusUpload(c, c.Param("id"), func(tx *gorm.DB, tusUpload *models.TusUpload) {
+		// 完了したアップロードの受け取り途中のファイルは削除済み（最後の PATCH の再送など）
+		if tusUpload.Completed() {
+			c.Header("Upload-Offset", strconv.FormatInt(tusUpload.Offset, 10))
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Upload has already completed",
+			})
+			return
+		}
+		if err := checkTusOffset(c.GetHeader("Upload-Offset"), tusUpload.Offset); err != nil {
+			if errors.Is(err, errTusOffsetMismatch) {
+				c.Header("Upload-Offset", strconv.FormatInt(tusUpload.Offset, 10))
+				c.JSON(http.StatusConflict, gin.H{
+					"error": "Upload-Offset does not match the current offset",
+				})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Upload-Offset is required",
+			})
+			return
+		}
+
+		if writeTusChunk(c, tx, tusUpload) {
+			setTusOffsetHeaders(c, tusUpload)
+			c.Status(http.StatusNoContent)
+		}
+	})
+}
+
+// DeleteTusUpload アップロードを中止して受け取ったデータを削除（DELETE /api/uploads/:id）
+func DeleteTusUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	var deleted bool
+	lockTusUpload(c, c.Param("id"), func(tx *gorm.DB, tusUpload *models.TusUpload) {
+		if err := tx.Delete(tusUpload).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to delete upload",
+			})
+			return
+		}
+		deleted = true
+	})
+	if deleted {
+		// 行の削除を確定してからファイルを消す（PATCH はもうこのアップロードを見つけられない）
+		removeTusFile(c.Param("id"))
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetTusUpload アップロードの状態（完了していれば作成したメディア）を JSON で返す（GET /api/uploads/:id）
+func GetTusUpload(c *gin.Context) {
+	var tusUpload models.TusUpload
+	if err := database.GetDB().Preload("Media").Preload("Media.Variants", orderMediaVariants).
+		Where("id = ? AND user_id = ?", c.Param("id"), currentUserID(c)).
+		First(&tusUpload).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Upload not found",
+		})
+		return
+	}
+	if tusUpload.Media != nil {
+		if err := setMediaURLs(tusUpload.Media); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Storage is not available",
+			})
+			return
+		}
+	}
+
+	status := "uploading"
+	switch {
+	case tusUpload.Completed():
+		status = "completed"
+	case tusUpload.Error != "":
+		status = "failed"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": status,
+		"upload": tusUpload,
+	})
+}
+
+// writeTusChunk 本文を追記して受け取ったバイト数を更新し、最後のチャンクならメディアとして保存する
+// tx は lockTusUpload のトランザクション。接続が途中で切れても受け取った分は残す（エラーならレスポンスを書いて false）
+func writeTusChunk(c *gin.Context, tx *gorm.DB, tusUpload *models.TusUpload) bool {
+	cfg := getConfig()
+	written, copyErr := appendTusChunk(tusFilePath(tusUpload.ID), tusUpload.Offset, tusUpload.Length, c.Request.Body, c.Request.ContentLength)
+	if errors.Is(copyErr, errTusChunkTooLarge) && written == 0 {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "Chunk exceeds Upload-Length",
+		})
+		return false
+	}
+	if errors.Is(copyErr, os.ErrNotExist) {
+		respondTusFileGone(c)
+		return false
+	}
+
+	tusUpload.Offset += written
+	tusUpload.ExpiresAt = time.Now().Add(cfg.TusUploadExpiry)
+	if err := tx.Model(tusUpload).Updates(map[string]interface{}{
+		"upload_offset": tusUpload.Offset,
+		"expires_at":    tusUpload.ExpiresAt,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to write chunk",
+		})
+		return false
+	}
+	if copyErr != nil {
+		c.Header("Upload-Offset", strconv.FormatInt(tusUpload.Offset, 10))
+		if errors.Is(copyErr, errTusChunkTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": "Chunk exceeds Upload-Length",
+			})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to write chunk",
+		})
+		return false
+	}
+
+	if tusUpload.Offset == tusUpload.Length && !tusUpload.Completed() {
+		return completeTusUpload(c, tx, tusUpload)
+	}
+	return true
+}
+
+// completeTusUpload 受け取ったファイルを通常のアップロードと同じように検査・保存してメディアを作成する
+// 検査で拒否した場合はデータを削除し、保存に失敗した場合や容量の上限を超えた場合は空の PATCH でやり直せるように残す。
+// ロックしている行は tx で更新する（ほかの接続からは更新を待ち続けることになる）
+func completeTusUpload(c *gin.Context, tx *gorm.DB, tusUpload *models.TusUpload) bool {
+	store, err := getStorage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Storage is not available",
+		})
+		return false
+	}
+	f, err := os.Open(tusFilePath(tusUpload.ID))
+	if errors.Is(err, os.ErrNotExist) {
+		respondTusFileGone(c)
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to save file",
+		})
+		return false
+	}
+	defer f.Close()
+
+	policy := tusUploadPolicy(c)
+	media, _, err := saveUpload(c.Request.Context(), store, f, mediaUpload{
+		UploaderID: tusUpload.UserID,
+		Filename:   tusUpload.Filename,
+		AltText:    tusUpload.AltText,
+		Caption:    tusUpload.Caption,
+		Policy:     policy,
+		Quota:      storageQuota(c),
+	})
+	if err != nil {
+		var rejected *uploadRejectedError
+		if errors.As(err, &rejected) {
+			tusUpload.Error = truncateUTF8(rejected.message, 255)
+			tx.Model(tusUpload).UpdateColumn("error", tusUpload.Error)
+			removeTusFile(tusUpload.ID)
+		}
+		respondUploadError(c, err, policy)
+		return false
+	}
+
+	tusUpload.MediaID = &media.ID
+	if err := tx.Model(tusUpload).UpdateColumn("media_id", media.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to save file",
+		})
+		return false
+	}
+	removeTusFile(tusUpload.ID)
+	c.Header("Upload-Media-Id", strconv.FormatUint(uint64(media.ID), 10))
+	return true
+}
+
+// lockTusUpload 自分のアップロードの行を SELECT ... FOR UPDATE でロックし、トランザクションの中で fn を呼ぶ
+// ロックは行にかけるので、どのレプリカに届いたリクエストでも同じアップロードへの書き込みは1つずつになる。
+// ほかのリクエストがロックしていれば待たずに 423 を返す（クライアントは HEAD で受け取ったバイト数を確かめてやり直す）
+func lockTusUpload(c *gin.Context, id string, fn func(tx *gorm.DB, tusUpload *models.TusUpload)) {
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var tusUpload models.TusUpload
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ? AND user_id = ?", id, currentUserID(c)).
+			First(&tusUpload).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// ロックされていて読み飛ばしたのか、ないのかを確かめる（なければ 404 / 410）
+			if _, ok := loadTusUpload(c, tx, id); ok {
+				c.JSON(http.StatusLocked, gin.H{
+					"error": "Upload is being written by another request",
+				})
+			}
+			return errTusNotLocked
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch upload",
+			})
+			return errTusNotLocked
+		}
+		if !checkTusUploadActive(c, &tusUpload) {
+			return errTusNotLocked
+		}
+		fn(tx, &tusUpload)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errTusNotLocked) {
+		// レスポンスは書いてある。受け取ったバイト数を記録できなかった場合は、次の PATCH が 409 になり HEAD からやり直される
+		log.Printf("Failed to commit tus upload %s: %v", id, err)
+	}
+}
+
+// loadTusUpload 自分のアップロードを取得（なければ 404、期限切れ・拒否済みなら 410 を返して false）
+func loadTusUpload(c *gin.Context, db *gorm.DB, id string) (*models.TusUpload, bool) {
+	var tusUpload models.TusUpload
+	if err := db.Where("id = ? AND user_id = ?", id, currentUserID(c)).First(&tusUpload).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Upload not found",
+		})
+		return nil, false
+	}
+	if !checkTusUploadActive(c, &tusUpload) {
+		return nil, false
+	}
+	return &tusUpload, true
+}
+
+// checkTusUploadActive 期限切れ・拒否済みでないか（そうなら 410 を返して false）
+func checkTusUploadActive(c *gin.Context, tusUpload *models.TusUpload) bool {
+	if tusUpload.Error != "" || (!tusUpload.Completed() && time.Now().After(tusUpload.ExpiresAt)) {
+		c.JSON(http.StatusGone, gin.H{
+			"error": "Upload has expired or was rejected",
+		})
+		return false
+	}
+	return true
+}
+
+// respondTusFileGone 受け取り途中のファイルがない（削除済み、または TUS_UPLOAD_DIR をレプリカで共有していない）
+func respondTusFileGone(c *gin.Context) {
+	c.JSON(http.StatusGone, gin.H{
+		"error": "Upload data is no longer available",
+	})
+}
+
+// checkTusResumable クライアントのプロトコルのバージョンを確認（違えば 412 を返して false）
+func checkTusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "Unsupported tus version",
+		})
+		return false
+	}
+	return true
+}
+
+func setTusOffsetHeaders(c *gin.Context, tusUpload *models.TusUpload) {
+	c.Header("Upload-Offset", strconv.FormatInt(tusUpload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(tusUpload.Length, 10))
+	if !tusUpload.Completed() {
+		c.Header("Upload-Expires", tusUpload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// tusUploadPolicy ロールの制限を使い、大きさの上限は TUS_MAX_SIZE を超えないようにする
+func tusUploadPolicy(c *gin.Context) upload.Policy {
+	cfg := getConfig()
+	policy := cfg.UploadPolicy(currentUserRole(c))
+	policy.MaxSize = min(policy.MaxSize, cfg.TusMaxSize)
+	return policy
+}
+
+// parseTusLength Upload-Length を解析する（max を超えたら upload.ErrTooLarge）
+func parseTusLength(header string, max int64) (int64, error) {
+	length, err := strconv.ParseInt(header, 10, 64)
+	if err != nil || length <= 0 {
+		return 0, errTusLengthRequired
+	}
+	if length > max {
+		return 0, upload.ErrTooLarge
+	}
+	return length, nil
+}
+
+// checkTusOffset Upload-Offset が受け取ったバイト数（current）と一致するか
+func checkTusOffset(header string, current int64) error {
+	offset, err := strconv.ParseInt(header, 10, 64)
+	if err != nil || offset < 0 {
+		return errTusOffsetRequired
+	}
+	if offset != current {
+		return errTusOffsetMismatch
+	}
+	return nil
+}
+
+// appendTusChunk 受け取り途中のファイルの offset から body を書き込み、書き込んだバイト数を返す
+// 全体で length を超える分は書かずに errTusChunkTooLarge を返す（contentLength で分かれば何も書かない）。
+// 途中で読み込みに失敗しても書き込んだ分は返すので、クライアントはその続きから再開できる
+func appendTusChunk(path string, offset, length int64, body io.Reader, contentLength int64) (int64, error) {
+	remaining := length - offset
+	if contentLength > remaining {
+		return 0, errTusChunkTooLarge
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(io.NewOffsetWriter(f, offset), io.LimitReader(body, remaining))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written == remaining {
+		// 長さを送らない（chunked の）リクエストで Upload-Length より多く送られた
+		if n, _ := body.Read(make([]byte, 1)); n > 0 {
+			err = errTusChunkTooLarge
+		}
+	}
+	return written, err
+}
+
+// allowsExtension 許可された種類のいずれかの拡張子か
+func allowsExtension(policy upload.Policy, ext string) bool {
+	ext = strings.ToLower(ext)
+	for _, mimeType := range policy.AllowedTypes {
+		for _, allowed := range upload.Types[mimeType] {
+			if allowed == ext {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseTusMetadata Upload-Metadata（"key base64値,key base64値"）を解析
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || key == "" {
+			return nil, errors.New("invalid metadata")
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// tusFilePath 受け取り途中のファイルの場所
+func tusFilePath(id string) string {
+	return filepath.Join(getConfig().TusUploadDir, filepath.Base(id))
+}
+
+func removeTusFile(id string) {
+	if err := os.Remove(tusFilePath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to remove tus upload %s: %v", id, err)
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"blogapp/config"
+	"blogapp/internal/upload"
+	"blogapp/models"
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseTusLength(t *testing.T) {
+	tests := []struct {
+		header string
+		want   int64
+		err    error
+	}{
+		{"1", 1, nil},
+		{"1024", 1024, nil},
+		{"", 0, errTusLengthRequired},
+		{"0", 0, errTusLengthRequired},
+		{"-1", 0, errTusLengthRequired},
+		{"1e3", 0, errTusLengthRequired},
+		{"99999999999999999999", 0, errTusLengthRequired},
+		{strconv.FormatInt(math.MaxInt64, 10), 0, upload.ErrTooLarge},
+		{"1025", 0, upload.ErrTooLarge},
+	}
+	for _, tt := range tests {
+		got, err := parseTusLength(tt.header, 1024)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("parseTusLength(%q) = %d, %v; want %d, %v", tt.header, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestCheckTusOffset(t *testing.T) {
+	tests := []struct {
+		header  string
+		current int64
+		err     error
+	}{
+		{"0", 0, nil},
+		{"512", 512, nil},
+		{"0", 512, errTusOffsetMismatch},
+		{"1024", 512, errTusOffsetMismatch},
+		{"", 0, errTusOffsetRequired},
+		{"-1", 0, errTusOffsetRequired},
+		{"abc", 0, errTusOffsetRequired},
+		{"99999999999999999999", 0, errTusOffsetRequired},
+	}
+	for _, tt := range tests {
+		if err := checkTusOffset(tt.header, tt.current); !errors.Is(err, tt.err) {
+			t.Errorf("checkTusOffset(%q, %d) = %v, want %v", tt.header, tt.current, err, tt.err)
+		}
+	}
+}
+
+// brokenReader n バイト読んだところで接続が切れたように失敗する
+type brokenReader struct {
+	r io.Reader
+}
+
+func (b *brokenReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func TestAppendTusChunkResume(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	length := int64(len(content))
+	path := filepath.Join(t.TempDir(), "upload")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// 最初のチャンクの途中で接続が切れても、受け取った分は残る
+	written, err := appendTusChunk(path, 0, length, &brokenReader{bytes.NewReader(content[:7])}, 12)
+	if err == nil || written != 7 {
+		t.Fatalf("interrupted chunk = %d, %v; want 7 bytes and an error", written, err)
+	}
+	offset := written
+
+	// 受け取ったバイト数から再開する
+	written, err = appendTusChunk(path, offset, length, bytes.NewReader(content[offset:15]), 15-offset)
+	if err != nil || written != 15-offset {
+		t.Fatalf("resumed chunk = %d, %v", written, err)
+	}
+	offset += written
+
+	// 長さを送らないリクエスト
+	written, err = appendTusChunk(path, offset, length, bytes.NewReader(content[offset:]), -1)
+	if err != nil || offset+written != length {
+		t.Fatalf("last chunk = %d, %v", written, err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("file = %q, %v; want %q", got, err, content)
+	}
+}
+
+func TestAppendTusChunkOverflow(t *testing.T) {
+	tests := []struct {
+		name          string
+		offset        int64
+		body          string
+		contentLength int64
+		written       int64
+		file          string
+	}{
+		{"declared length over Upload-Length", 6, "67890", 5, 0, "012345"},
+		{"chunked body over Upload-Length", 6, "6789xx", -1, 4, "0123456789"},
+		{"data after the last byte", 10, "x", -1, 0, "0123456789"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "upload")
+			if err := os.WriteFile(path, []byte("0123456789"[:tt.offset]), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			written, err := appendTusChunk(path, tt.offset, 10, strings.NewReader(tt.body), tt.contentLength)
+			if !errors.Is(err, errTusChunkTooLarge) || written != tt.written {
+				t.Errorf("appendTusChunk = %d, %v; want %d, errTusChunkTooLarge", written, err, tt.written)
+			}
+			if got, _ := os.ReadFile(path); string(got) != tt.file {
+				t.Errorf("file = %q, want %q", got, tt.file)
+			}
+		})
+	}
+}
+
+// 完了して受け取り途中のファイルを消したアップロードや、ファイルを共有していないレプリカへの PATCH は 410
+func TestWriteTusChunkMissingFile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	saved := appConfig
+	t.Cleanup(func() { appConfig = saved })
+	dir := t.TempDir()
+	appConfig = &config.Config{TusUploadDir: dir}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPatch, "/api/uploads/gone", strings.NewReader("data"))
+	if writeTusChunk(c, nil, &models.TusUpload{ID: "gone", Length: 10}) {
+		t.Fatal("writeTusChunk = true, want false")
+	}
+	if w.Code != http.StatusGone {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusGone)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gone")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("writeTusChunk created the missing file: %v", err)
+	}
+}
+
+func TestSetTusOffsetHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	expires := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mediaID := uint(1)
+
+	tests := []struct {
+		name    string
+		upload  models.TusUpload
+		expires string
+	}{
+		{"uploading", models.TusUpload{Length: 100, Offset: 40, ExpiresAt: expires}, "Wed, 01 May 2024 12:00:00 GMT"},
+		{"completed", models.TusUpload{Length: 100, Offset: 100, ExpiresAt: expires, MediaID: &mediaID}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodHead, "/api/uploads/x", nil)
+			setTusOffsetHeaders(c, &tt.upload)
+
+			h := w.Header()
+			if h.Get("Upload-Offset") != strconv.FormatInt(tt.upload.Offset, 10) || h.Get("Upload-Length") != "100" {
+				t.Errorf("headers = %v", h)
+			}
+			if got := h.Get("Upload-Expires"); got != tt.expires {
+				t.Errorf("Upload-Expires = %q, want %q", got, tt.expires)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// nameTaken 同じ名前のレコードが既にあるか（一意インデックスは論理削除済みの行も対象なので Unscoped で調べる）
+func nameTaken(db *gorm.DB, model interface{}, name string, excludeID uint) (bool, error) {
+	var count int64
+	query := db.Unscoped().Model(model).Where("name = ?", name)
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// isDuplicateKey 一意制約違反かどうか（事前チェック後に競合した場合）
+func isDuplicateKey(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
@@ -1,74 +1,323 @@
 package handlers
 
 import (
+	"blogapp/database"
+	"blogapp/internal/imageproc"
+	"blogapp/internal/storage"
+	"blogapp/internal/upload"
+	"blogapp/models"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"path/filepath"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // UploadFile ファイルをアップロード
+// 種類は拡張子ではなく中身から判定し、ロールごとに許可された種類と大きさ（UPLOAD_ALLOWED_TYPES / UPLOAD_MAX_SIZE）に制限する。
+// 保存するファイル名は中身のハッシュから作り（クライアントのファイル名は使わない）、メディアライブラリに登録する
 func UploadFile(c *gin.Context) {
+	policy := getConfig().UploadPolicy(currentUserRole(c))
+
+	// マルチパートのヘッダーなどの分の余裕を持たせてリクエストの大きさを制限
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, policy.MaxSize+1<<20)
+
 	// フォームからファイルを取得
 	file, err := c.FormFile("file")
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("File size exceeds maximum limit of %s", formatSize(policy.MaxSize)),
+			})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "No file uploaded",
 		})
 		return
 	}
 
-	// ファイルサイズをチェック (例: 10MB まで)
-	maxSize := int64(10 << 20) // 10MB
-	if file.Size > maxSize {
+	if file.Size > policy.MaxSize {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "File size exceeds maximum limit of 10MB",
+			"error": fmt.Sprintf("File size exceeds maximum limit of %s", formatSize(policy.MaxSize)),
 		})
 		return
 	}
 
-	// ファイル拡張子をチェック
-	ext := filepath.Ext(file.Filename)
-	allowedExtensions := map[string]bool{
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".gif":  true,
-		".webp": true,
-		".pdf":  true,
-	}
-
-	if !allowedExtensions[ext] {
+	src, err := file.Open()
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "File type not allowed",
+			"error": "Failed to read file",
 		})
 		return
 	}
+	defer src.Close()
 
-	// ユニークなファイル名を生成
-	timestamp := time.Now().Unix()
-	filename := fmt.Sprintf("%d_%s", timestamp, file.Filename)
-	
-	// アップロードディレクトリ (TODO: 環境変数で設定)
-	uploadPath := filepath.Join("./uploads", filename)
-
-	// ファイルを保存
-	if err := c.SaveUploadedFile(file, uploadPath); err != nil {
+	store, err := getStorage()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to save file",
+			"error": "Storage is not available",
 		})
 		return
 	}
 
-	// ファイルのURLを返す (TODO: 実際のドメインを使用)
-	fileURL := fmt.Sprintf("/uploads/%s", filename)
+	media, created, err := saveUpload(c.Request.Context(), store, src, mediaUpload{
+		UploaderID: currentUserID(c),
+		Filename:   file.Filename,
+		AltText:    c.PostForm("alt_text"),
+		Caption:    c.PostForm("caption"),
+		Policy:     policy,
+		Quota:      storageQuota(c),
+	})
+	if err != nil {
+		respondUploadError(c, err, policy)
+		return
+	}
+	if !created {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "File already uploaded",
+			"media":   media,
+			"url":     media.URL,
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":  "File uploaded successfully",
-		"filename": filename,
-		"url":      fileURL,
-		"size":     file.Size,
+		"message":       "File uploaded successfully",
+		"media":         media,
+		"filename":      media.Filename,
+		"original_name": media.OriginalName,
+		"key":           media.Key,
+		"url":           media.URL,
+		"size":          media.Size,
+		"content_type":  media.MIME,
+		"sha256":        media.Hash,
+		"variants":      media.Variants,
+		"srcset":        media.Srcset,
+	})
+}
+
+// mediaUpload アップロードされたファイルの情報（通常のアップロードと tus で共通）
+type mediaUpload struct {
+	UploaderID uint
+	Filename   string // クライアントが送ったファイル名（拡張子の確認と表示に使う）
+	AltText    string
+	Caption    string
+	Policy     upload.Policy
+	Quota      int64 // 容量の上限（0 は無制限）
+}
+
+// uploadRejectedError 検査で拒否したファイル（400 を返す）
+type uploadRejectedError struct {
+	err     error
+	message string
+}
+
+func (e *uploadRejectedError) Error() string { return e.message }
+func (e *uploadRejectedError) Unwrap() error { return e.err }
+
+// saveUpload ファイルを検査（マルウェア検査を含む）して保存し、メディアライブラリに登録する
+// 年月ごとのディレクトリにアップロードしたユーザーと中身のハッシュから作った名前で保存し、
+// 同じユーザーが同じ月に同じ中身のファイルをアップロード済みならそのメディアを返す（created は false）。
+// 別のユーザーのファイルとは共有しないので、容量の計算やマルウェア検査の記録はユーザーごとに行われる
+func saveUpload(ctx context.Context, store storage.Storage, src io.ReadSeeker, u mediaUpload) (*models.Media, bool, error) {
+	// 中身の検査（種類・拡張子との一致・埋め込まれたスクリプト）
+	result, err := upload.Inspect(src, u.Filename, u.Policy)
+	if err != nil {
+		return nil, false, &uploadRejectedError{err: err, message: uploadErrorMessage(err, u.Policy)}
+	}
+
+	db := database.GetDB()
+	key := models.MediaKey(time.Now(), result.Name(u.UploaderID))
+	var media models.Media
+	err = db.Preload("Variants", orderMediaVariants).Where("key = ?", key).First(&media).Error
+	if err == nil {
+		media.SetURLs(store.URL)
+		return &media, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	// マルウェア検査（検出したものは保存しない）
+	if err := scanUpload(ctx, src, u, result, key); err != nil {
+		return nil, false, err
+	}
+
+	// 画像は位置情報などのメタデータを取り除いて保存し、縮小画像も作る
+	var processed *imageproc.Result
+	if imageproc.Supports(result.MIME) {
+		if result.Size > getConfig().ImageMaxSourceSize {
+			return nil, false, &uploadRejectedError{err: imageproc.ErrTooLarge, message: "Image file is too large to process"}
+		}
+		data, err := io.ReadAll(src)
+		if err == nil {
+			processed, err = imageproc.Process(data, result.MIME, getConfig().ImageOptions())
+		}
+		if err != nil {
+			message := "Failed to process image"
+			if errors.Is(err, imageproc.ErrTooLarge) {
+				message = "Image dimensions are too large"
+			}
+			return nil, false, &uploadRejectedError{err: err, message: message}
+		}
+	}
+
+	// 保存する前に容量を確認する（登録する直前にも確認し直す）
+	size := result.Size
+	if processed != nil {
+		size = processed.Size()
+	}
+	if err := checkStorageQuota(db, u.UploaderID, size, u.Quota); err != nil {
+		return nil, false, err
+	}
+
+	media = models.Media{
+		UploaderID:   u.UploaderID,
+		Key:          key,
+		Filename:     result.Name(u.UploaderID),
+		OriginalName: truncateUTF8(u.Filename, 255),
+		MIME:         result.MIME,
+		Size:         result.Size,
+		Width:        result.Width,
+		Height:       result.Height,
+		Hash:         result.Hash,
+		AltText:      truncateUTF8(u.AltText, 500),
+		Caption:      u.Caption,
+	}
+	if err := storeMediaFiles(ctx, store, &media, src, processed); err != nil {
+		return nil, false, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 同じユーザーのアップロードが同時に上限を超えないように、ユーザーの行をロックしてから確認する
+		if u.Quota > 0 {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", u.UploaderID).Find(&models.User{}).Error; err != nil {
+				return err
+			}
+			if err := checkStorageQuota(tx, u.UploaderID, media.TotalSize(), u.Quota); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&media).Error
 	})
+	if err != nil {
+		// 同じファイルが同時にアップロードされた場合は先に登録されたほうを返す
+		if !isDuplicateKey(err) {
+			// 容量の超過・DB のエラーなどで登録できなかった場合は、同じファイルを別のリクエストが登録していなければ保存したファイルを消す
+			var count int64
+			if db.Model(&models.Media{}).Where("key = ?", key).Count(&count).Error == nil && count == 0 {
+				deleteMediaFiles(context.WithoutCancel(ctx), store, &media)
+			}
+			return nil, false, err
+		}
+		if err := db.Preload("Variants", orderMediaVariants).Where("key = ?", key).First(&media).Error; err != nil {
+			return nil, false, err
+		}
+		media.SetURLs(store.URL)
+		return &media, false, nil
+	}
+	media.SetURLs(store.URL)
+	return &media, true, nil
+}
+
+// storeMediaFiles 元のファイル（画像ならメタデータを取り除いたもの）と縮小画像を保存し、media に大きさと縮小画像をセットする
+// 途中で失敗した場合は保存したファイルを消す
+func storeMediaFiles(ctx context.Context, store storage.Storage, media *models.Media, src io.Reader, processed *imageproc.Result) error {
+	if processed == nil {
+		return store.Put(ctx, media.Key, src, media.Size, media.MIME)
+	}
+
+	original := processed.Original
+	media.Size = int64(len(original.Data))
+	media.Width, media.Height = original.Width, original.Height
+	if err := store.Put(ctx, media.Key, bytes.NewReader(original.Data), media.Size, media.MIME); err != nil {
+		return err
+	}
+
+	media.Variants = nil
+	for _, image := range processed.Variants {
+		variant := models.MediaVariant{
+			Name:    image.Name,
+			Key:     models.MediaVariantKey(media.Key, image.Name, image.Ext),
+			MIME:    image.MIME,
+			Size:    int64(len(image.Data)),
+			Width:   image.Width,
+			Height:  image.Height,
+			Cropped: image.Cropped,
+		}
+		if err := store.Put(ctx, variant.Key, bytes.NewReader(image.Data), variant.Size, variant.MIME); err != nil {
+			deleteMediaFiles(ctx, store, media)
+			return err
+		}
+		media.Variants = append(media.Variants, variant)
+	}
+	return nil
+}
+
+// respondUploadError saveUpload のエラーをレスポンスにする
+func respondUploadError(c *gin.Context, err error, policy upload.Policy) {
+	var quotaErr *storageQuotaError
+	if errors.As(err, &quotaErr) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "Storage quota exceeded",
+			"used":  quotaErr.Used,
+			"quota": quotaErr.Quota,
+			"size":  quotaErr.Size,
+		})
+		return
+	}
+	var scanErr *scanUnavailableError
+	if errors.As(err, &scanErr) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Malware scanner is not available",
+		})
+		return
+	}
+	var rejected *uploadRejectedError
+	if errors.As(err, &rejected) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":         rejected.message,
+			"allowed_types": policy.AllowedTypes,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": "Failed to save file",
+	})
+}
+
+// uploadErrorMessage 検査で拒否した理由をクライアントに返すメッセージにする
+func uploadErrorMessage(err error, policy upload.Policy) string {
+	switch {
+	case errors.Is(err, upload.ErrTooLarge):
+		return fmt.Sprintf("File size exceeds maximum limit of %s", formatSize(policy.MaxSize))
+	case errors.Is(err, upload.ErrTypeNotAllowed):
+		return "File type not allowed"
+	case errors.Is(err, upload.ErrExtensionMismatch):
+		return "File extension does not match its content"
+	case errors.Is(err, upload.ErrPolyglot):
+		return "File contains embedded content that is not allowed"
+	case errors.Is(err, upload.ErrUnsafeSVG):
+		return "SVG files must not contain scripts or external references"
+	}
+	return "Invalid file"
+}
+
+// formatSize バイト数を "10MB" のように表示
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20 && size%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", size>>20)
+	case size >= 1<<10 && size%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", size>>10)
+	}
+	return fmt.Sprintf("%d bytes", size)
 }
@@ -0,0 +1,166 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func eq(s string) Edit  { return Edit{Op: Equal, Text: s} }
+func ins(s string) Edit { return Edit{Op: Insert, Text: s} }
+func del(s string) Edit { return Edit{Op: Delete, Text: s} }
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Edit
+	}{
+		{"both empty", "", "", nil},
+		{"identical", "a\nb\n", "a\nb\n", []Edit{eq("a"), eq("b")}},
+		{"trailing newline", "a\n", "a", []Edit{eq("a")}},
+		{"insert into empty", "", "a\nb", []Edit{ins("a"), ins("b")}},
+		{"delete all", "a\nb", "", []Edit{del("a"), del("b")}},
+		{"insert", "a\nc", "a\nb\nc", []Edit{eq("a"), ins("b"), eq("c")}},
+		{"delete", "a\nb\nc", "a\nc", []Edit{eq("a"), del("b"), eq("c")}},
+		{"replace", "a\nb\nc", "a\nx\nc", []Edit{eq("a"), del("b"), ins("x"), eq("c")}},
+		{"insert at start", "b\nc", "a\nb\nc", []Edit{ins("a"), eq("b"), eq("c")}},
+		{"delete at end", "a\nb\nc", "a\nb", []Edit{eq("a"), eq("b"), del("c")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(tt.a, tt.b)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+// Myers の差分は最短の編集になる（"abcabba" → "cbabac" の編集距離は 5）
+func TestLinesShortest(t *testing.T) {
+	a := strings.Join(strings.Split("abcabba", ""), "\n")
+	b := strings.Join(strings.Split("cbabac", ""), "\n")
+	edits := Lines(a, b)
+
+	changes := 0
+	var from, to []string
+	for _, e := range edits {
+		if e.Op != Equal {
+			changes++
+		}
+		if e.Op != Insert {
+			from = append(from, e.Text)
+		}
+		if e.Op != Delete {
+			to = append(to, e.Text)
+		}
+	}
+	if changes != 5 {
+		t.Errorf("edit distance = %d, want 5 (%v)", changes, edits)
+	}
+	if strings.Join(from, "\n") != a || strings.Join(to, "\n") != b {
+		t.Errorf("edits do not reproduce the inputs: %v", edits)
+	}
+}
+
+// 編集距離が maxEditDistance を超える場合は全削除・全挿入にする
+func TestLinesTooManyEdits(t *testing.T) {
+	var a, b []string
+	for i := 0; i < maxEditDistance; i++ {
+		a = append(a, fmt.Sprintf("a%d", i))
+		b = append(b, fmt.Sprintf("b%d", i))
+	}
+	edits := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+	if len(edits) != 2*maxEditDistance {
+		t.Fatalf("len(edits) = %d, want %d", len(edits), 2*maxEditDistance)
+	}
+	if edits[0] != del("a0") || edits[maxEditDistance-1].Op != Delete || edits[maxEditDistance] != ins("b0") {
+		t.Errorf("edits should delete everything before inserting, got %v ... %v", edits[0], edits[maxEditDistance])
+	}
+}
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Edit
+	}{
+		{"identical", "hello world", "hello world", []Edit{eq("hello world")}},
+		{"insert word", "hello world", "hello there world", []Edit{eq("hello "), ins("there "), eq("world")}},
+		{"delete word", "hello there world", "hello world", []Edit{eq("hello "), del("there "), eq("world")}},
+		{"replace word", "red car", "blue car", []Edit{del("red"), ins("blue"), eq(" car")}},
+		{"cjk by rune", "今日は晴れ", "今日は雨", []Edit{eq("今日は"), del("晴れ"), ins("雨")}},
+		{"mixed", "Go言語", "Rust言語", []Edit{del("Go"), ins("Rust"), eq("言語")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Words(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Words(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	lines := func(s ...string) string { return strings.Join(s, "\n") }
+	const header = "--- a\n+++ b\n"
+	tests := []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{"identical", "a\nb", "a\nb", 3, ""},
+		{"both empty", "", "", 3, ""},
+		{"insert into empty", "", "x", 3, header + "@@ -0,0 +1 @@\n+x\n"},
+		{"delete all", "a\nb", "", 3, header + "@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{
+			"context around a change",
+			lines("1", "2", "3", "4", "5", "6", "7", "8", "9", "10"),
+			lines("1", "2", "3", "4", "X", "6", "7", "8", "9", "10"),
+			3,
+			header + "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+X\n 6\n 7\n 8\n",
+		},
+		{
+			"context clipped at the start",
+			lines("1", "2", "3"),
+			lines("0", "1", "2", "3"),
+			1,
+			header + "@@ -1 +1,2 @@\n+0\n 1\n",
+		},
+		{
+			"zero context",
+			lines("1", "2", "3"),
+			lines("1", "X", "3"),
+			0,
+			header + "@@ -2 +2 @@\n-2\n+X\n",
+		},
+		// 変更の間の同じ行が 2×context 以下なら1つのハンクにまとめる
+		{
+			"gap within twice the context",
+			lines("a", "b", "c", "d"),
+			lines("A", "b", "c", "D"),
+			1,
+			header + "@@ -1,4 +1,4 @@\n-a\n+A\n b\n c\n-d\n+D\n",
+		},
+		{
+			"gap beyond twice the context",
+			lines("a", "b", "c", "d", "e"),
+			lines("A", "b", "c", "d", "E"),
+			1,
+			header + "@@ -1,2 +1,2 @@\n-a\n+A\n b\n@@ -4,2 +4,2 @@\n d\n-e\n+E\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified(tt.a, tt.b, "a", "b", tt.context); got != tt.want {
+				t.Errorf("Unified =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
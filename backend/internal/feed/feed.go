@@ -0,0 +1,41 @@
+// Package feed は投稿の一覧を RSS 2.0・Atom 1.0・JSON Feed 1.1 で出力する。
+//
+// Feed と Item に形式によらない情報を詰め、RSS / Atom / JSON で書き出す。
+// Item.Content が空なら Summary だけを出力する（抜粋のみのフィード）。
+package feed
+
+import (
+	"time"
+)
+
+// Feed フィード全体の情報
+type Feed struct {
+	Title       string
+	Description string
+	Link        string // サイト（または一覧ページ）の URL
+	FeedURL     string // このフィード自身の URL
+	Language    string // "ja" など
+	Updated     time.Time
+	Items       []Item
+}
+
+// Item フィードの1件
+type Item struct {
+	ID         string // 変わらない識別子（URL または tag: URI）
+	Title      string
+	Link       string
+	Summary    string // プレーンテキストの抜粋
+	Content    string // 本文の HTML（空なら出力しない）
+	Author     string
+	Categories []string
+	Published  time.Time
+	Updated    time.Time
+	Enclosure  *Enclosure
+}
+
+// Enclosure 添付ファイル（アイキャッチ画像など）
+type Enclosure struct {
+	URL    string
+	Type   string
+	Length int64 // 不明なら 0
+}
@@ -0,0 +1,351 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testPublished = time.Date(2024, 5, 1, 9, 0, 0, 0, time.FixedZone("JST", 9*60*60))
+	testUpdated   = time.Date(2024, 5, 2, 12, 30, 0, 0, time.UTC)
+)
+
+// testFeed 本文あり・抜粋のみ・最小限の3件を持つフィード
+func testFeed() *Feed {
+	return &Feed{
+		Title:       "Tom & Jerry's <Blog>",
+		Description: "ブログの説明",
+		Link:        "https://example.com/",
+		FeedURL:     "https://api.example.com/feed",
+		Language:    "ja",
+		Updated:     testUpdated,
+		Items: []Item{
+			{
+				ID:         "https://example.com/posts/hello",
+				Title:      "Hello & <World>",
+				Link:       "https://example.com/posts/hello",
+				Summary:    "抜粋",
+				Content:    `<p>本文 <a href="/x">link</a></p><pre>a]]>b</pre>`,
+				Author:     "Alice",
+				Categories: []string{"go", "web"},
+				Published:  testPublished,
+				Updated:    testUpdated,
+				Enclosure:  &Enclosure{URL: "https://api.example.com/uploads/a.jpg", Type: "image/jpeg", Length: 1234},
+			},
+			{
+				ID:        "tag:example.com,2024:post-2",
+				Title:     "Excerpt only",
+				Link:      "https://example.com/posts/excerpt",
+				Summary:   "抜粋だけ",
+				Published: testPublished,
+				Enclosure: &Enclosure{URL: "https://api.example.com/uploads/b.pdf", Type: "application/pdf"},
+			},
+			{
+				ID:    "https://example.com/posts/minimal",
+				Title: "Minimal",
+				Link:  "https://example.com/posts/minimal",
+			},
+		},
+	}
+}
+
+type rssResult struct {
+	Version string `xml:"version,attr"`
+	Channel struct {
+		Title         string `xml:"title"`
+		Description   string `xml:"description"`
+		Language      string `xml:"language"`
+		LastBuildDate string `xml:"lastBuildDate"`
+		// link と atom:link（名前空間で見分ける）
+		Links []struct {
+			XMLName xml.Name
+			Href    string `xml:"href,attr"`
+			Rel     string `xml:"rel,attr"`
+			Value   string `xml:",chardata"`
+		} `xml:"link"`
+		Items []struct {
+			Title string `xml:"title"`
+			Link  string `xml:"link"`
+			GUID  struct {
+				IsPermaLink string `xml:"isPermaLink,attr"`
+				Value       string `xml:",chardata"`
+			} `xml:"guid"`
+			Description string   `xml:"description"`
+			Content     *string  `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+			Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+			Categories  []string `xml:"category"`
+			PubDate     string   `xml:"pubDate"`
+			Enclosure   *struct {
+				URL    string `xml:"url,attr"`
+				Length string `xml:"length,attr"`
+				Type   string `xml:"type,attr"`
+			} `xml:"enclosure"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+func TestRSS(t *testing.T) {
+	f := testFeed()
+	data, err := f.RSS()
+	if err != nil {
+		t.Fatalf("RSS() error: %v", err)
+	}
+	if !strings.HasPrefix(string(data), xml.Header) {
+		t.Errorf("RSS() does not start with the XML header: %q", data[:40])
+	}
+	var doc rssResult
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("RSS() is not valid XML: %v\n%s", err, data)
+	}
+
+	ch := doc.Channel
+	if doc.Version != "2.0" {
+		t.Errorf("version = %q, want 2.0", doc.Version)
+	}
+	if ch.Title != f.Title || ch.Description != f.Description || ch.Language != "ja" {
+		t.Errorf("channel = %q, %q, %q", ch.Title, ch.Description, ch.Language)
+	}
+	if want := "Thu, 02 May 2024 12:30:00 +0000"; ch.LastBuildDate != want {
+		t.Errorf("lastBuildDate = %q, want %q", ch.LastBuildDate, want)
+	}
+	if len(ch.Links) != 2 || ch.Links[0].XMLName.Space != "" || ch.Links[0].Value != f.Link ||
+		ch.Links[1].XMLName.Space != "http://www.w3.org/2005/Atom" || ch.Links[1].Href != f.FeedURL || ch.Links[1].Rel != "self" {
+		t.Errorf("links = %+v, want %q and atom:link self %q", ch.Links, f.Link, f.FeedURL)
+	}
+	if len(ch.Items) != len(f.Items) {
+		t.Fatalf("len(items) = %d, want %d", len(ch.Items), len(f.Items))
+	}
+
+	full, excerpt, minimal := ch.Items[0], ch.Items[1], ch.Items[2]
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"full title", full.Title, "Hello & <World>"},
+		{"full guid", full.GUID.Value, "https://example.com/posts/hello"},
+		{"full guid is permalink", full.GUID.IsPermaLink, "true"},
+		{"full description", full.Description, "抜粋"},
+		{"full content", full.Content != nil && *full.Content == f.Items[0].Content, true},
+		{"full creator", full.Creator, "Alice"},
+		{"full categories", full.Categories, []string{"go", "web"}},
+		{"full pubDate", full.PubDate, "Wed, 01 May 2024 00:00:00 +0000"},
+		{"full enclosure", full.Enclosure != nil && full.Enclosure.URL == "https://api.example.com/uploads/a.jpg" &&
+			full.Enclosure.Length == "1234" && full.Enclosure.Type == "image/jpeg", true},
+		{"excerpt guid is not permalink", excerpt.GUID.IsPermaLink, "false"},
+		{"excerpt has no content", excerpt.Content == nil, true},
+		{"excerpt description", excerpt.Description, "抜粋だけ"},
+		{"excerpt enclosure length", excerpt.Enclosure != nil && excerpt.Enclosure.Length == "0", true},
+		{"minimal creator", minimal.Creator, ""},
+		{"minimal pubDate", minimal.PubDate, ""},
+		{"minimal enclosure", minimal.Enclosure == nil, true},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestRSSDefaults(t *testing.T) {
+	// 説明がなければタイトルを使い、更新日時・自身の URL がなければ出力しない
+	f := &Feed{Title: "Blog", Link: "https://example.com/"}
+	data, err := f.RSS()
+	if err != nil {
+		t.Fatalf("RSS() error: %v", err)
+	}
+	var doc rssResult
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("RSS() is not valid XML: %v\n%s", err, data)
+	}
+	if doc.Channel.Description != "Blog" {
+		t.Errorf("description = %q, want the title", doc.Channel.Description)
+	}
+	for _, unwanted := range []string{"<lastBuildDate>", "<atom:link", "<item>"} {
+		if strings.Contains(string(data), unwanted) {
+			t.Errorf("RSS() contains %s:\n%s", unwanted, data)
+		}
+	}
+}
+
+type atomResult struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang    string   `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Links   []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Entries []struct {
+		ID        string `xml:"id"`
+		Title     string `xml:"title"`
+		Updated   string `xml:"updated"`
+		Published string `xml:"published"`
+		Author    string `xml:"author>name"`
+		Links     []struct {
+			Href   string `xml:"href,attr"`
+			Rel    string `xml:"rel,attr"`
+			Type   string `xml:"type,attr"`
+			Length string `xml:"length,attr"`
+		} `xml:"link"`
+		Categories []struct {
+			Term string `xml:"term,attr"`
+		} `xml:"category"`
+		Summary *struct {
+			Type  string `xml:"type,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"summary"`
+		Content *struct {
+			Type  string `xml:"type,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"content"`
+	} `xml:"entry"`
+}
+
+func TestAtom(t *testing.T) {
+	f := testFeed()
+	data, err := f.Atom()
+	if err != nil {
+		t.Fatalf("Atom() error: %v", err)
+	}
+	var doc atomResult
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Atom() is not valid XML: %v\n%s", err, data)
+	}
+	if len(doc.Entries) != len(f.Items) {
+		t.Fatalf("len(entries) = %d, want %d", len(doc.Entries), len(f.Items))
+	}
+
+	full, excerpt, minimal := doc.Entries[0], doc.Entries[1], doc.Entries[2]
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"lang", doc.Lang, "ja"},
+		{"id", doc.ID, f.FeedURL},
+		{"title", doc.Title, f.Title},
+		{"updated", doc.Updated, "2024-05-02T12:30:00Z"},
+		{"links", len(doc.Links) == 2 && doc.Links[0].Rel == "alternate" && doc.Links[0].Href == f.Link &&
+			doc.Links[1].Rel == "self" && doc.Links[1].Href == f.FeedURL, true},
+		{"full id", full.ID, "https://example.com/posts/hello"},
+		{"full title", full.Title, "Hello & <World>"},
+		{"full updated", full.Updated, "2024-05-02T12:30:00Z"},
+		{"full published", full.Published, "2024-05-01T00:00:00Z"},
+		{"full author", full.Author, "Alice"},
+		{"full categories", len(full.Categories) == 2 && full.Categories[0].Term == "go" && full.Categories[1].Term == "web", true},
+		{"full summary", full.Summary != nil && full.Summary.Type == "text" && full.Summary.Value == "抜粋", true},
+		{"full content", full.Content != nil && full.Content.Type == "html" && full.Content.Value == f.Items[0].Content, true},
+		{"full enclosure", len(full.Links) == 2 && full.Links[1].Rel == "enclosure" &&
+			full.Links[1].Href == "https://api.example.com/uploads/a.jpg" && full.Links[1].Length == "1234", true},
+		// 更新日時がなければ公開日時、著者がなければフィードのタイトル
+		{"excerpt updated", excerpt.Updated, "2024-05-01T00:00:00Z"},
+		{"excerpt author", excerpt.Author, f.Title},
+		{"excerpt has no content", excerpt.Content == nil, true},
+		{"excerpt enclosure without length", len(excerpt.Links) == 2 && excerpt.Links[1].Length == "", true},
+		{"minimal updated", minimal.Updated, "1970-01-01T00:00:00Z"},
+		{"minimal published", minimal.Published, ""},
+		{"minimal summary", minimal.Summary == nil, true},
+		{"minimal links", len(minimal.Links), 1},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestAtomIDFallsBackToLink(t *testing.T) {
+	f := &Feed{Title: "Blog", Link: "https://example.com/"}
+	data, err := f.Atom()
+	if err != nil {
+		t.Fatalf("Atom() error: %v", err)
+	}
+	var doc atomResult
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Atom() is not valid XML: %v\n%s", err, data)
+	}
+	if doc.ID != f.Link {
+		t.Errorf("id = %q, want %q", doc.ID, f.Link)
+	}
+	if len(doc.Links) != 1 || doc.Links[0].Rel != "alternate" {
+		t.Errorf("links = %+v, want only the alternate link", doc.Links)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	f := testFeed()
+	data, err := f.JSON()
+	if err != nil {
+		t.Fatalf("JSON() error: %v", err)
+	}
+	if strings.Contains(string(data), `\u003c`) || strings.Contains(string(data), `\u0026`) {
+		t.Errorf("JSON() escapes HTML: %s", data)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("JSON() is not valid JSON: %v\n%s", err, data)
+	}
+	items, _ := doc["items"].([]any)
+	if len(items) != len(f.Items) {
+		t.Fatalf("len(items) = %d, want %d", len(items), len(f.Items))
+	}
+	full, _ := items[0].(map[string]any)
+	excerpt, _ := items[1].(map[string]any)
+	minimal, _ := items[2].(map[string]any)
+
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"version", doc["version"], "https://jsonfeed.org/version/1.1"},
+		{"title", doc["title"], f.Title},
+		{"home_page_url", doc["home_page_url"], f.Link},
+		{"feed_url", doc["feed_url"], f.FeedURL},
+		{"description", doc["description"], f.Description},
+		{"language", doc["language"], "ja"},
+		{"full id", full["id"], "https://example.com/posts/hello"},
+		{"full url", full["url"], "https://example.com/posts/hello"},
+		{"full content_html", full["content_html"], f.Items[0].Content},
+		{"full content_text", full["content_text"], nil},
+		{"full summary", full["summary"], "抜粋"},
+		{"full image", full["image"], "https://api.example.com/uploads/a.jpg"},
+		{"full date_published", full["date_published"], "2024-05-01T00:00:00Z"},
+		{"full date_modified", full["date_modified"], "2024-05-02T12:30:00Z"},
+		{"full authors", full["authors"], []any{map[string]any{"name": "Alice"}}},
+		{"full tags", full["tags"], []any{"go", "web"}},
+		{"full attachments", full["attachments"], []any{map[string]any{
+			"url": "https://api.example.com/uploads/a.jpg", "mime_type": "image/jpeg", "size_in_bytes": float64(1234),
+		}}},
+		// 抜粋のみなら content_text に抜粋を入れる
+		{"excerpt content_html", excerpt["content_html"], nil},
+		{"excerpt content_text", excerpt["content_text"], "抜粋だけ"},
+		{"excerpt date_modified", excerpt["date_modified"], nil},
+		{"excerpt image", excerpt["image"], nil},
+		{"excerpt attachments", excerpt["attachments"], []any{map[string]any{
+			"url": "https://api.example.com/uploads/b.pdf", "mime_type": "application/pdf",
+		}}},
+		{"minimal keys", len(minimal), 3},
+		{"minimal title", minimal["title"], "Minimal"},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestJSONEmptyItems(t *testing.T) {
+	// 項目がなくても items は null ではなく空の配列にする
+	data, err := (&Feed{Title: "Blog"}).JSON()
+	if err != nil {
+		t.Fatalf("JSON() error: %v", err)
+	}
+	if !strings.Contains(string(data), `"items": []`) {
+		t.Errorf("JSON() = %s, want empty items", data)
+	}
+}
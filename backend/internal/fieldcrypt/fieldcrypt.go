@@ -0,0 +1,135 @@
+// Package fieldcrypt はデータベースに保存する個人情報（コメントのメールアドレスや IP アドレス）を
+// AES-256-GCM で暗号化する。
+//
+// 暗号化した値は "enc:v1:<base64(nonce || 暗号文)>" の形式で保存する。接頭辞のない値は
+// 暗号化を導入する前の平文として扱い、そのまま返す。
+// 暗号化した列は検索できないため、一致検索には BlindIndex（鍵付きハッシュ）を別の列に保存して使う。
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+)
+
+const prefix = "enc:v1:"
+
+var (
+	errNoKey     = errors.New("fieldcrypt: encryption key is not configured")
+	errMalformed = errors.New("fieldcrypt: malformed encrypted value")
+)
+
+// Cipher 暗号化と検索用ハッシュの鍵
+type Cipher struct {
+	aead     cipher.AEAD
+	indexKey []byte
+}
+
+// New 鍵から Cipher を作る
+// 32 バイトを base64 でエンコードした鍵はそのまま使い、それ以外の文字列は SHA-256 で 32 バイトにする
+func New(key string) (*Cipher, error) {
+	if key == "" {
+		return nil, errNoKey
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		sum := sha256.Sum256([]byte(key))
+		raw = sum[:]
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// 検索用ハッシュには暗号化とは別の鍵を使う
+	mac := hmac.New(sha256.New, raw)
+	mac.Write([]byte("blind-index"))
+	return &Cipher{aead: aead, indexKey: mac.Sum(nil)}, nil
+}
+
+// Encrypt 値を暗号化（空文字はそのまま）
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return prefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 暗号化された値を復号（接頭辞のない値は平文としてそのまま返す）
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", errMalformed
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// BlindIndex 一致検索用の鍵付きハッシュ（HMAC-SHA256 の16進表記）
+func (c *Cipher) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted Encrypt で暗号化された値かどうか
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+var (
+	mu      sync.RWMutex
+	current *Cipher
+)
+
+// SetKey データベースの値の暗号化に使う鍵を設定（サーバー・マイグレーションの起動時に呼ぶ）
+func SetKey(key string) error {
+	c, err := New(key)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	current = c
+	mu.Unlock()
+	return nil
+}
+
+// Default SetKey で設定した Cipher（未設定なら nil）
+func Default() *Cipher {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// BlindIndex 設定済みの鍵で検索用ハッシュを計算（鍵が未設定ならエラー）
+func BlindIndex(value string) (string, error) {
+	c := Default()
+	if c == nil {
+		return "", errNoKey
+	}
+	return c.BlindIndex(value), nil
+}
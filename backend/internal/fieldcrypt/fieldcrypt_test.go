@@ -0,0 +1,176 @@
+package fieldcrypt
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func mustNew(t *testing.T, key string) *Cipher {
+	t.Helper()
+	c, err := New(key)
+	if err != nil {
+		t.Fatalf("New(%q) error: %v", key, err)
+	}
+	return c
+}
+
+func TestNewRequiresKey(t *testing.T) {
+	if _, err := New(""); err == nil {
+		t.Fatal("New(\"\") should fail")
+	}
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	keys := []string{
+		"passphrase",
+		base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")),
+	}
+	values := []string{
+		"user@example.com",
+		"192.0.2.1",
+		"2001:db8::1",
+		"日本語のメールアドレス@例え.jp",
+		strings.Repeat("x", 4096),
+	}
+	for _, key := range keys {
+		c := mustNew(t, key)
+		for _, value := range values {
+			encrypted, err := c.Encrypt(value)
+			if err != nil {
+				t.Fatalf("Encrypt(%q) error: %v", value, err)
+			}
+			if !IsEncrypted(encrypted) || strings.Contains(encrypted, value) {
+				t.Errorf("Encrypt(%q) = %q, want an encrypted value", value, encrypted)
+			}
+			decrypted, err := c.Decrypt(encrypted)
+			if err != nil {
+				t.Fatalf("Decrypt(%q) error: %v", encrypted, err)
+			}
+			if decrypted != value {
+				t.Errorf("Decrypt(Encrypt(%q)) = %q", value, decrypted)
+			}
+		}
+	}
+}
+
+func TestEncryptUsesRandomNonce(t *testing.T) {
+	c := mustNew(t, "passphrase")
+	a, _ := c.Encrypt("user@example.com")
+	b, _ := c.Encrypt("user@example.com")
+	if a == b {
+		t.Errorf("Encrypt returned the same ciphertext twice: %q", a)
+	}
+}
+
+func TestEmptyAndPlaintextValues(t *testing.T) {
+	c := mustNew(t, "passphrase")
+	if encrypted, err := c.Encrypt(""); err != nil || encrypted != "" {
+		t.Errorf("Encrypt(\"\") = %q, %v; want \"\", nil", encrypted, err)
+	}
+	// 暗号化を導入する前の値はそのまま返す
+	for _, value := range []string{"", "user@example.com", "enc:v2:abc"} {
+		if decrypted, err := c.Decrypt(value); err != nil || decrypted != value {
+			t.Errorf("Decrypt(%q) = %q, %v; want the value unchanged", value, decrypted, err)
+		}
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	c := mustNew(t, "passphrase")
+	encrypted, err := c.Encrypt("user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(encrypted, prefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flip := func(i int) string {
+		b := append([]byte(nil), sealed...)
+		b[i] ^= 0x01
+		return prefix + base64.RawStdEncoding.EncodeToString(b)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"flipped nonce", flip(0)},
+		{"flipped ciphertext", flip(c.aead.NonceSize())},
+		{"flipped tag", flip(len(sealed) - 1)},
+		{"truncated", prefix + base64.RawStdEncoding.EncodeToString(sealed[:len(sealed)-1])},
+		{"shorter than nonce", prefix + base64.RawStdEncoding.EncodeToString(sealed[:4])},
+		{"not base64", prefix + "!!!"},
+		{"empty payload", prefix},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if decrypted, err := c.Decrypt(tt.value); err == nil {
+				t.Errorf("Decrypt(%q) = %q; want error", tt.value, decrypted)
+			}
+		})
+	}
+
+	other := mustNew(t, "another passphrase")
+	if decrypted, err := other.Decrypt(encrypted); err == nil {
+		t.Errorf("Decrypt with another key = %q; want error", decrypted)
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	c := mustNew(t, "passphrase")
+	other := mustNew(t, "another passphrase")
+
+	tests := []struct {
+		name   string
+		a, b   string
+		cipher *Cipher
+		equal  bool
+	}{
+		{"same value", "user@example.com", "user@example.com", c, true},
+		{"different value", "user@example.com", "other@example.com", c, false},
+		{"case sensitive", "user@example.com", "User@example.com", c, false},
+		{"different key", "user@example.com", "user@example.com", other, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := c.BlindIndex(tt.a), tt.cipher.BlindIndex(tt.b)
+			if len(a) != 64 {
+				t.Errorf("BlindIndex(%q) = %q, want 64 hex characters", tt.a, a)
+			}
+			if (a == b) != tt.equal {
+				t.Errorf("BlindIndex(%q) == BlindIndex(%q): %v, want %v", tt.a, tt.b, a == b, tt.equal)
+			}
+		})
+	}
+}
+
+func TestPackageBlindIndexWithoutKey(t *testing.T) {
+	mu.Lock()
+	saved := current
+	current = nil
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		current = saved
+		mu.Unlock()
+	})
+
+	if _, err := BlindIndex("user@example.com"); err == nil {
+		t.Fatal("BlindIndex without a key should fail")
+	}
+	if err := SetKey(""); err == nil {
+		t.Fatal("SetKey(\"\") should fail")
+	}
+	if err := SetKey("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := BlindIndex("user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mustNew(t, "passphrase").BlindIndex("user@example.com"); got != want {
+		t.Errorf("BlindIndex = %q, want %q", got, want)
+	}
+}
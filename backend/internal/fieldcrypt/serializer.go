@@ -0,0 +1,57 @@
+package fieldcrypt
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("encrypted", Serializer{})
+}
+
+// Serializer 文字列のフィールドを暗号化して保存する GORM のシリアライザー
+// `gorm:"serializer:encrypted"` で使う（SetKey で鍵を設定しておくこと）
+type Serializer struct{}
+
+// Scan データベースの値を復号してフィールドにセット
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("fieldcrypt: unsupported database value %T for %s", dbValue, field.Name)
+	}
+
+	if IsEncrypted(value) {
+		c := Default()
+		if c == nil {
+			return errNoKey
+		}
+		plaintext, err := c.Decrypt(value)
+		if err != nil {
+			return fmt.Errorf("fieldcrypt: failed to decrypt %s: %w", field.Name, err)
+		}
+		value = plaintext
+	}
+	return field.Set(ctx, dst, value)
+}
+
+// Value フィールドの値を暗号化
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("fieldcrypt: %s must be a string, got %T", field.Name, fieldValue)
+	}
+	c := Default()
+	if c == nil {
+		return nil, errNoKey
+	}
+	return c.Encrypt(value)
+}
@@ -0,0 +1,234 @@
+// Package imagecache は変換した画像をディスクに保存し、合計の大きさの上限を超えたら
+// 最近使われていないものから削除する（LRU）
+package imagecache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache ディスク上の LRU キャッシュ
+// ファイルは <Dir>/<元の画像のハッシュの先頭2文字>/<元の画像のハッシュ>/<変換のハッシュ> に置く
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*list.Element // 相対パス → lru の要素
+	lru     *list.List               // 先頭が最近使ったもの
+
+	group singleflight.Group
+}
+
+type entry struct {
+	path string
+	size int64
+}
+
+// New キャッシュを作成（ディレクトリにある既存のファイルを更新日時の順に読み込む）
+func New(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+
+	type file struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []file
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		// 書き込み途中で止まった一時ファイルは消す
+		if strings.HasPrefix(d.Name(), ".tmp-") {
+			return os.Remove(path)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, file{path: rel, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range files {
+		c.entries[f.path] = c.lru.PushFront(&entry{path: f.path, size: f.size})
+		c.size += f.size
+	}
+	c.evictLocked()
+	return c, nil
+}
+
+// Open キャッシュしたファイルを開く（なければ create で作って保存する）
+// 同じファイルを同時に要求された場合、create は一度だけ呼ぶ
+func (c *Cache) Open(source, variant string, create func() ([]byte, error)) (*os.File, error) {
+	path := c.path(source, variant)
+	if f, ok := c.open(path); ok {
+		return f, nil
+	}
+
+	_, err, _ := c.group.Do(path, func() (interface{}, error) {
+		if _, ok := c.lookup(path); ok {
+			return nil, nil
+		}
+		data, err := create()
+		if err != nil {
+			return nil, err
+		}
+		return nil, c.store(path, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := c.open(path); ok {
+		return f, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+// RemoveSource 元の画像から作ったファイルをすべて削除する（元の画像を削除したときに呼ぶ）
+func (c *Cache) RemoveSource(source string) error {
+	dir := c.sourceDir(source)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path, elem := range c.entries {
+		if filepath.Dir(path) == dir {
+			c.removeLocked(elem)
+		}
+	}
+	return os.RemoveAll(filepath.Join(c.dir, dir))
+}
+
+// Size キャッシュしているファイルの合計の大きさ
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+func (c *Cache) sourceDir(source string) string {
+	h := hash(source)
+	return filepath.Join(h[:2], h)
+}
+
+func (c *Cache) path(source, variant string) string {
+	return filepath.Join(c.sourceDir(source), hash(variant))
+}
+
+// lookup 登録されていれば最近使ったものにする
+func (c *Cache) lookup(path string) (*entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[path]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*entry), true
+}
+
+func (c *Cache) open(path string) (*os.File, bool) {
+	if _, ok := c.lookup(path); !ok {
+		return nil, false
+	}
+	f, err := os.Open(filepath.Join(c.dir, path))
+	if err != nil {
+		// 外から消された場合は登録を取り消して作り直す
+		c.mu.Lock()
+		if elem, ok := c.entries[path]; ok {
+			c.removeLocked(elem)
+		}
+		c.mu.Unlock()
+		return nil, false
+	}
+	// 再起動後も使われた順を保てるように更新日時を変える
+	now := time.Now()
+	_ = os.Chtimes(f.Name(), now, now)
+	return f, true
+}
+
+// store 一時ファイルに書いてから置き換え、上限を超えたら古いものを消す
+func (c *Cache) store(path string, data []byte) error {
+	full := filepath.Join(c.dir, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(full), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), full); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[path]; ok {
+		c.size -= elem.Value.(*entry).size
+		c.lru.Remove(elem)
+	}
+	c.entries[path] = c.lru.PushFront(&entry{path: path, size: int64(len(data))})
+	c.size += int64(len(data))
+	c.evictLocked()
+	return nil
+}
+
+// evictLocked 上限を超えている間、最近使われていないものから消す（最後の1件は残す）
+func (c *Cache) evictLocked() {
+	for c.maxSize > 0 && c.size > c.maxSize && c.lru.Len() > 1 {
+		elem := c.lru.Back()
+		c.removeLocked(elem)
+		os.Remove(filepath.Join(c.dir, elem.Value.(*entry).path))
+	}
+}
+
+func (c *Cache) removeLocked(elem *list.Element) {
+	e := elem.Value.(*entry)
+	c.lru.Remove(elem)
+	delete(c.entries, e.path)
+	c.size -= e.size
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,230 @@
+package imagecache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func read(t *testing.T, f *os.File) []byte {
+	t.Helper()
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func data(s string) func() ([]byte, error) {
+	return func() ([]byte, error) { return []byte(s), nil }
+}
+
+func TestOpenCreatesOnce(t *testing.T) {
+	c, err := New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls atomic.Int32
+	create := func() ([]byte, error) {
+		calls.Add(1)
+		return []byte("resized"), nil
+	}
+
+	// 同時に要求されても create は1回だけ呼ばれる
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, err := c.Open("2024/05/a.jpg", "w=640", create)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got := read(t, f); !bytes.Equal(got, []byte("resized")) {
+				t.Errorf("read = %q", got)
+			}
+		}()
+	}
+	wg.Wait()
+
+	f, err := c.Open("2024/05/a.jpg", "w=640", create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read(t, f)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("create called %d times, want 1", n)
+	}
+	if c.Size() != int64(len("resized")) {
+		t.Errorf("Size = %d", c.Size())
+	}
+}
+
+func TestOpenError(t *testing.T) {
+	c, err := New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := errors.New("decode failed")
+	if _, err := c.Open("a.jpg", "w=640", func() ([]byte, error) { return nil, failed }); !errors.Is(err, failed) {
+		t.Fatalf("Open error = %v, want %v", err, failed)
+	}
+	// 失敗した結果はキャッシュしない
+	f, err := c.Open("a.jpg", "w=640", data("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, f); string(got) != "ok" {
+		t.Errorf("read = %q, want ok", got)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxSize int64
+		touch   string // 途中で使ったもの（消されにくくなる）
+		want    []string
+	}{
+		{"no limit", 0, "", []string{"a", "b", "c"}},
+		{"evicts least recently used", 8, "", []string{"b", "c"}},
+		{"keeps touched entry", 8, "a", []string{"a", "c"}},
+		{"keeps the last entry even if too large", 1, "", []string{"c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(t.TempDir(), tt.maxSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"a", "b", "c"} {
+				if name == "c" && tt.touch != "" {
+					f, err := c.Open(tt.touch+".jpg", "w=1", data("xxxx"))
+					if err != nil {
+						t.Fatal(err)
+					}
+					f.Close()
+				}
+				f, err := c.Open(name+".jpg", "w=1", data("xxxx"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.Close()
+			}
+
+			var got []string
+			for _, name := range []string{"a", "b", "c"} {
+				if _, ok := c.lookup(c.path(name+".jpg", "w=1")); ok {
+					got = append(got, name)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("cached = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("cached = %v, want %v", got, tt.want)
+				}
+			}
+			if c.Size() != int64(4*len(tt.want)) {
+				t.Errorf("Size = %d, want %d", c.Size(), 4*len(tt.want))
+			}
+		})
+	}
+}
+
+func TestRemoveSource(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, variant := range []string{"w=320", "w=640"} {
+		f, err := c.Open("a.jpg", variant, data("a"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	f, err := c.Open("b.jpg", "w=320", data("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if err := c.RemoveSource("a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Size() != 1 {
+		t.Errorf("Size = %d, want 1", c.Size())
+	}
+	if _, err := os.Stat(filepath.Join(dir, c.sourceDir("a.jpg"))); !os.IsNotExist(err) {
+		t.Errorf("source directory still exists: %v", err)
+	}
+	f, err = c.Open("a.jpg", "w=320", data("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, f); string(got) != "new" {
+		t.Errorf("read after RemoveSource = %q, want new", got)
+	}
+}
+
+func TestNewLoadsExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := c.Open("a.jpg", "w=640", data("cached"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	// 書き込み途中で止まった一時ファイル
+	tmp := filepath.Join(dir, c.sourceDir("a.jpg"), ".tmp-123")
+	if err := os.WriteFile(tmp, []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := New(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Size() != int64(len("cached")) {
+		t.Errorf("Size = %d, want %d", reopened.Size(), len("cached"))
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("temporary file was not removed: %v", err)
+	}
+	f, err = reopened.Open("a.jpg", "w=640", func() ([]byte, error) {
+		t.Error("create should not be called for a cached file")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, f); string(got) != "cached" {
+		t.Errorf("read = %q, want cached", got)
+	}
+
+	// 外から消されたファイルは作り直す
+	if err := os.Remove(filepath.Join(dir, reopened.path("a.jpg", "w=640"))); err != nil {
+		t.Fatal(err)
+	}
+	f, err = reopened.Open("a.jpg", "w=640", data("recreated"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, f); string(got) != "recreated" {
+		t.Errorf("read = %q, want recreated", got)
+	}
+}
@@ -0,0 +1,270 @@
+// Package imageproc はアップロードされた画像のメタデータの除去と縮小画像の作成を行う
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sync"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ErrTooLarge 画素数が多すぎる（展開するとメモリを使い切るおそれがある）
+var ErrTooLarge = errors.New("imageproc: image dimensions too large")
+
+// Variant 作る縮小画像
+// Height を指定すると中央で切り抜いて Width×Height ちょうどにし、省略すると縦横比を保って幅を Width にする
+type Variant struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// Options 処理の設定
+type Options struct {
+	Variants  []Variant
+	Quality   int   // JPEG の品質（1〜100）
+	MaxPixels int64 // これより画素数の多い画像は処理しない（0 で無制限）
+}
+
+// Image 処理した画像
+type Image struct {
+	Name    string // 縮小画像の名前（元の画像は ""）
+	Data    []byte
+	MIME    string
+	Ext     string
+	Width   int
+	Height  int
+	Cropped bool // 切り抜いたので元の画像と縦横比が違う
+}
+
+// Result 元の画像（メタデータを除いたもの）と縮小画像（元の画像より小さいものだけ）
+type Result struct {
+	Original Image
+	Variants []Image
+}
+
+// Size 元の画像と縮小画像の合計のバイト数
+func (r *Result) Size() int64 {
+	size := int64(len(r.Original.Data))
+	for _, v := range r.Variants {
+		size += int64(len(v.Data))
+	}
+	return size
+}
+
+// Encoder 画像をエンコードする関数
+type Encoder func(w io.Writer, img image.Image, quality int) error
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]Encoder{
+		"image/jpeg": func(w io.Writer, img image.Image, quality int) error {
+			return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+		},
+		"image/png": func(w io.Writer, img image.Image, _ int) error {
+			return png.Encode(w, img)
+		},
+	}
+	extensions = map[string]string{
+		"image/jpeg": ".jpg",
+		"image/png":  ".png",
+		"image/gif":  ".gif",
+		"image/webp": ".webp",
+	}
+)
+
+// RegisterEncoder エンコーダーを登録する（標準ライブラリは WebP をエンコードできないため、
+// 変換 URL の f=webp を使うには libwebp のバインディングなどのエンコーダーを "image/webp" で登録する）
+func RegisterEncoder(mimeType string, enc Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[mimeType] = enc
+}
+
+// CanEncode エンコーダーが登録されているか
+func CanEncode(mimeType string) bool {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	_, ok := encoders[mimeType]
+	return ok
+}
+
+func encode(img image.Image, mimeType string, quality int) ([]byte, error) {
+	encodersMu.RLock()
+	enc, ok := encoders[mimeType]
+	encodersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("imageproc: no encoder for %s", mimeType)
+	}
+	var buf bytes.Buffer
+	if err := enc(&buf, img, quality); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Supports 処理できる画像の種類か
+func Supports(mimeType string) bool {
+	_, ok := extensions[mimeType]
+	return ok
+}
+
+// Process 元の画像のメタデータを取り除き、縮小画像を作る
+// 元の画像は再エンコードしない（JPEG の向きは Orientation だけの EXIF で残し、幅と高さは回転後の値にする）
+func Process(data []byte, mimeType string, opts Options) (*Result, error) {
+	if !Supports(mimeType) {
+		return nil, fmt.Errorf("imageproc: unsupported type %s", mimeType)
+	}
+	if opts.Quality <= 0 || opts.Quality > 100 {
+		opts.Quality = 85
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxPixels > 0 && int64(config.Width)*int64(config.Height) > opts.MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	original := Image{MIME: mimeType, Ext: extensions[mimeType], Width: config.Width, Height: config.Height}
+	original.Data, err = StripMetadata(data, mimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := 1
+	if mimeType == "image/jpeg" {
+		orientation = jpegOrientation(data)
+	}
+	if orientation >= 5 {
+		original.Width, original.Height = original.Height, original.Width
+	}
+	result := &Result{Original: original}
+	if len(opts.Variants) == 0 {
+		return result, nil
+	}
+
+	var img image.Image
+	switch mimeType {
+	case "image/jpeg":
+		if img, err = jpeg.Decode(bytes.NewReader(data)); err == nil {
+			img = applyOrientation(img, orientation)
+		}
+	case "image/gif":
+		// アニメーション GIF は最初のフレームから作る
+		img, err = gif.Decode(bytes.NewReader(data))
+	default:
+		img, _, err = image.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	format := variantFormat(img, mimeType)
+	for _, variant := range opts.Variants {
+		resized := resize(img, variant)
+		if resized == nil {
+			continue
+		}
+		encoded, err := encode(resized, format, opts.Quality)
+		if err != nil {
+			return nil, err
+		}
+		result.Variants = append(result.Variants, Image{
+			Name:    variant.Name,
+			Data:    encoded,
+			MIME:    format,
+			Ext:     extensions[format],
+			Width:   resized.Bounds().Dx(),
+			Height:  resized.Bounds().Dy(),
+			Cropped: variant.Height > 0,
+		})
+	}
+	return result, nil
+}
+
+// variantFormat 縮小画像の形式（JPEG は JPEG、透過のない WebP は JPEG、それ以外は PNG）
+func variantFormat(img image.Image, mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return "image/jpeg"
+	case "image/webp":
+		if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+			return "image/jpeg"
+		}
+	}
+	return "image/png"
+}
+
+// resize 縮小する（元の画像より大きくなる場合は nil）
+func resize(img image.Image, variant Variant) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if variant.Width <= 0 || srcW == 0 || srcH == 0 {
+		return nil
+	}
+
+	if variant.Height > 0 {
+		if srcW < variant.Width || srcH < variant.Height || (srcW == variant.Width && srcH == variant.Height) {
+			return nil
+		}
+	} else if srcW <= variant.Width {
+		return nil
+	}
+
+	src, width, height := geometry(bounds, Params{Width: variant.Width, Height: variant.Height, Fit: FitCover})
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Src, nil)
+	return dst
+}
+
+// applyOrientation EXIF の Orientation（2〜8）に従って回転・反転する
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 左右反転
+				dx, dy = w-1-x, y
+			case 3: // 180 度回転
+				dx, dy = w-1-x, h-1-y
+			case 4: // 上下反転
+				dx, dy = x, h-1-y
+			case 5: // 左上と右下を結ぶ線で反転
+				dx, dy = y, x
+			case 6: // 時計回りに 90 度回転
+				dx, dy = h-1-y, x
+			case 7: // 右上と左下を結ぶ線で反転
+				dx, dy = h-1-y, w-1-x
+			case 8: // 反時計回りに 90 度回転
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+	return dst
+}
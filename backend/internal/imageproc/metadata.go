@@ -0,0 +1,319 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformed = errors.New("imageproc: malformed image")
+
+// StripMetadata 画像を再エンコードせずに EXIF（位置情報を含む）・XMP・IPTC・コメントを取り除く
+// JPEG の向き（EXIF の Orientation）は、Orientation だけの EXIF を付け直して残す
+func StripMetadata(data []byte, mimeType string) ([]byte, error) {
+	switch mimeType {
+	case "image/jpeg":
+		return stripJPEG(data, jpegOrientation(data))
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	case "image/gif":
+		return stripGIF(data)
+	}
+	return data, nil
+}
+
+// stripJPEG APP1（EXIF・XMP）、APP13（IPTC）などのセグメントと、EOI 以降のデータ
+// （マルチピクチャーのプレビュー画像など）を取り除く。JFIF・ICC プロファイル・Adobe のセグメントは残す
+// orientation が 1 以外なら、JFIF の直後に Orientation だけの EXIF を入れる
+func stripJPEG(data []byte, orientation int) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errMalformed
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:2])
+	pending := orientation != 1
+	i := 2
+	for i+2 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, errMalformed
+		}
+		marker := data[i+1]
+		if pending && marker != 0xE0 && marker != 0xFF {
+			out.Write(orientationSegment(orientation))
+			pending = false
+		}
+		switch {
+		case marker == 0xFF: // 埋め草
+			i++
+			continue
+		case marker == 0xD9: // EOI
+			out.Write(data[i : i+2])
+			return out.Bytes(), nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			out.Write(data[i : i+2])
+			i += 2
+			continue
+		}
+
+		if i+4 > len(data) {
+			return nil, errMalformed
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end < i+4 || end > len(data) {
+			return nil, errMalformed
+		}
+		if !dropJPEGSegment(marker, data[i+4:end]) {
+			out.Write(data[i:end])
+		}
+		i = end
+
+		if marker == 0xDA { // SOS: 次のマーカーまでは圧縮データ
+			next := scanEntropyData(data, i)
+			out.Write(data[i:next])
+			i = next
+		}
+	}
+	return nil, errMalformed
+}
+
+// scanEntropyData 圧縮データの終わり（RST 以外のマーカーの位置）を探す
+func scanEntropyData(data []byte, i int) int {
+	for ; i+1 < len(data); i++ {
+		if data[i] != 0xFF {
+			continue
+		}
+		next := data[i+1]
+		if next == 0x00 || next == 0xFF || (next >= 0xD0 && next <= 0xD7) {
+			continue
+		}
+		return i
+	}
+	return len(data)
+}
+
+func dropJPEGSegment(marker byte, payload []byte) bool {
+	switch {
+	case marker == 0xE0, marker == 0xEE: // JFIF、Adobe
+		return false
+	case marker == 0xE2: // ICC プロファイルは残し、マルチピクチャー（MPF）は取り除く
+		return bytes.HasPrefix(payload, []byte("MPF\x00"))
+	case marker >= 0xE1 && marker <= 0xEF, marker == 0xFE: // その他の APPn、コメント
+		return true
+	}
+	return false
+}
+
+// orientationSegment Orientation タグだけを持つ EXIF の APP1 セグメント
+func orientationSegment(orientation int) []byte {
+	segment := []byte{
+		0xFF, 0xE1, 0x00, 0x22, // APP1、長さ 34
+		'E', 'x', 'i', 'f', 0x00, 0x00,
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, // ビッグエンディアンの TIFF、IFD0 は 8 バイト目から
+		0x00, 0x01, // エントリー 1 つ
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, // Orientation（SHORT が 1 つ）
+		0x00, 0x00, 0x00, 0x00, // 次の IFD はない
+	}
+	binary.BigEndian.PutUint16(segment[28:], uint16(orientation))
+	return segment
+}
+
+// stripPNG テキスト・EXIF・更新日時のチャンクを取り除く
+func stripPNG(data []byte) ([]byte, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return nil, errMalformed
+	}
+
+	var out bytes.Buffer
+	out.WriteString(signature)
+	for i := len(signature); i < len(data); {
+		if i+12 > len(data) {
+			return nil, errMalformed
+		}
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errMalformed
+		}
+		switch string(data[i+4 : i+8]) {
+		case "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+		default:
+			out.Write(data[i:end])
+		}
+		if string(data[i+4:i+8]) == "IEND" {
+			return out.Bytes(), nil
+		}
+		i = end
+	}
+	return nil, errMalformed
+}
+
+// stripWebP EXIF・XMP チャンクを取り除き、VP8X のフラグと RIFF の大きさを直す
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errMalformed
+	}
+
+	out := append([]byte{}, data[:12]...)
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errMalformed
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if size < 0 || end > len(data) {
+			if i+8+size != len(data) { // 最後のチャンクのパディングが省略されている場合は許す
+				return nil, errMalformed
+			}
+			end = len(data)
+		}
+
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte{}, data[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF・XMP があることを示すフラグ
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
+
+// stripGIF コメント拡張と、アニメーションのループ回数（NETSCAPE2.0・ANIMEXTS1.0）以外の
+// アプリケーション拡張（XMP など）を取り除く
+func stripGIF(data []byte) ([]byte, error) {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return nil, errMalformed
+	}
+
+	// ヘッダー・論理画面記述子・グローバルカラーテーブル
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+	if i > len(data) {
+		return nil, errMalformed
+	}
+	out := append([]byte{}, data[:i]...)
+
+	for i < len(data) {
+		start := i
+		switch data[i] {
+		case 0x3B: // トレーラー
+			return append(out, data[i]), nil
+		case 0x21: // 拡張
+			if i+2 > len(data) {
+				return nil, errMalformed
+			}
+			label := data[i+1]
+			end, ok := skipGIFSubBlocks(data, i+2)
+			if !ok {
+				return nil, errMalformed
+			}
+			i = end
+			if label == 0xFE || (label == 0xFF && !isGIFLoopExtension(data[start+2:end])) {
+				continue
+			}
+		case 0x2C: // 画像記述子・ローカルカラーテーブル・LZW の最小コードサイズ・画像データ
+			if i+10 > len(data) {
+				return nil, errMalformed
+			}
+			i += 10
+			if data[i-1]&0x80 != 0 {
+				i += 3 << (data[i-1]&0x07 + 1)
+			}
+			end, ok := skipGIFSubBlocks(data, i+1)
+			if !ok {
+				return nil, errMalformed
+			}
+			i = end
+		default:
+			return nil, errMalformed
+		}
+		out = append(out, data[start:i]...)
+	}
+	return nil, errMalformed
+}
+
+// skipGIFSubBlocks i から始まるデータサブブロックの並び（長さ 0 のブロックで終わる）の次の位置
+func skipGIFSubBlocks(data []byte, i int) (int, bool) {
+	for i < len(data) {
+		size := int(data[i])
+		i += 1 + size
+		if size == 0 {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func isGIFLoopExtension(blocks []byte) bool {
+	return len(blocks) >= 12 && blocks[0] == 11 &&
+		(string(blocks[1:12]) == "NETSCAPE2.0" || string(blocks[1:12]) == "ANIMEXTS1.0")
+}
+
+// jpegOrientation EXIF の Orientation（1〜8、なければ 1）
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) {
+			break
+		}
+		if payload := data[i+4 : end]; marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			return exifOrientation(payload[6:])
+		}
+		i = end
+	}
+	return 1
+}
+
+// exifOrientation TIFF 形式の IFD0 から Orientation タグ（0x0112）を読む
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < count; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if value := int(order.Uint16(tiff[entry+8:])); value >= 1 && value <= 8 {
+				return value
+			}
+			break
+		}
+	}
+	return 1
+}
@@ -0,0 +1,141 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"testing"
+)
+
+// jpegWithSegments JPEG の SOI の直後に segments を入れる
+func jpegWithSegments(t *testing.T, w, h int, segments ...[]byte) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 16), uint8(y * 16), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:2]...), bytes.Join(segments, nil)...), data[2:]...)
+}
+
+// app APPn セグメント
+func app(marker byte, payload string) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// exifWithGPS Orientation と GPS の IFD へのポインターを持つ EXIF
+func exifWithGPS(orientation int) []byte {
+	tiff := []byte{
+		'I', 'I', 0x2A, 0x00, 0x08, 0x00, 0x00, 0x00,
+		0x02, 0x00,
+		0x12, 0x01, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, byte(orientation), 0x00, 0x00, 0x00,
+		0x25, 0x88, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00, 0x1A, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+	}
+	return app(0xE1, "Exif\x00\x00"+string(tiff)+"GPS 35.6N 139.7E")
+}
+
+func TestStripJPEGKeepsOrientationLosslessly(t *testing.T) {
+	icc := app(0xE2, "ICC_PROFILE\x00\x01\x01profile")
+	comment := app(0xFE, "secret comment")
+
+	tests := []struct {
+		name        string
+		orientation int
+	}{
+		{"no rotation", 1},
+		{"rotate 90", 6},
+		{"mirror", 2},
+		{"rotate 270", 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := jpegWithSegments(t, 4, 3, exifWithGPS(tt.orientation), icc, comment)
+			stripped, err := StripMetadata(data, "image/jpeg")
+			if err != nil {
+				t.Fatalf("StripMetadata error: %v", err)
+			}
+			if bytes.Contains(stripped, []byte("GPS")) || bytes.Contains(stripped, []byte("secret")) {
+				t.Error("metadata was not removed")
+			}
+			if !bytes.Contains(stripped, icc) {
+				t.Error("ICC profile was removed")
+			}
+			if got := jpegOrientation(stripped); got != tt.orientation {
+				t.Errorf("orientation = %d, want %d", got, tt.orientation)
+			}
+			// 圧縮データはそのまま（再エンコードしない）
+			scan := bytes.Index(data, []byte{0xFF, 0xDA})
+			if !bytes.HasSuffix(stripped, data[scan:]) {
+				t.Error("image data was re-encoded")
+			}
+			if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+				t.Errorf("decode stripped JPEG: %v", err)
+			}
+		})
+	}
+}
+
+func TestProcessRotatedJPEG(t *testing.T) {
+	data := jpegWithSegments(t, 40, 30, exifWithGPS(6))
+	result, err := Process(data, "image/jpeg", Options{Variants: []Variant{{Name: "small", Width: 20}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Original.Width != 30 || result.Original.Height != 40 {
+		t.Errorf("original = %dx%d, want 30x40", result.Original.Width, result.Original.Height)
+	}
+	if len(result.Variants) != 1 || result.Variants[0].Width != 20 || result.Variants[0].Height != 26 {
+		t.Errorf("variants = %+v, want one rotated 20x26 image", result.Variants)
+	}
+}
+
+func TestStripGIF(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	frame := image.NewPaletted(image.Rect(0, 0, 2, 2), palette)
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}, LoopCount: 0}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if !bytes.Contains(data, []byte("NETSCAPE2.0")) {
+		t.Fatal("test GIF has no loop extension")
+	}
+
+	// 最初の画像の前にコメント拡張と XMP のアプリケーション拡張を入れる
+	at := bytes.Index(data, []byte("\x21\xF9"))
+	extensions := []byte("\x21\xFE\x0Esecret comment\x00" + "\x21\xFF\x0BXMP DataXMP\x09<x:xmpm/>\x00")
+	data = append(append(append([]byte{}, data[:at]...), extensions...), data[at:]...)
+
+	stripped, err := StripMetadata(data, "image/gif")
+	if err != nil {
+		t.Fatalf("StripMetadata error: %v", err)
+	}
+	if bytes.Contains(stripped, []byte("secret")) || bytes.Contains(stripped, []byte("XMP")) {
+		t.Error("metadata was not removed")
+	}
+	decoded, err := gif.DecodeAll(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("decode stripped GIF: %v", err)
+	}
+	if len(decoded.Image) != 2 || decoded.LoopCount != 0 {
+		t.Errorf("decoded %d frames with loop count %d, want an endless animation of 2 frames", len(decoded.Image), decoded.LoopCount)
+	}
+
+	for _, bad := range [][]byte{data[:len(data)-1], data[:20], []byte("GIF89a")} {
+		if _, err := StripMetadata(bad, "image/gif"); err == nil {
+			t.Errorf("StripMetadata(%d bytes) should fail", len(bad))
+		}
+	}
+}
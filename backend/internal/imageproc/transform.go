@@ -0,0 +1,234 @@
+package imageproc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// ErrInvalidParams 画像の変換パラメーターが正しくない
+var ErrInvalidParams = errors.New("imageproc: invalid parameters")
+
+// 大きさの合わせ方
+const (
+	FitContain = "contain" // 縦横比を保って枠に収める（既定）
+	FitCover   = "cover"   // 縦横比を保って枠を埋め、はみ出した部分を中央で切り抜く
+	FitFill    = "fill"    // 縦横比を無視して枠に合わせる
+)
+
+// formats パラメーターの f= で指定できる形式（エンコーダーが登録されていないものは ParseParams でエラーにする）
+var formats = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"webp": "image/webp",
+}
+
+// Params 画像の変換パラメーター（"w=640,h=360,fit=cover,f=webp,q=80" の形式）
+type Params struct {
+	Width   int
+	Height  int
+	Fit     string
+	Format  string // jpeg / png / webp（"" なら元の画像に合わせる）
+	Quality int
+}
+
+// ParseParams パラメーターを解析する（幅・高さは sizes のどれか、同じキーの繰り返しや未知のキーはエラー）
+// sizes を決めておくことで、1つの画像から作られる変換の数を限る（空なら任意の大きさを許す）
+func ParseParams(s string, sizes []int) (Params, error) {
+	p := Params{Fit: FitContain}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" || seen[name] {
+			return Params{}, ErrInvalidParams
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "w":
+			p.Width, err = parseDimension(value, sizes)
+		case "h":
+			p.Height, err = parseDimension(value, sizes)
+		case "fit":
+			if value != FitContain && value != FitCover && value != FitFill {
+				err = ErrInvalidParams
+			}
+			p.Fit = value
+		case "f":
+			// 黙って別の形式で返さない
+			if mimeType, ok := formats[value]; !ok || !CanEncode(mimeType) {
+				err = ErrInvalidParams
+			}
+			p.Format = value
+		case "q":
+			p.Quality, err = strconv.Atoi(value)
+			if err == nil && (p.Quality < 1 || p.Quality > 100) {
+				err = ErrInvalidParams
+			}
+		default:
+			err = ErrInvalidParams
+		}
+		if err != nil {
+			return Params{}, ErrInvalidParams
+		}
+	}
+	if p.Width == 0 && p.Height == 0 {
+		return Params{}, ErrInvalidParams
+	}
+	return p, nil
+}
+
+func parseDimension(value string, sizes []int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 || (len(sizes) > 0 && !slices.Contains(sizes, n)) || strconv.Itoa(n) != value {
+		return 0, ErrInvalidParams
+	}
+	return n, nil
+}
+
+// String 正規化したパラメーター（同じ変換は同じ文字列になる）
+func (p Params) String() string {
+	var parts []string
+	if p.Width > 0 {
+		parts = append(parts, "w="+strconv.Itoa(p.Width))
+	}
+	if p.Height > 0 {
+		parts = append(parts, "h="+strconv.Itoa(p.Height))
+	}
+	if p.Fit != "" && p.Fit != FitContain {
+		parts = append(parts, "fit="+p.Fit)
+	}
+	if p.Format != "" {
+		parts = append(parts, "f="+p.Format)
+	}
+	if p.Quality > 0 {
+		parts = append(parts, "q="+strconv.Itoa(p.Quality))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Sign パラメーターと画像のキーの署名（URL に入れられる 22 文字）
+func Sign(secret []byte, params, key string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(params + "/" + key))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// VerifySignature 署名が正しいか
+func VerifySignature(secret []byte, signature, params, key string) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, params, key)))
+}
+
+// Transform パラメーターに従って画像を縮小・切り抜きする（拡大はしない）
+// 展開する前に画素数を確かめ、opts.MaxPixels を超える画像は ErrTooLarge にする
+func Transform(data []byte, mimeType string, p Params, opts Options) (*Image, error) {
+	if !Supports(mimeType) {
+		return nil, fmt.Errorf("imageproc: unsupported type %s", mimeType)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxPixels > 0 && int64(config.Width)*int64(config.Height) > opts.MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	var img image.Image
+	switch mimeType {
+	case "image/jpeg":
+		if img, err = jpeg.Decode(bytes.NewReader(data)); err == nil {
+			img = applyOrientation(img, jpegOrientation(data))
+		}
+	case "image/gif":
+		img, err = gif.Decode(bytes.NewReader(data))
+	default:
+		img, _, err = image.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	src, width, height := geometry(img.Bounds(), p)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Src, nil)
+
+	quality := p.Quality
+	if quality == 0 {
+		quality = opts.Quality
+	}
+	if quality <= 0 || quality > 100 {
+		quality = 85
+	}
+	// 形式の指定がなければ縮小画像と同じ形式にする
+	format := variantFormat(img, mimeType)
+	if requested, ok := formats[p.Format]; ok {
+		format = requested
+	}
+
+	encoded, err := encode(dst, format, quality)
+	if err != nil {
+		return nil, err
+	}
+	return &Image{
+		Data:    encoded,
+		MIME:    format,
+		Ext:     extensions[format],
+		Width:   width,
+		Height:  height,
+		Cropped: p.Fit == FitCover && p.Width > 0 && p.Height > 0,
+	}, nil
+}
+
+// geometry 元の画像から使う範囲と変換後の大きさ（拡大はしない）
+func geometry(bounds image.Rectangle, p Params) (image.Rectangle, int, int) {
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	src := bounds
+	var width, height int
+
+	switch {
+	case p.Height == 0:
+		width = min(p.Width, srcW)
+		height = srcH * width / srcW
+	case p.Width == 0:
+		height = min(p.Height, srcH)
+		width = srcW * height / srcH
+	case p.Fit == FitFill:
+		width, height = min(p.Width, srcW), min(p.Height, srcH)
+	case p.Fit == FitCover:
+		// 縦横比が合うように中央を切り抜いてから縮小する
+		if srcW*p.Height > srcH*p.Width {
+			w := srcH * p.Width / p.Height
+			src.Min.X += (srcW - w) / 2
+			src.Max.X = src.Min.X + w
+		} else {
+			h := srcW * p.Height / p.Width
+			src.Min.Y += (srcH - h) / 2
+			src.Max.Y = src.Min.Y + h
+		}
+		width, height = src.Dx(), src.Dy()
+		if width > p.Width {
+			width, height = p.Width, p.Height
+		}
+	default:
+		width, height = srcW, srcH
+		if width > p.Width {
+			width, height = p.Width, srcH*p.Width/srcW
+		}
+		if height > p.Height {
+			width, height = srcW*p.Height/srcH, p.Height
+		}
+	}
+	return src, max(1, width), max(1, height)
+}
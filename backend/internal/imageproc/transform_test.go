@@ -0,0 +1,88 @@
+package imageproc
+
+import (
+	"image"
+	"io"
+	"testing"
+)
+
+func TestParseParams(t *testing.T) {
+	sizes := []int{320, 640, 1280}
+	tests := []struct {
+		in   string
+		want string // 正規化した文字列（"" はエラー）
+	}{
+		{"w=640", "w=640"},
+		{"h=320,w=640", "w=640,h=320"},
+		{"w=640,h=320,fit=cover,f=jpeg,q=80", "w=640,h=320,fit=cover,f=jpeg,q=80"},
+		{"w=640,fit=contain", "w=640"},
+		{"w=641", ""},
+		{"w=4096", ""},
+		{"w=0640", ""},
+		{"w=640,w=320", ""},
+		{"w=640,x=1", ""},
+		{"fit=cover", ""},
+		{"w=640,fit=stretch", ""},
+		{"w=640,f=gif", ""},
+		{"w=640,q=0", ""},
+		{"w=640,q=101", ""},
+		{"w=", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		p, err := ParseParams(tt.in, sizes)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ParseParams(%q) = %q, want error", tt.in, p)
+			}
+			continue
+		}
+		if err != nil || p.String() != tt.want {
+			t.Errorf("ParseParams(%q) = %q, %v; want %q", tt.in, p, err, tt.want)
+		}
+	}
+
+	if p, err := ParseParams("w=641", nil); err != nil || p.Width != 641 {
+		t.Errorf("ParseParams without sizes = %+v, %v; want any width", p, err)
+	}
+}
+
+// エンコーダーのない形式は黙って JPEG・PNG で返さずにエラーにする
+func TestParseParamsWebP(t *testing.T) {
+	if _, err := ParseParams("w=640,f=webp", nil); err == nil {
+		t.Fatal("ParseParams(f=webp) without an encoder should fail")
+	}
+
+	RegisterEncoder("image/webp", func(w io.Writer, img image.Image, quality int) error { return nil })
+	t.Cleanup(func() {
+		encodersMu.Lock()
+		delete(encoders, "image/webp")
+		encodersMu.Unlock()
+	})
+	if p, err := ParseParams("w=640,f=webp", nil); err != nil || p.String() != "w=640,f=webp" {
+		t.Errorf("ParseParams(f=webp) with an encoder = %q, %v", p, err)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("secret")
+	sig := Sign(secret, "w=640", "2024/05/a.jpg")
+	tests := []struct {
+		name             string
+		secret           []byte
+		sig, params, key string
+		want             bool
+	}{
+		{"valid", secret, sig, "w=640", "2024/05/a.jpg", true},
+		{"other params", secret, sig, "w=1280", "2024/05/a.jpg", false},
+		{"other key", secret, sig, "w=640", "2024/05/b.jpg", false},
+		{"other secret", []byte("other"), sig, "w=640", "2024/05/a.jpg", false},
+		{"truncated", secret, sig[:len(sig)-1], "w=640", "2024/05/a.jpg", false},
+		{"empty", secret, "", "w=640", "2024/05/a.jpg", false},
+	}
+	for _, tt := range tests {
+		if got := VerifySignature(tt.secret, tt.sig, tt.params, tt.key); got != tt.want {
+			t.Errorf("%s: VerifySignature = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
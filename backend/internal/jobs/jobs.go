@@ -0,0 +1,88 @@
+// Package jobs はサーバープロセス内で定期実行するバックグラウンドジョブを管理する。
+//
+// 複数のレプリカが同時に起動していても、各ジョブは PostgreSQL の
+// アドバイザリロックを取得できた1台だけが実行する。
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Job 定期実行されるバックグラウンドジョブ
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// Run はアドバイザリロックを保持したトランザクション内で呼ばれる
+	Run func(ctx context.Context, tx *gorm.DB) error
+}
+
+type afterCommitKey struct{}
+
+// AfterCommit Run の中から呼び、トランザクションがコミットされたあとに fn を実行する
+// ファイルの削除などロールバックで戻せない処理は、行の削除が確定してから行う
+func AfterCommit(ctx context.Context, fn func()) {
+	if hooks, ok := ctx.Value(afterCommitKey{}).(*[]func()); ok {
+		*hooks = append(*hooks, fn)
+		return
+	}
+	fn()
+}
+
+// Start 各ジョブを個別のゴルーチンで定期実行する（ctx がキャンセルされるまで）
+func Start(ctx context.Context, db *gorm.DB, jobs ...Job) {
+	for _, job := range jobs {
+		if job.Interval <= 0 {
+			log.Printf("Job %s disabled (interval %v)", job.Name, job.Interval)
+			continue
+		}
+		go loop(ctx, db, job)
+	}
+}
+
+func loop(ctx context.Context, db *gorm.DB, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := RunOnce(ctx, db, job); err != nil {
+			log.Printf("Job %s failed: %v", job.Name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce ロックを取得できた場合のみジョブを1回実行する
+// 他のレプリカが実行中ならなにもせずに nil を返す
+func RunOnce(ctx context.Context, db *gorm.DB, job Job) error {
+	var hooks []func()
+	runCtx := context.WithValue(ctx, afterCommitKey{}, &hooks)
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw(
+			"SELECT pg_try_advisory_xact_lock(hashtext(?))",
+			"blogapp.jobs."+job.Name,
+		).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		return job.Run(runCtx, tx)
+	})
+	if err != nil {
+		return err
+	}
+	for _, fn := range hooks {
+		fn()
+	}
+	return nil
+}
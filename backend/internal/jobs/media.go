@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"blogapp/database"
+	"blogapp/internal/imagecache"
+	"blogapp/internal/storage"
+	"blogapp/models"
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CleanupOrphanMedia どの投稿からも参照されないまま maxAge を過ぎたメディアをファイルごと削除するジョブ
+// 変換した画像のキャッシュ（cache、nil なら使わない）からも消す
+func CleanupOrphanMedia(interval, maxAge time.Duration, store storage.Storage, cache *imagecache.Cache) Job {
+	if maxAge <= 0 {
+		interval = 0
+	}
+	return Job{
+		Name:     "cleanup_orphan_media",
+		Interval: interval,
+		Run: func(ctx context.Context, tx *gorm.DB) error {
+			var media []models.Media
+			if err := database.UnreferencedMedia(tx, time.Now().Add(-maxAge)).
+				Preload("Variants").
+				Order("id").Limit(500).
+				Find(&media).Error; err != nil {
+				return err
+			}
+
+			// ファイルはトランザクションがコミットされてから消す（ロールバックされると行だけ残るため）
+			var keys []string
+			var deleted int
+			for i := range media {
+				// 探してから削除するまでに投稿で使われた場合は残す（縮小画像の行は外部キーで一緒に消える）
+				result := database.UnreferencedMedia(tx, time.Now().Add(-maxAge)).
+					Where("id = ?", media[i].ID).
+					Delete(&models.Media{})
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected == 0 {
+					continue
+				}
+				keys = append(keys, media[i].Key)
+				for _, variant := range media[i].Variants {
+					keys = append(keys, variant.Key)
+				}
+				deleted++
+			}
+			if deleted == 0 {
+				return nil
+			}
+
+			AfterCommit(ctx, func() {
+				for _, key := range keys {
+					if err := store.Delete(ctx, key); err != nil {
+						log.Printf("Failed to delete orphan media file %s: %v", key, err)
+					}
+					if cache != nil {
+						if err := cache.RemoveSource(key); err != nil {
+							log.Printf("Failed to purge image cache for %s: %v", key, err)
+						}
+					}
+				}
+				log.Printf("Deleted %d orphan media files", deleted)
+			})
+			return nil
+		},
+	}
+}
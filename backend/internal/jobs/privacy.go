@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"blogapp/database"
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AnonymizeCommentIPs 保存期間を過ぎたコメントの IP アドレスとユーザーエージェントを消すジョブ
+func AnonymizeCommentIPs(interval, retention time.Duration) Job {
+	if retention <= 0 {
+		interval = 0
+	}
+	return Job{
+		Name:     "anonymize_comment_ips",
+		Interval: interval,
+		Run: func(ctx context.Context, tx *gorm.DB) error {
+			anonymized, err := database.AnonymizeCommentIPs(tx, time.Now().Add(-retention))
+			if err != nil {
+				return err
+			}
+			if anonymized > 0 {
+				log.Printf("Anonymized IP addresses of %d comments", anonymized)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"blogapp/models"
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PublishScheduledPosts 公開日時を過ぎた予約投稿を公開するジョブ
+func PublishScheduledPosts(interval time.Duration) Job {
+	return Job{
+		Name:     "publish_scheduled_posts",
+		Interval: interval,
+		Run:      publishScheduledPosts,
+	}
+}
+
+func publishScheduledPosts(ctx context.Context, tx *gorm.DB) error {
+	var posts []models.Post
+	if err := tx.
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND published_at <= ?", models.PostStatusScheduled, time.Now()).
+		Find(&posts).Error; err != nil {
+		return err
+	}
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	// 予約状態のままの行だけを更新（手動で下書きに戻された投稿は対象外）
+	if err := tx.Session(&gorm.Session{SkipHooks: true}).Model(&models.Post{}).
+		Where("id IN ? AND status = ?", ids, models.PostStatusScheduled).
+		Updates(map[string]interface{}{
+			"status":    models.PostStatusPublished,
+			"published": true,
+		}).Error; err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		log.Printf("Scheduled post published: %s (id=%d)", post.Title, post.ID)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"blogapp/database"
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PrunePostRevisions 保持ポリシーを超えた投稿リビジョンを削除するジョブ
+func PrunePostRevisions(interval time.Duration, policy database.RevisionRetention) Job {
+	return Job{
+		Name:     "prune_post_revisions",
+		Interval: interval,
+		Run: func(ctx context.Context, tx *gorm.DB) error {
+			deleted, err := database.PruneAllPostRevisions(tx, policy)
+			if err != nil {
+				return err
+			}
+			if deleted > 0 {
+				log.Printf("Pruned %d post revisions", deleted)
+			}
+			return nil
+		},
+	}
+}
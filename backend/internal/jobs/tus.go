@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"blogapp/models"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CleanupTusUploads 期限を過ぎた再開可能なアップロードを、受け取り途中のファイルごと削除するジョブ
+// 完了したアップロードは状態の確認用に期限まで残している行だけを消す
+func CleanupTusUploads(interval time.Duration, dir string) Job {
+	return Job{
+		Name:     "cleanup_tus_uploads",
+		Interval: interval,
+		Run: func(ctx context.Context, tx *gorm.DB) error {
+			var uploads []models.TusUpload
+			if err := tx.Select("id").
+				Where("expires_at < ?", time.Now()).
+				Order("expires_at").Limit(500).
+				Find(&uploads).Error; err != nil {
+				return err
+			}
+			if len(uploads) == 0 {
+				return nil
+			}
+
+			ids := make([]string, len(uploads))
+			for i, upload := range uploads {
+				ids[i] = upload.ID
+			}
+			if err := tx.Where("id IN ?", ids).Delete(&models.TusUpload{}).Error; err != nil {
+				return err
+			}
+			AfterCommit(ctx, func() {
+				for _, id := range ids {
+					if err := os.Remove(filepath.Join(dir, id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+						log.Printf("Failed to remove expired tus upload %s: %v", id, err)
+					}
+				}
+				log.Printf("Deleted %d expired uploads", len(ids))
+			})
+			return nil
+		},
+	}
+}
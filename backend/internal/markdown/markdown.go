@@ -0,0 +1,277 @@
+// Package markdown は投稿本文の Markdown をサニタイズ済みの HTML と目次に変換する。
+//
+// CommonMark + GFM（テーブル・打ち消し線・自動リンク・タスクリスト）と脚注に対応し、
+// コードブロックは chroma でシンタックスハイライト（CSS クラス出力）、
+// 見出しには ID とアンカーリンクを付与する。著者が書いた生の HTML は
+// bluemonday の許可リストでサニタイズされる。
+package markdown
+
+import (
+	"bytes"
+	stdhtml "html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Version レンダリング結果が変わる変更をしたら上げる（キャッシュ済み HTML の再生成に使う）
+const Version = 1
+
+// HighlightStyle コードブロックのハイライトに使う chroma のスタイル
+const HighlightStyle = "github"
+
+// Heading 目次の1項目
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+// Result レンダリング結果
+type Result struct {
+	HTML string
+	TOC  []Heading
+}
+
+var (
+	formatter = chromahtml.New(chromahtml.WithClasses(true))
+
+	md = goldmark.New(
+		goldmark.WithExtensions(extension.GFM, extension.Footnote),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+			parser.WithASTTransformers(util.Prioritized(headingTransformer{}, 100)),
+		),
+		goldmark.WithRendererOptions(
+			// 生の HTML は出力し、後段の bluemonday でサニタイズする
+			html.WithUnsafe(),
+			renderer.WithNodeRenderers(util.Prioritized(codeBlockRenderer{}, 100)),
+		),
+	)
+
+	policy = newPolicy()
+)
+
+// Render Markdown をサニタイズ済み HTML と目次に変換
+func Render(source string) (*Result, error) {
+	var toc []Heading
+	ctx := parser.NewContext(parser.WithIDs(newIDs()))
+	ctx.Set(tocKey, &toc)
+
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(source), &buf, parser.WithContext(ctx)); err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		HTML: policy.Sanitize(buf.String()),
+		TOC:  toc,
+	}, nil
+}
+
+// HighlightCSS コードブロック用のスタイルシートを返す
+func HighlightCSS() (string, error) {
+	var buf bytes.Buffer
+	if err := formatter.WriteCSS(&buf, styles.Get(HighlightStyle)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+
+	identifier := regexp.MustCompile(`^[\p{L}\p{N}_:.-]+$`)
+	classNames := regexp.MustCompile(`^[a-zA-Z0-9 _-]+$`)
+
+	// 見出しアンカー・脚注のリンク先
+	p.AllowAttrs("id").Matching(identifier).OnElements(
+		"h1", "h2", "h3", "h4", "h5", "h6", "li", "sup", "div", "section",
+	)
+	// シンタックスハイライト・脚注・アンカーのクラス
+	p.AllowAttrs("class").Matching(classNames).OnElements(
+		"pre", "code", "span", "a", "sup", "div", "section", "li", "hr", "ol",
+	)
+	p.AllowAttrs("role").Matching(regexp.MustCompile(`^doc-[a-z]+$`)).OnElements("a", "div", "section")
+	// タスクリストのチェックボックス
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	// テーブルの配置
+	p.AllowStyles("text-align").MatchingEnum("left", "right", "center").OnElements("th", "td")
+
+	return p
+}
+
+// --- コードブロックのハイライト ---
+
+type codeBlockRenderer struct{}
+
+func (r codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.render)
+}
+
+func (codeBlockRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.FencedCodeBlock)
+	var code bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		code.Write(segment.Value(source))
+	}
+
+	lang := string(n.Language(source))
+	lexer := lexers.Get(lang)
+	if lang == "" || lexer == nil {
+		// 言語指定がなければハイライトせずにそのまま出力
+		w.WriteString("<pre><code>")
+		w.Write(util.EscapeHTML(code.Bytes()))
+		w.WriteString("</code></pre>\n")
+		return ast.WalkSkipChildren, nil
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code.String())
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	if err := formatter.Format(w, styles.Get(HighlightStyle), iterator); err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// --- 見出し（目次とアンカー） ---
+
+var tocKey = parser.NewContextKey()
+
+type headingTransformer struct{}
+
+func (headingTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	toc, _ := pc.Get(tocKey).(*[]Heading)
+	source := reader.Source()
+
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := node.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+
+		value, ok := heading.AttributeString("id")
+		if !ok {
+			return ast.WalkSkipChildren, nil
+		}
+		id := string(value.([]byte))
+
+		if toc != nil {
+			*toc = append(*toc, Heading{
+				Level: heading.Level,
+				Text:  strings.TrimSpace(plainText(heading, source)),
+				ID:    id,
+			})
+		}
+
+		anchor := ast.NewLink()
+		anchor.Destination = []byte("#" + id)
+		anchor.SetAttributeString("class", []byte("heading-anchor"))
+		anchor.AppendChild(anchor, ast.NewString([]byte("#")))
+		heading.AppendChild(heading, anchor)
+
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// plainText 見出しなどのインライン要素から装飾を除いたテキストを取り出す
+func plainText(node ast.Node, source []byte) string {
+	var sb strings.Builder
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		switch n := child.(type) {
+		case *ast.Text:
+			sb.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			sb.Write(n.Value)
+		default:
+			sb.WriteString(plainText(child, source))
+		}
+	}
+	return sb.String()
+}
+
+// ids 日本語の見出しも残す ID 生成（goldmark 標準は ASCII 以外を落とすため）
+type ids struct {
+	used map[string]bool
+}
+
+func newIDs() *ids {
+	return &ids{used: map[string]bool{}}
+}
+
+func (s *ids) Generate(value []byte, kind ast.NodeKind) []byte {
+	var sb strings.Builder
+	lastHyphen := false
+	for _, r := range strings.ToLower(strings.TrimSpace(string(value))) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			sb.WriteRune(r)
+			lastHyphen = false
+		case (unicode.IsSpace(r) || r == '-') && !lastHyphen && sb.Len() > 0:
+			sb.WriteByte('-')
+			lastHyphen = true
+		}
+	}
+
+	base := strings.TrimSuffix(sb.String(), "-")
+	if base == "" {
+		base = "section"
+	}
+
+	id := base
+	for i := 1; s.used[id]; i++ {
+		id = base + "-" + strconv.Itoa(i)
+	}
+	s.used[id] = true
+	return []byte(id)
+}
+
+func (s *ids) Put(value []byte) {
+	s.used[string(value)] = true
+}
+
+var (
+	headingAnchor = regexp.MustCompile(`<a [^>]*class="heading-anchor"[^>]*>#</a>`)
+	blockEnd      = regexp.MustCompile(`</(?:p|h[1-6]|li|blockquote|pre|td|th)>|<br ?/?>`)
+	textPolicy    = bluemonday.StrictPolicy()
+)
+
+// Excerpt レンダリングした HTML からタグを除いたプレーンテキストの抜粋（maxRunes 文字を超えたら … で切る）
+func Excerpt(renderedHTML string, maxRunes int) string {
+	// ブロックの境目は空白にする（インラインの要素の前後には入れない）
+	text := blockEnd.ReplaceAllString(headingAnchor.ReplaceAllString(renderedHTML, ""), "$0\n")
+	text = textPolicy.Sanitize(text)
+	text = strings.Join(strings.Fields(stdhtml.UnescapeString(text)), " ")
+	runes := []rune(text)
+	if len(runes) <= maxRunes {
+		return text
+	}
+	return strings.TrimRightFunc(string(runes[:maxRunes]), unicode.IsSpace) + "…"
+}
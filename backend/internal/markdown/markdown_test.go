@@ -0,0 +1,161 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		maxRunes int
+		want     string
+	}{
+		{"empty", "", 10, ""},
+		{"plain", "<p>Hello, world</p>", 100, "Hello, world"},
+		{"blocks are separated", "<h2>Title</h2><p>First</p><ul><li>one</li><li>two</li></ul>", 100, "Title First one two"},
+		{"inline elements are not separated", "<p>Go<strong>lang</strong> and <a href=\"/x\">links</a></p>", 100, "Golang and links"},
+		{"line break", "<p>one<br>two<br />three</p>", 100, "one two three"},
+		{"whitespace is collapsed", "<p>  a\n\n  b\t c  </p>", 100, "a b c"},
+		{"entities are unescaped", "<p>&lt;tag&gt; &amp; &quot;quote&quot;</p>", 100, "<tag> & \"quote\""},
+		{"script is dropped", "<p>safe</p><script>alert(1)</script>", 100, "safe"},
+		{"heading anchor is dropped", "<h2 id=\"intro\">Intro<a href=\"#intro\" class=\"heading-anchor\">#</a></h2><p>Body</p>", 100, "Intro Body"},
+		{"exact length", "<p>abcde</p>", 5, "abcde"},
+		{"truncated", "<p>abcdef</p>", 5, "abcde…"},
+		{"truncated by runes", "<p>こんにちは世界</p>", 5, "こんにちは…"},
+		{"trailing space before ellipsis", "<p>abc def</p>", 4, "abc…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Excerpt(tt.html, tt.maxRunes); got != tt.want {
+				t.Errorf("Excerpt(%q, %d) = %q, want %q", tt.html, tt.maxRunes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcerptRendered(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		maxRunes int
+		want     string
+	}{
+		{"heading and paragraph", "# はじめに\n\n本文です。", 100, "はじめに 本文です。"},
+		{"emphasis and code", "Use **bold** and `code`.", 100, "Use bold and code."},
+		{"list", "- one\n- two\n", 100, "one two"},
+		{"code block", "```go\nfmt.Println(\"hi\")\n```", 100, "fmt.Println(\"hi\")"},
+		{"raw html is sanitized", "<script>alert(1)</script>\n\ntext", 100, "text"},
+		{"truncated", "# Title\n\nLong paragraph here.", 10, "Title Long…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Render(tt.source)
+			if err != nil {
+				t.Fatalf("Render(%q) error: %v", tt.source, err)
+			}
+			if got := Excerpt(result.HTML, tt.maxRunes); got != tt.want {
+				t.Errorf("Excerpt(Render(%q)) = %q, want %q (html: %q)", tt.source, got, tt.want, result.HTML)
+			}
+		})
+	}
+}
+
+func TestRenderSanitizes(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		keep    string   // 残る部分
+		removed []string // 出力に含まれてはいけない文字列
+	}{
+		{"script", "<script>alert(1)</script>\n\nok", "<p>ok</p>", []string{"<script", "alert"}},
+		{"javascript markdown link", "[x](javascript:alert(1))", "x", []string{"javascript:", "href"}},
+		{"javascript html link", `<a href="javascript:alert(1)">y</a>`, "y", []string{"javascript:", "href"}},
+		{"onerror attribute", "<img src=x onerror=alert(1)>", `<img src="x">`, []string{"onerror", "alert"}},
+		{"onclick attribute", `<p onclick="x()">p</p>`, "<p>p</p>", []string{"onclick"}},
+		{"iframe and style", `<iframe src="https://evil"></iframe><style>p{}</style>text`, "text", []string{"<iframe", "<style", "evil"}},
+		{"style attribute", `<p style="position:fixed">p</p>`, "<p>p</p>", []string{"style=", "position"}},
+		// クラスは許可した文字だけ
+		{"class injection", `<span class="a&quot; onclick=&quot;x">s</span>`, "s", []string{"onclick"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Render(tt.source)
+			if err != nil {
+				t.Fatalf("Render error: %v", err)
+			}
+			if !strings.Contains(result.HTML, tt.keep) {
+				t.Errorf("Render(%q) = %q, want it to contain %q", tt.source, result.HTML, tt.keep)
+			}
+			for _, s := range tt.removed {
+				if strings.Contains(result.HTML, s) {
+					t.Errorf("Render(%q) = %q, must not contain %q", tt.source, result.HTML, s)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderFeatures(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"table with alignment", "| a | b |\n|:-|-:|\n| 1 | 2 |", []string{
+			"<table>", `<th style="text-align: left">a</th>`, `<td style="text-align: right">2</td>`,
+		}},
+		{"task list", "- [x] done\n- [ ] todo", []string{
+			`<input checked="" disabled="" type="checkbox"> done`, `<input disabled="" type="checkbox"> todo`,
+		}},
+		{"footnote", "text[^1]\n\n[^1]: note", []string{
+			`<sup id="fnref:1"><a href="#fn:1" class="footnote-ref" role="doc-noteref"`,
+			`<div class="footnotes" role="doc-endnotes">`, `<li id="fn:1">`, `class="footnote-backref"`,
+		}},
+		{"heading anchor", "## Getting Started", []string{
+			`<h2 id="getting-started">Getting Started<a href="#getting-started" class="heading-anchor"`,
+		}},
+		{"japanese heading id", "## 見出し です", []string{`<h2 id="見出し-です">`}},
+		{"chroma classes", "```go\nfunc main() {}\n```", []string{
+			`<pre class="chroma">`, `<span class="kd">func</span>`, `<span class="nf">main</span>`,
+		}},
+		{"unknown language", "```nosuchlang\n<b>x</b>\n```", []string{"<pre><code>&lt;b&gt;x&lt;/b&gt;\n</code></pre>"}},
+		{"strikethrough and autolink", "~~del~~ https://example.com", []string{
+			"<del>del</del>", `<a href="https://example.com" rel="nofollow">https://example.com</a>`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Render(tt.source)
+			if err != nil {
+				t.Fatalf("Render error: %v", err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(result.HTML, s) {
+					t.Errorf("Render(%q) = %q, want it to contain %q", tt.source, result.HTML, s)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderTOC(t *testing.T) {
+	result, err := Render("# Title\n\n## **Bold** part\n\n## 見出し\n\n## 見出し\n\n### !!!")
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	want := []Heading{
+		{Level: 1, Text: "Title", ID: "title"},
+		{Level: 2, Text: "Bold part", ID: "bold-part"},
+		{Level: 2, Text: "見出し", ID: "見出し"},
+		// 同じ見出しには連番を付ける
+		{Level: 2, Text: "見出し", ID: "見出し-1"},
+		// ID に使える文字がなければ section
+		{Level: 3, Text: "!!!", ID: "section"},
+	}
+	if !reflect.DeepEqual(result.TOC, want) {
+		t.Errorf("TOC = %+v, want %+v", result.TOC, want)
+	}
+}
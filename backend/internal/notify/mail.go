@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"bytes"
+	"html/template"
+)
+
+// Message 送信するメール
+type Message struct {
+	To      string
+	Subject string
+	Body    string // HTML
+	Headers map[string]string
+}
+
+// Sender メールを送信する関数（utils.SendEmail を渡す）
+type Sender func(to, subject, body string, headers map[string]string) error
+
+// Send メッセージを送信
+func (s Sender) Send(m *Message) error {
+	return s(m.To, m.Subject, m.Body, m.Headers)
+}
+
+// CommentMail 通知メールに差し込むコメントの情報
+type CommentMail struct {
+	Recipient      string // 宛名
+	PostTitle      string
+	PostURL        string
+	Author         string
+	Content        string
+	Status         string // pending / approved / spam
+	ParentContent  string // 返信通知のみ
+	UnsubscribeURL string
+}
+
+var (
+	newCommentTemplate = template.Must(template.New("new_comment").Parse(`<html>
+<body>
+	<h2>新しいコメントが届きました</h2>
+	<p>{{.Recipient}} 様</p>
+	<p>投稿「<a href="{{.PostURL}}">{{.PostTitle}}</a>」に {{.Author}} さんからコメントがありました。</p>
+	{{if eq .Status "pending"}}<p>このコメントは承認待ちです。管理画面から確認してください。</p>{{end}}
+	{{if eq .Status "spam"}}<p>このコメントはスパムと判定されました。誤判定の場合は管理画面から承認してください。</p>{{end}}
+	<blockquote style="white-space: pre-wrap;">{{.Content}}</blockquote>
+	<hr>
+	<p style="font-size: 12px; color: #666;">この通知が不要な場合は<a href="{{.UnsubscribeURL}}">配信を停止</a>できます。</p>
+</body>
+</html>`))
+
+	replyTemplate = template.Must(template.New("reply").Parse(`<html>
+<body>
+	<h2>あなたのコメントに返信がありました</h2>
+	<p>{{.Recipient}} 様</p>
+	<p>投稿「<a href="{{.PostURL}}">{{.PostTitle}}</a>」のあなたのコメントに {{.Author}} さんが返信しました。</p>
+	<blockquote style="white-space: pre-wrap; color: #666;">{{.ParentContent}}</blockquote>
+	<blockquote style="white-space: pre-wrap;">{{.Content}}</blockquote>
+	<p><a href="{{.PostURL}}">返信を見る</a></p>
+	<hr>
+	<p style="font-size: 12px; color: #666;">返信の通知が不要な場合は<a href="{{.UnsubscribeURL}}">配信を停止</a>できます。</p>
+</body>
+</html>`))
+)
+
+// NewCommentMessage 投稿の著者への新着コメント通知
+func NewCommentMessage(to string, data *CommentMail) (*Message, error) {
+	return render(to, "【ブログ】新しいコメント: "+data.PostTitle, newCommentTemplate, data)
+}
+
+// ReplyMessage コメントした人への返信通知
+func ReplyMessage(to string, data *CommentMail) (*Message, error) {
+	return render(to, "【ブログ】コメントに返信がありました: "+data.PostTitle, replyTemplate, data)
+}
+
+// render テンプレートに差し込み、ワンクリック配信停止（RFC 8058）のヘッダーを付ける
+func render(to, subject string, tmpl *template.Template, data *CommentMail) (*Message, error) {
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, data); err != nil {
+		return nil, err
+	}
+	return &Message{
+		To:      to,
+		Subject: subject,
+		Body:    body.String(),
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + data.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}, nil
+}
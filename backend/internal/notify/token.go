@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Kind 配信停止の対象の種類
+type Kind string
+
+const (
+	// KindCommenter 返信通知（ID はコメントID。同じメールアドレスのコメントすべての通知を止める）
+	KindCommenter Kind = "c"
+	// KindUser 著者への新着コメント通知（ID はユーザーID）
+	KindUser Kind = "u"
+)
+
+var errInvalidToken = errors.New("invalid unsubscribe token")
+
+// IssueUnsubscribeToken 配信停止リンク用の署名付きトークンを発行する
+// 形式は "<種類>.<ID>.<署名>"（メールアドレスは URL に含めない）
+func IssueUnsubscribeToken(secret []byte, kind Kind, id uint) string {
+	payload := string(kind) + "." + strconv.FormatUint(uint64(id), 10)
+	return payload + "." + sign(secret, payload)
+}
+
+// VerifyUnsubscribeToken トークンを検証して対象の種類と ID を返す
+func VerifyUnsubscribeToken(secret []byte, token string) (Kind, uint, error) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return "", 0, errInvalidToken
+	}
+	payload, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(sign(secret, payload))) {
+		return "", 0, errInvalidToken
+	}
+
+	kind, rawID, _ := strings.Cut(payload, ".")
+	if Kind(kind) != KindCommenter && Kind(kind) != KindUser {
+		return "", 0, errInvalidToken
+	}
+	id, err := strconv.ParseUint(rawID, 10, 64)
+	if err != nil || id == 0 {
+		return "", 0, errInvalidToken
+	}
+	return Kind(kind), uint(id), nil
+}
+
+func sign(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("unsubscribe:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
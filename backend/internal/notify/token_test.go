@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnsubscribeTokenRoundTrip(t *testing.T) {
+	secret := []byte("secret")
+	tests := []struct {
+		kind Kind
+		id   uint
+	}{
+		{KindCommenter, 1},
+		{KindCommenter, 42},
+		{KindUser, 7},
+		{KindUser, 4000000000},
+	}
+	for _, tt := range tests {
+		token := IssueUnsubscribeToken(secret, tt.kind, tt.id)
+		kind, id, err := VerifyUnsubscribeToken(secret, token)
+		if err != nil {
+			t.Errorf("VerifyUnsubscribeToken(%q) error: %v", token, err)
+			continue
+		}
+		if kind != tt.kind || id != tt.id {
+			t.Errorf("VerifyUnsubscribeToken(%q) = %q, %d; want %q, %d", token, kind, id, tt.kind, tt.id)
+		}
+	}
+}
+
+func TestVerifyUnsubscribeTokenRejects(t *testing.T) {
+	secret := []byte("secret")
+	valid := IssueUnsubscribeToken(secret, KindCommenter, 42)
+	// 署名は正しいが中身が不正なトークン
+	signed := func(payload string) string {
+		return payload + "." + sign(secret, payload)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"no signature", "c.42"},
+		{"tampered id", strings.Replace(valid, ".42.", ".43.", 1)},
+		{"tampered kind", "u" + valid[1:]},
+		{"truncated signature", valid[:len(valid)-1]},
+		{"other secret", IssueUnsubscribeToken([]byte("other"), KindCommenter, 42)},
+		{"unknown kind", signed("x.42")},
+		{"zero id", signed("c.0")},
+		{"negative id", signed("c.-1")},
+		{"non-numeric id", signed("c.abc")},
+		{"missing id", signed("c")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if kind, id, err := VerifyUnsubscribeToken(secret, tt.token); err == nil {
+				t.Errorf("VerifyUnsubscribeToken(%q) = %q, %d; want error", tt.token, kind, id)
+			}
+		})
+	}
+}
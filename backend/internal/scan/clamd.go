@@ -0,0 +1,141 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamd に送るチャンクの大きさの既定値（clamd の StreamMaxLength とは別に、1回に送る大きさ）
+const defaultChunkSize = 64 << 10
+
+// ErrClamd clamd がエラーを返した（INSTREAM size limit exceeded など）
+var ErrClamd = errors.New("clamd error")
+
+// Clamd clamd の INSTREAM コマンドで検査する
+type Clamd struct {
+	Network   string        // "tcp" または "unix"
+	Address   string        // "127.0.0.1:3310" や "/var/run/clamav/clamd.ctl"
+	Timeout   time.Duration // 接続から結果を受け取るまで（0 なら期限なし）
+	ChunkSize int           // 0 なら 64KB
+}
+
+// NewClamd "tcp://host:port"、"unix:///path"、"host:port"、"/path" のいずれかのアドレスから作る
+func NewClamd(address string, timeout time.Duration) (*Clamd, error) {
+	network := "tcp"
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		address = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "unix://"):
+		network, address = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "/"):
+		network = "unix"
+	}
+	if address == "" {
+		return nil, fmt.Errorf("scan: clamd address is empty")
+	}
+	return &Clamd{Network: network, Address: address, Timeout: timeout}, nil
+}
+
+func (*Clamd) Name() string { return "clamd" }
+
+// Scan INSTREAM でファイルを送って結果を受け取る
+func (s *Clamd) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.Network, s.Address)
+	if err != nil {
+		return Result{}, fmt.Errorf("scan: connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// キャンセルされたら読み書き中でも接続を閉じる
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if err := s.stream(conn, r); err != nil {
+		// clamd が上限を超えたなどで先に応答して切断した場合は、その応答を返す
+		if reply, readErr := readReply(conn); readErr == nil && reply != "" {
+			return parseReply(reply)
+		}
+		return Result{}, fmt.Errorf("scan: send to clamd: %w", err)
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return Result{}, fmt.Errorf("scan: read clamd reply: %w", err)
+	}
+	return parseReply(reply)
+}
+
+// stream コマンドと、長さ（4バイトのビッグエンディアン）を前に付けたチャンクを送り、長さ 0 で終える
+func (s *Clamd) stream(w io.Writer, r io.Reader) error {
+	if _, err := io.WriteString(w, "zINSTREAM\x00"); err != nil {
+		return err
+	}
+
+	size := s.ChunkSize
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	buf := make([]byte, 4+size)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := w.Write(buf[:4+n]); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// readReply NUL で終わる応答を読む（z 付きのコマンドの応答は NUL で終わる）
+func readReply(r io.Reader) (string, error) {
+	reply, err := io.ReadAll(io.LimitReader(r, 4096))
+	if err != nil && len(reply) == 0 {
+		return "", err
+	}
+	if i := bytes.IndexByte(reply, 0); i >= 0 {
+		reply = reply[:i]
+	}
+	return strings.TrimSpace(string(reply)), nil
+}
+
+// parseReply "stream: OK"、"stream: <名前> FOUND"、"<メッセージ> ERROR" を解釈する
+func parseReply(reply string) (Result, error) {
+	message := reply
+	if i := strings.Index(reply, ": "); i >= 0 {
+		message = reply[i+2:]
+	}
+	switch {
+	case message == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(message, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(message, " FOUND")}, nil
+	case strings.HasSuffix(message, " ERROR"):
+		return Result{}, fmt.Errorf("%w: %s", ErrClamd, strings.TrimSuffix(message, " ERROR"))
+	}
+	return Result{}, fmt.Errorf("%w: unexpected reply %q", ErrClamd, reply)
+}
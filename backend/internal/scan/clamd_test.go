@@ -0,0 +1,245 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClamd INSTREAM だけに応答するテスト用の clamd
+type fakeClamd struct {
+	listener net.Listener
+	reply    func(data []byte) string // 受け取ったデータへの応答（"" なら応答せずに待つ）
+	limit    int                      // 0 でなければ、これを超えたら途中で応答して切断する（StreamMaxLength）
+
+	mu       sync.Mutex
+	received [][]byte // 接続ごとに受け取ったデータ
+	chunks   []int    // 受け取ったチャンクの大きさ
+	errs     []error  // プロトコルの誤り
+}
+
+func startFakeClamd(t *testing.T, network string, reply func([]byte) string) *fakeClamd {
+	t.Helper()
+	address := "127.0.0.1:0"
+	if network == "unix" {
+		address = filepath.Join(t.TempDir(), "clamd.sock")
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeClamd{listener: listener, reply: reply}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeClamd) scanner(network string) *Clamd {
+	return &Clamd{Network: network, Address: f.listener.Addr().String(), Timeout: 2 * time.Second, ChunkSize: 1000}
+}
+
+func (f *fakeClamd) fail(err error) {
+	f.mu.Lock()
+	f.errs = append(f.errs, err)
+	f.mu.Unlock()
+}
+
+func (f *fakeClamd) serve(conn net.Conn) {
+	defer conn.Close()
+
+	command := make([]byte, len("zINSTREAM\x00"))
+	if _, err := io.ReadFull(conn, command); err != nil || string(command) != "zINSTREAM\x00" {
+		f.fail(errors.New("bad command " + string(command)))
+		return
+	}
+
+	var data []byte
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			f.fail(err)
+			return
+		}
+		n := int(binary.BigEndian.Uint32(size[:]))
+		if n == 0 {
+			break
+		}
+		f.mu.Lock()
+		f.chunks = append(f.chunks, n)
+		f.mu.Unlock()
+
+		chunk := make([]byte, n)
+		if _, err := io.ReadFull(conn, chunk); err != nil {
+			f.fail(err)
+			return
+		}
+		data = append(data, chunk...)
+		if f.limit > 0 && len(data) > f.limit {
+			conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+			return
+		}
+	}
+
+	f.mu.Lock()
+	f.received = append(f.received, data)
+	f.mu.Unlock()
+	if reply := f.reply(data); reply != "" {
+		conn.Write([]byte(reply + "\x00"))
+		return
+	}
+	io.Copy(io.Discard, conn) // 応答せずに切断されるまで待つ
+}
+
+func eicarReply(data []byte) string {
+	switch {
+	case bytes.Contains(data, []byte("EICAR")):
+		return "stream: Eicar-Test-Signature FOUND"
+	case bytes.Contains(data, []byte("broken")):
+		return "stream: Can't allocate memory ERROR"
+	case bytes.Contains(data, []byte("silent")):
+		return ""
+	case bytes.Contains(data, []byte("weird")):
+		return "PONG"
+	}
+	return "stream: OK"
+}
+
+func TestClamdScan(t *testing.T) {
+	large := bytes.Repeat([]byte("a"), 2500)
+	tests := []struct {
+		name      string
+		data      []byte
+		infected  bool
+		signature string
+		err       error
+	}{
+		{"clean", []byte("hello"), false, "", nil},
+		{"empty", nil, false, "", nil},
+		{"several chunks", large, false, "", nil},
+		{"found", []byte("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR"), true, "Eicar-Test-Signature", nil},
+		{"error reply", []byte("broken"), false, "", ErrClamd},
+		{"unexpected reply", []byte("weird"), false, "", ErrClamd},
+	}
+	for _, network := range []string{"tcp", "unix"} {
+		fake := startFakeClamd(t, network, eicarReply)
+		for _, tt := range tests {
+			t.Run(network+"/"+tt.name, func(t *testing.T) {
+				result, err := fake.scanner(network).Scan(context.Background(), bytes.NewReader(tt.data))
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Scan error = %v, want %v", err, tt.err)
+				}
+				if result.Infected != tt.infected || result.Signature != tt.signature {
+					t.Errorf("Scan = %+v, want infected=%v signature=%q", result, tt.infected, tt.signature)
+				}
+			})
+		}
+		fake.mu.Lock()
+		if len(fake.errs) > 0 {
+			t.Errorf("%s: protocol errors: %v", network, fake.errs)
+		}
+		fake.mu.Unlock()
+	}
+}
+
+func TestClamdFraming(t *testing.T) {
+	fake := startFakeClamd(t, "tcp", eicarReply)
+	data := bytes.Repeat([]byte("0123456789"), 250)
+	if _, err := fake.scanner("tcp").Scan(context.Background(), bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.received) != 1 || !bytes.Equal(fake.received[0], data) {
+		t.Fatalf("clamd received %d streams, want the data once", len(fake.received))
+	}
+	// ChunkSize ごとに区切り、最後だけ短い
+	if want := []int{1000, 1000, 500}; len(fake.chunks) != len(want) || fake.chunks[0] != want[0] || fake.chunks[1] != want[1] || fake.chunks[2] != want[2] {
+		t.Errorf("chunks = %v, want %v", fake.chunks, want)
+	}
+}
+
+func TestClamdSizeLimit(t *testing.T) {
+	fake := startFakeClamd(t, "tcp", eicarReply)
+	fake.limit = 1500
+	_, err := fake.scanner("tcp").Scan(context.Background(), bytes.NewReader(make([]byte, 1<<20)))
+	if !errors.Is(err, ErrClamd) || !strings.Contains(err.Error(), "size limit") {
+		t.Errorf("Scan error = %v, want the size limit error from clamd", err)
+	}
+}
+
+func TestClamdUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	s := &Clamd{Network: "tcp", Address: address, Timeout: time.Second}
+	if _, err := s.Scan(context.Background(), strings.NewReader("hello")); err == nil || errors.Is(err, ErrClamd) {
+		t.Errorf("Scan error = %v, want a connection error", err)
+	}
+}
+
+func TestClamdTimeout(t *testing.T) {
+	fake := startFakeClamd(t, "tcp", eicarReply)
+	s := fake.scanner("tcp")
+	s.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	_, err := s.Scan(context.Background(), strings.NewReader("silent"))
+	if err == nil {
+		t.Fatal("Scan should fail when clamd does not reply")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Scan took %v, want about the timeout", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Timeout = 0
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := s.Scan(ctx, strings.NewReader("silent")); err == nil {
+		t.Error("Scan should fail when the context is canceled")
+	}
+}
+
+func TestNewClamd(t *testing.T) {
+	tests := []struct {
+		address, network, want string
+		ok                     bool
+	}{
+		{"127.0.0.1:3310", "tcp", "127.0.0.1:3310", true},
+		{"tcp://clamav:3310", "tcp", "clamav:3310", true},
+		{"unix:///var/run/clamav/clamd.ctl", "unix", "/var/run/clamav/clamd.ctl", true},
+		{"/var/run/clamav/clamd.ctl", "unix", "/var/run/clamav/clamd.ctl", true},
+		{"", "", "", false},
+		{"tcp://", "", "", false},
+	}
+	for _, tt := range tests {
+		s, err := NewClamd(tt.address, time.Second)
+		if (err == nil) != tt.ok {
+			t.Errorf("NewClamd(%q) error = %v, want ok=%v", tt.address, err, tt.ok)
+			continue
+		}
+		if tt.ok && (s.Network != tt.network || s.Address != tt.want) {
+			t.Errorf("NewClamd(%q) = %s %s, want %s %s", tt.address, s.Network, s.Address, tt.network, tt.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+// Package scan はアップロードされたファイルのマルウェア検査を提供する。
+//
+// Scanner を実装すれば別の検査エンジンも使える。Clamd は clamd の INSTREAM プロトコルで
+// TCP または Unix ソケットの clamd にファイルを送って検査する。
+package scan
+
+import (
+	"context"
+	"io"
+)
+
+// Result 検査の結果
+type Result struct {
+	Infected  bool
+	Signature string // 検出したマルウェアの名前（Infected のときのみ）
+}
+
+// Scanner ファイルを検査する
+// 検査できなかった場合（接続できない、大きすぎるなど）はエラーを返す
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
@@ -1,10 +1,18 @@
+package security
+
+import (
+	"log"
+
+	"github.com/sethvargo/go-password/password"
+)
+
+// GenerateFirstAdminPassword 初期管理者のパスワードを生成してログに出す
 func GenerateFirstAdminPassword() string {
-//    pwd, _ := password.Generate(16, 4, 0, false, false)
-    pwd, err := password.Generate(16, 4, 0, false, false)
-    if err != nil {
-        log.Fatal(err)
-    }
-    
-    log.Printf("Admin password: %s (保存してください)", pwd)
-    return pwd
+	pwd, err := password.Generate(16, 4, 0, false, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Admin password: %s (保存してください)", pwd)
+	return pwd
 }
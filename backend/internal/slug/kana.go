@@ -0,0 +1,131 @@
+package slug
+
+import (
+	"strings"
+)
+
+// kanaRomaji ひらがな → ローマ字（ヘボン式）
+var kanaRomaji = map[string]string{
+	"あ": "a", "い": "i", "う": "u", "え": "e", "お": "o",
+	"か": "ka", "き": "ki", "く": "ku", "け": "ke", "こ": "ko",
+	"が": "ga", "ぎ": "gi", "ぐ": "gu", "げ": "ge", "ご": "go",
+	"さ": "sa", "し": "shi", "す": "su", "せ": "se", "そ": "so",
+	"ざ": "za", "じ": "ji", "ず": "zu", "ぜ": "ze", "ぞ": "zo",
+	"た": "ta", "ち": "chi", "つ": "tsu", "て": "te", "と": "to",
+	"だ": "da", "ぢ": "ji", "づ": "zu", "で": "de", "ど": "do",
+	"な": "na", "に": "ni", "ぬ": "nu", "ね": "ne", "の": "no",
+	"は": "ha", "ひ": "hi", "ふ": "fu", "へ": "he", "ほ": "ho",
+	"ば": "ba", "び": "bi", "ぶ": "bu", "べ": "be", "ぼ": "bo",
+	"ぱ": "pa", "ぴ": "pi", "ぷ": "pu", "ぺ": "pe", "ぽ": "po",
+	"ま": "ma", "み": "mi", "む": "mu", "め": "me", "も": "mo",
+	"や": "ya", "ゆ": "yu", "よ": "yo",
+	"ら": "ra", "り": "ri", "る": "ru", "れ": "re", "ろ": "ro",
+	"わ": "wa", "ゐ": "i", "ゑ": "e", "を": "o", "ん": "n",
+	"ゔ": "vu",
+	"ぁ": "a", "ぃ": "i", "ぅ": "u", "ぇ": "e", "ぉ": "o",
+	"ゃ": "ya", "ゅ": "yu", "ょ": "yo", "ゎ": "wa",
+
+	// 外来語の表記
+	"ふぁ": "fa", "ふぃ": "fi", "ふぇ": "fe", "ふぉ": "fo",
+	"てぃ": "ti", "でぃ": "di", "とぅ": "tu", "どぅ": "du",
+	"うぃ": "wi", "うぇ": "we", "うぉ": "wo",
+	"ゔぁ": "va", "ゔぃ": "vi", "ゔぇ": "ve", "ゔぉ": "vo",
+	"いぇ": "ye", "くぁ": "kwa", "つぁ": "tsa",
+}
+
+func init() {
+	// 拗音（きゃ・しゃ など）
+	youon := map[string]string{
+		"き": "ky", "ぎ": "gy", "に": "ny", "ひ": "hy", "び": "by",
+		"ぴ": "py", "み": "my", "り": "ry",
+	}
+	for kana, prefix := range youon {
+		kanaRomaji[kana+"ゃ"] = prefix + "a"
+		kanaRomaji[kana+"ゅ"] = prefix + "u"
+		kanaRomaji[kana+"ょ"] = prefix + "o"
+	}
+
+	palatal := map[string]string{"し": "sh", "じ": "j", "ち": "ch", "ぢ": "j"}
+	for kana, prefix := range palatal {
+		kanaRomaji[kana+"ゃ"] = prefix + "a"
+		kanaRomaji[kana+"ゅ"] = prefix + "u"
+		kanaRomaji[kana+"ょ"] = prefix + "o"
+		kanaRomaji[kana+"ぇ"] = prefix + "e"
+	}
+}
+
+// toHiragana カタカナをひらがなに変換
+func toHiragana(r rune) rune {
+	if r >= 'ァ' && r <= 'ヶ' {
+		return r - ('ァ' - 'ぁ')
+	}
+	return r
+}
+
+// romanizeKana 文字列中のかな・カナをローマ字に置き換える（それ以外の文字はそのまま）
+func romanizeKana(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = toHiragana(r)
+	}
+
+	var sb strings.Builder
+	sokuon := false
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		if r == 'っ' {
+			sokuon = true
+			i++
+			continue
+		}
+		if r == 'ー' {
+			// 長音は省略（例: コーヒー → kohi）
+			i++
+			continue
+		}
+
+		romaji, width := "", 0
+		if i+1 < len(runes) {
+			if v, ok := kanaRomaji[string(runes[i:i+2])]; ok {
+				romaji, width = v, 2
+			}
+		}
+		if width == 0 {
+			if v, ok := kanaRomaji[string(r)]; ok {
+				romaji, width = v, 1
+			}
+		}
+
+		if width == 0 {
+			sokuon = false
+			sb.WriteRune(r)
+			i++
+			continue
+		}
+
+		// 促音は次の子音を重ねる（ch の前は t）
+		if sokuon {
+			if strings.HasPrefix(romaji, "ch") {
+				sb.WriteByte('t')
+			} else if c := romaji[0]; !strings.ContainsRune("aiueon", rune(c)) {
+				sb.WriteByte(c)
+			}
+			sokuon = false
+		}
+		// 語を区切るため、かなとそれ以外の文字の境界に空白を入れる
+		if i > 0 && !isKana(runes[i-1]) {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(romaji)
+		i += width
+		if i < len(runes) && !isKana(runes[i]) {
+			sb.WriteByte(' ')
+		}
+	}
+	return sb.String()
+}
+
+func isKana(r rune) bool {
+	return (r >= 'ぁ' && r <= 'ゖ') || r == 'ー' || r == 'っ'
+}
@@ -0,0 +1,101 @@
+// Package slug は投稿・カテゴリー・タグの URL スラッグを生成する。
+//
+// Unicode を NFKC で正規化し、かな・カナはヘボン式ローマ字に変換、
+// アクセント記号は取り除く。漢字は読みが分からずローマ字化できないため、
+// 漢字を1文字でも含む文字列や何も残らない場合は空文字を返す（呼び出し側で ID ベースのスラッグにする）。
+// 漢字だけを落とすと「東京タワー」が "tawa" になるように意味の違うスラッグになってしまうため。
+package slug
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+)
+
+// MaxLength 生成するスラッグの最大長（重複回避の接尾辞は含まない）
+const MaxLength = 80
+
+// Make タイトルや名前からスラッグを生成（生成できなければ空文字）
+func Make(s string) string {
+	s = norm.NFKC.String(s)
+	if containsHan(s) {
+		return ""
+	}
+	s = romanizeKana(s)
+	s = norm.NFD.String(s)
+
+	var sb strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// 分解したアクセント記号を落とす（é → e）
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if hyphen && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			hyphen = false
+		default:
+			hyphen = true
+		}
+	}
+
+	return truncate(sb.String(), MaxLength)
+}
+
+// Fallback ローマ字化できない名前用の ID ベースのスラッグ（例: post-12）
+func Fallback(prefix string, id uint) string {
+	return fmt.Sprintf("%s-%d", prefix, id)
+}
+
+// Unique 同じテーブルに重複がない場合はそのまま、ある場合は -2, -3 ... を付けて返す
+// 論理削除済みの行も一意インデックスに含まれるため対象にする
+func Unique(db *gorm.DB, model interface{}, base string, excludeID uint) (string, error) {
+	var taken []string
+	query := db.Unscoped().Model(model).
+		Where("slug = ? OR slug LIKE ?", base, base+"-%")
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	if err := query.Pluck("slug", &taken).Error; err != nil {
+		return "", err
+	}
+
+	used := make(map[string]bool, len(taken))
+	for _, s := range taken {
+		used[s] = true
+	}
+	if !used[base] {
+		return base, nil
+	}
+	for i := 2; ; i++ {
+		candidate := base + "-" + strconv.Itoa(i)
+		if !used[candidate] {
+			return candidate, nil
+		}
+	}
+}
+
+// containsHan 漢字を含むか
+func containsHan(s string) bool {
+	return strings.ContainsFunc(s, func(r rune) bool {
+		return unicode.Is(unicode.Han, r)
+	})
+}
+
+// truncate 単語の途中で切れないよう、上限を超える場合は直前のハイフンで切る
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	s = s[:max]
+	if i := strings.LastIndexByte(s, '-'); i > max/2 {
+		s = s[:i]
+	}
+	return strings.Trim(s, "-")
+}
@@ -0,0 +1,88 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"empty", "", ""},
+		{"symbols only", "!!!", ""},
+		{"latin", "Hello, World!", "hello-world"},
+		{"accents", "Café au lait", "cafe-au-lait"},
+		{"fullwidth", "ＧＯ　ＬＡＮＧ", "go-lang"},
+		{"hiragana and latin", "はじめてのGo", "hajimeteno-go"},
+		{"katakana and digits", "ホテル123", "hoteru-123"},
+		{"particle between words", "Vue.jsとReact", "vue-js-to-react"},
+		{"youon", "しゃしん", "shashin"},
+		{"youon with long vowel", "きゃりーぱみゅぱみゅ", "kyaripamyupamyu"},
+		{"foreign kana", "ファイル", "fairu"},
+		{"vu", "ヴァイオリン", "vaiorin"},
+		// 長音は省略する
+		{"long vowel", "コーヒー", "kohi"},
+		{"long vowel in the middle", "ラーメン", "ramen"},
+		// 促音は次の子音を重ねる（ch の前は t）
+		{"small tsu", "きっぷ", "kippu"},
+		{"small tsu before shi", "ざっし", "zasshi"},
+		{"small tsu before chi", "マッチ", "matchi"},
+		{"small tsu before youon", "ちょっと", "chotto"},
+		{"small tsu and long vowel", "ティーカップ", "tikappu"},
+		// 漢字を1文字でも含めば ID ベースのスラッグにする
+		{"kanji only", "東京", ""},
+		{"kanji and katakana", "東京タワー", ""},
+		{"latin and kanji", "Go言語入門", ""},
+		{"single kanji in latin", "Go 入門 guide for beginners", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Make(tt.in); got != tt.want {
+				t.Errorf("Make(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeTruncates(t *testing.T) {
+	got := Make(strings.Repeat("word ", 30))
+	if len(got) > MaxLength || strings.HasSuffix(got, "-") || !strings.HasSuffix(got, "word") {
+		t.Errorf("Make(long) = %q (%d bytes), want at most %d bytes ending with a whole word", got, len(got), MaxLength)
+	}
+
+	// 区切りがなければそのまま切る
+	if got := Make(strings.Repeat("a", 100)); got != strings.Repeat("a", MaxLength) {
+		t.Errorf("Make(100 letters) = %q", got)
+	}
+}
+
+func TestRomanizeKana(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"あいうえお", "aiueo"},
+		{"カタカナ", "katakana"},
+		{"じゃあね", "jaane"},
+		{"っ", ""},
+		// 促音の後がかなでなければ重ねない
+		{"っA", "A"},
+		{"んー", "n"},
+		{"AのB", "A no B"},
+		{"漢字とかな", "漢字 tokana"},
+	}
+	for _, tt := range tests {
+		if got := romanizeKana(tt.in); got != tt.want {
+			t.Errorf("romanizeKana(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	if got := Fallback("post", 12); got != "post-12" {
+		t.Errorf("Fallback = %q, want %q", got, "post-12")
+	}
+}
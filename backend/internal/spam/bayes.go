@@ -0,0 +1,145 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// TotalsToken 学習したコメント数を記録するための予約トークン（Tokenize が返すことはない）
+const TotalsToken = "*total*"
+
+// TokenCount トークンがスパム・非スパムのコメントに現れた回数
+type TokenCount struct {
+	Spam int64
+	Ham  int64
+}
+
+// TokenStore 単純ベイズ分類器の学習データ
+// Counts は TotalsToken を含めて問い合わせられ、その値は学習したコメント数を表す
+type TokenStore interface {
+	Counts(ctx context.Context, tokens []string) (map[string]TokenCount, error)
+	Train(ctx context.Context, tokens []string, spamDelta, hamDelta int64) error
+}
+
+// Bayes 学習済みのトークンの出現頻度からスパムらしさを判定する
+// スパム・非スパムどちらも MinTrained 件以上学習するまではスコアを付けない
+type Bayes struct {
+	Store      TokenStore
+	MinTrained int64
+	MaxScore   float64 // スパムである確率が 1 のときのスコア（確率 0.5 以下は 0）
+}
+
+func (Bayes) Name() string { return "bayes" }
+
+func (r Bayes) Check(ctx context.Context, in *Input) (Result, error) {
+	tokens := Tokenize(in.Author + " " + in.Content)
+	if len(tokens) == 0 {
+		return Result{}, nil
+	}
+
+	counts, err := r.Store.Counts(ctx, append(tokens, TotalsToken))
+	if err != nil {
+		return Result{}, err
+	}
+	totals := counts[TotalsToken]
+	if totals.Spam < r.MinTrained || totals.Ham < r.MinTrained {
+		return Result{Reason: "not enough training data"}, nil
+	}
+
+	p := spamProbability(tokens, counts, totals)
+	result := Result{Reason: fmt.Sprintf("spam probability %.1f%%", p*100)}
+	if p > 0.5 {
+		result.Score = r.MaxScore * (p - 0.5) * 2
+	}
+	return result, nil
+}
+
+// Learn コメントを学習する（spam が false なら非スパムとして学習）
+func Learn(ctx context.Context, store TokenStore, author, content string, spam bool) error {
+	return train(ctx, store, author, content, spam, 1)
+}
+
+// Unlearn Learn で学習した内容を取り消す（モデレーターが判定を覆した場合）
+func Unlearn(ctx context.Context, store TokenStore, author, content string, spam bool) error {
+	return train(ctx, store, author, content, spam, -1)
+}
+
+func train(ctx context.Context, store TokenStore, author, content string, spam bool, delta int64) error {
+	tokens := append(Tokenize(author+" "+content), TotalsToken)
+	if spam {
+		return store.Train(ctx, tokens, delta, 0)
+	}
+	return store.Train(ctx, tokens, 0, delta)
+}
+
+// spamProbability 単純ベイズ（ラプラス平滑化）でスパムである確率を求める
+func spamProbability(tokens []string, counts map[string]TokenCount, totals TokenCount) float64 {
+	spamDocs := float64(totals.Spam)
+	hamDocs := float64(totals.Ham)
+
+	logSpam := math.Log(spamDocs / (spamDocs + hamDocs))
+	logHam := math.Log(hamDocs / (spamDocs + hamDocs))
+	for _, token := range tokens {
+		count, ok := counts[token]
+		if !ok {
+			// どちらでも見たことのないトークンは判定に使わない
+			continue
+		}
+		logSpam += math.Log((float64(count.Spam) + 1) / (spamDocs + 2))
+		logHam += math.Log((float64(count.Ham) + 1) / (hamDocs + 2))
+	}
+	return 1 / (1 + math.Exp(logHam-logSpam))
+}
+
+// MaxTokens Tokenize が返すトークンの数の上限（学習データへの問い合わせのパラメーター数を限る）
+const MaxTokens = 1000
+
+// Tokenize 本文を学習・判定用のトークンに分割する（重複は除き、先頭から MaxTokens 個まで）
+// 英数字は小文字にした単語（2〜30文字）、CJK は隣り合う2文字ずつ（bi-gram）にする
+func Tokenize(text string) []string {
+	seen := map[string]bool{}
+	var tokens []string
+	add := func(token string) {
+		if !seen[token] && len(tokens) < MaxTokens {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	var word, cjk []rune
+	flushWord := func() {
+		if n := len(word); n >= 2 && n <= 30 {
+			add(string(word))
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		if len(cjk) == 1 {
+			add(string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			add(string(cjk[i : i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
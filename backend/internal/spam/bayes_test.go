@@ -0,0 +1,175 @@
+package spam
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// memStore メモリ上の学習データ
+type memStore map[string]TokenCount
+
+func (s memStore) Counts(_ context.Context, tokens []string) (map[string]TokenCount, error) {
+	counts := map[string]TokenCount{}
+	for _, token := range tokens {
+		if count, ok := s[token]; ok {
+			counts[token] = count
+		}
+	}
+	return counts, nil
+}
+
+func (s memStore) Train(_ context.Context, tokens []string, spamDelta, hamDelta int64) error {
+	for _, token := range tokens {
+		count := s[token]
+		count.Spam = max(count.Spam+spamDelta, 0)
+		count.Ham = max(count.Ham+hamDelta, 0)
+		s[token] = count
+	}
+	return nil
+}
+
+type failingStore struct{}
+
+func (failingStore) Counts(context.Context, []string) (map[string]TokenCount, error) {
+	return nil, errors.New("db down")
+}
+
+func (failingStore) Train(context.Context, []string, int64, int64) error {
+	return errors.New("db down")
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"words", "Hello, World!", []string{"hello", "world"}},
+		{"duplicates", "buy buy BUY", []string{"buy"}},
+		{"single letters", "a b c", nil},
+		{"digits", "x1 42", []string{"x1", "42"}},
+		{"too long", strings.Repeat("a", 31) + " ok", []string{"ok"}},
+		{"max length", strings.Repeat("a", 30), []string{strings.Repeat("a", 30)}},
+		{"cjk bigram", "東京都", []string{"東京", "京都"}},
+		{"cjk single", "猫", []string{"猫"}},
+		{"kana", "スパム", []string{"スパ", "パム"}},
+		{"mixed", "Go言語で書く", []string{"go", "言語", "語で", "で書", "書く"}},
+		{"cjk split by space", "東京 大阪", []string{"東京", "大阪"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tokenize(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenizeLimit(t *testing.T) {
+	words := make([]string, MaxTokens*2)
+	for i := range words {
+		words[i] = "w" + strconv.Itoa(i)
+	}
+	tokens := Tokenize(strings.Join(words, " "))
+	if len(tokens) != MaxTokens {
+		t.Fatalf("len(Tokenize) = %d, want %d", len(tokens), MaxTokens)
+	}
+	if tokens[0] != "w0" || tokens[MaxTokens-1] != "w"+strconv.Itoa(MaxTokens-1) {
+		t.Errorf("Tokenize should keep the first tokens, got %q ... %q", tokens[0], tokens[MaxTokens-1])
+	}
+}
+
+func trainedStore(t *testing.T, n int) memStore {
+	t.Helper()
+	store := memStore{}
+	for i := 0; i < n; i++ {
+		if err := Learn(context.Background(), store, "bot", "cheap pills casino bonus", true); err != nil {
+			t.Fatal(err)
+		}
+		if err := Learn(context.Background(), store, "reader", "great article thanks", false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestBayesCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		store     TokenStore
+		content   string
+		wantScore func(float64) bool
+		reason    string
+	}{
+		{"no tokens", trainedStore(t, 10), "!!", func(s float64) bool { return s == 0 }, ""},
+		{"not enough training", trainedStore(t, 9), "cheap casino", func(s float64) bool { return s == 0 }, "not enough training data"},
+		{"spam", trainedStore(t, 10), "cheap casino bonus", func(s float64) bool { return s > 4.9 && s <= 5 }, ""},
+		{"ham", trainedStore(t, 10), "great article", func(s float64) bool { return s == 0 }, ""},
+		// 学習していないトークンだけなら事前確率（50%）のまま
+		{"unknown tokens", trainedStore(t, 10), "completely unrelated", func(s float64) bool { return s == 0 }, "spam probability 50.0%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := Bayes{Store: tt.store, MinTrained: 10, MaxScore: 5}
+			result, err := rule.Check(context.Background(), &Input{Author: "someone", Content: tt.content})
+			if err != nil {
+				t.Fatalf("Check error: %v", err)
+			}
+			if !tt.wantScore(result.Score) {
+				t.Errorf("score = %v (%s)", result.Score, result.Reason)
+			}
+			if tt.reason != "" && result.Reason != tt.reason {
+				t.Errorf("reason = %q, want %q", result.Reason, tt.reason)
+			}
+		})
+	}
+
+	if _, err := (Bayes{Store: failingStore{}}).Check(context.Background(), &Input{Content: "hello"}); err == nil {
+		t.Error("Check should return the store error")
+	}
+}
+
+func TestUnlearn(t *testing.T) {
+	store := trainedStore(t, 10)
+	want := store["cheap"]
+	ctx := context.Background()
+	if err := Learn(ctx, store, "bot", "cheap", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unlearn(ctx, store, "bot", "cheap", true); err != nil {
+		t.Fatal(err)
+	}
+	if store["cheap"] != want || store[TotalsToken] != (TokenCount{Spam: 10, Ham: 10}) {
+		t.Errorf("Unlearn should restore counts, got %+v totals %+v", store["cheap"], store[TotalsToken])
+	}
+}
+
+func TestSpamProbability(t *testing.T) {
+	totals := TokenCount{Spam: 10, Ham: 10}
+	tests := []struct {
+		name   string
+		tokens []string
+		counts map[string]TokenCount
+		want   float64
+	}{
+		{"no tokens", nil, nil, 0.5},
+		{"unseen", []string{"x"}, map[string]TokenCount{}, 0.5},
+		{"balanced", []string{"x"}, map[string]TokenCount{"x": {Spam: 5, Ham: 5}}, 0.5},
+		// (10+1)/12 と (0+1)/12 の比
+		{"spam only", []string{"x"}, map[string]TokenCount{"x": {Spam: 10}}, 11.0 / 12},
+		{"ham only", []string{"x"}, map[string]TokenCount{"x": {Ham: 10}}, 1.0 / 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := spamProbability(tt.tokens, tt.counts, totals); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("spamProbability = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Honeypot 隠し入力欄が埋められていればスパム
+type Honeypot struct {
+	Score float64
+}
+
+func (Honeypot) Name() string { return "honeypot" }
+
+func (r Honeypot) Check(_ context.Context, in *Input) (Result, error) {
+	if strings.TrimSpace(in.Honeypot) == "" {
+		return Result{}, nil
+	}
+	return Result{Score: r.Score, Reason: "hidden field was filled in"}, nil
+}
+
+// SubmitTime フォーム表示から送信までの時間をトークンで確認する
+// トークンがない・不正・期限切れ、または MinAge より早い送信にスコアを付ける
+type SubmitTime struct {
+	Secret []byte
+	MinAge time.Duration
+	MaxAge time.Duration
+
+	MissingScore float64 // トークンがない・不正・期限切れ
+	TooFastScore float64 // MinAge より早い
+}
+
+func (SubmitTime) Name() string { return "submit_time" }
+
+func (r SubmitTime) Check(_ context.Context, in *Input) (Result, error) {
+	if in.Token == "" {
+		return Result{Score: r.MissingScore, Reason: "no form token"}, nil
+	}
+
+	issuedAt, err := VerifyToken(r.Secret, in.Token, in.PostID)
+	if err != nil {
+		return Result{Score: r.MissingScore, Reason: err.Error()}, nil
+	}
+
+	age := in.Now.Sub(issuedAt)
+	switch {
+	case age < r.MinAge:
+		return Result{Score: r.TooFastScore, Reason: fmt.Sprintf("submitted %.1fs after the form was shown", age.Seconds())}, nil
+	case r.MaxAge > 0 && age > r.MaxAge:
+		return Result{Score: r.MissingScore, Reason: "form token expired"}, nil
+	}
+	return Result{}, nil
+}
+
+var linkPattern = regexp.MustCompile(`(?i)https?://|www\.|<a\s|\[url`)
+
+// LinkCount 本文のリンクが Max 個を超えたら、超えた数ごとにスコアを付ける
+type LinkCount struct {
+	Max          int
+	ScorePerLink float64
+}
+
+func (LinkCount) Name() string { return "link_count" }
+
+func (r LinkCount) Check(_ context.Context, in *Input) (Result, error) {
+	links := len(linkPattern.FindAllStringIndex(in.Content, -1))
+	if links <= r.Max {
+		return Result{}, nil
+	}
+	return Result{
+		Score:  float64(links-r.Max) * r.ScorePerLink,
+		Reason: fmt.Sprintf("%d links (max %d)", links, r.Max),
+	}, nil
+}
+
+// Blocklist 禁止語が投稿者名・メール・本文に含まれるごとにスコアを付ける（大文字小文字は区別しない）
+type Blocklist struct {
+	Words       []string
+	ScorePerHit float64
+}
+
+func (Blocklist) Name() string { return "blocklist" }
+
+func (r Blocklist) Check(_ context.Context, in *Input) (Result, error) {
+	text := strings.ToLower(in.Author + "\n" + in.Email + "\n" + in.Content)
+
+	var hits []string
+	for _, word := range r.Words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" && strings.Contains(text, word) {
+			hits = append(hits, word)
+		}
+	}
+	if len(hits) == 0 {
+		return Result{}, nil
+	}
+	return Result{
+		Score:  float64(len(hits)) * r.ScorePerHit,
+		Reason: "blocked words: " + strings.Join(hits, ", "),
+	}, nil
+}
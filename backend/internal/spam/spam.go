@@ -0,0 +1,77 @@
+// Package spam はコメントのスパム判定パイプラインを提供する。
+//
+// パイプラインは複数のルール（Rule）を順に実行し、各ルールのスコアを合計して
+// しきい値以上ならスパムと判定する。ルールごとのスコアと理由は Report に残るので、
+// モデレーターが判定の根拠を確認できる。
+package spam
+
+import (
+	"context"
+	"time"
+)
+
+// Input 判定対象のコメント
+type Input struct {
+	PostID    uint
+	Author    string
+	Email     string
+	Content   string
+	IP        string
+	UserAgent string
+
+	Honeypot string // 人には見えない入力欄の値（ボットだけが埋める）
+	Token    string // フォーム表示時に発行したトークン（IssueToken）
+
+	Now time.Time
+}
+
+// Result ルール1つの判定結果
+type Result struct {
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+// Rule スパム判定のルール
+type Rule interface {
+	Name() string
+	Check(ctx context.Context, in *Input) (Result, error)
+}
+
+// RuleResult レポート中のルールごとの結果
+type RuleResult struct {
+	Rule string `json:"rule"`
+	Result
+}
+
+// Report パイプライン全体の判定結果
+type Report struct {
+	Score   float64      `json:"score"`
+	Spam    bool         `json:"spam"`
+	Results []RuleResult `json:"results"`
+}
+
+// Pipeline ルールを順に実行してスコアを合計する
+type Pipeline struct {
+	Rules     []Rule
+	Threshold float64 // 合計がこれ以上ならスパム
+}
+
+// Run すべてのルールを実行する
+// ルールがエラーを返した場合はそのルールをスコア 0 として記録し、判定は続ける
+func (p *Pipeline) Run(ctx context.Context, in *Input) *Report {
+	if in.Now.IsZero() {
+		in.Now = time.Now()
+	}
+
+	report := &Report{Results: make([]RuleResult, 0, len(p.Rules))}
+	for _, rule := range p.Rules {
+		result, err := rule.Check(ctx, in)
+		if err != nil {
+			result = Result{Reason: "error: " + err.Error()}
+		}
+		report.Score += result.Score
+		report.Results = append(report.Results, RuleResult{Rule: rule.Name(), Result: result})
+	}
+	report.Spam = p.Threshold > 0 && report.Score >= p.Threshold
+	return report
+}
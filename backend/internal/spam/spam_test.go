@@ -0,0 +1,85 @@
+package spam
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fixedRule 決まった結果を返すルール
+type fixedRule struct {
+	name   string
+	result Result
+	err    error
+}
+
+func (r fixedRule) Name() string { return r.name }
+
+func (r fixedRule) Check(context.Context, *Input) (Result, error) { return r.result, r.err }
+
+func TestPipelineRun(t *testing.T) {
+	honeypot := Honeypot{Score: 10}
+	links := LinkCount{Max: 1, ScorePerLink: 1.5}
+
+	tests := []struct {
+		name      string
+		rules     []Rule
+		threshold float64
+		in        Input
+		wantScore float64
+		wantSpam  bool
+	}{
+		{"no rules", nil, 5, Input{}, 0, false},
+		{"clean comment", []Rule{honeypot, links}, 5, Input{Content: "nice post"}, 0, false},
+		{"honeypot", []Rule{honeypot, links}, 5, Input{Honeypot: "http://spam.example"}, 10, true},
+		{"sum below threshold", []Rule{honeypot, links}, 5, Input{Content: "http://a http://b http://c"}, 3, false},
+		{"sum reaches threshold", []Rule{links, fixedRule{name: "extra", result: Result{Score: 2}}}, 5, Input{Content: "http://a http://b http://c"}, 5, true},
+		// しきい値が 0 なら判定しない
+		{"no threshold", []Rule{honeypot}, 0, Input{Honeypot: "x"}, 10, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Pipeline{Rules: tt.rules, Threshold: tt.threshold}
+			report := p.Run(context.Background(), &tt.in)
+			if report.Score != tt.wantScore || report.Spam != tt.wantSpam {
+				t.Errorf("Run = score %v spam %v, want %v %v", report.Score, report.Spam, tt.wantScore, tt.wantSpam)
+			}
+			if len(report.Results) != len(tt.rules) {
+				t.Errorf("len(Results) = %d, want %d", len(report.Results), len(tt.rules))
+			}
+		})
+	}
+}
+
+// エラーを返したルールはスコア 0 として記録し、残りのルールは実行する
+func TestPipelineRuleError(t *testing.T) {
+	p := Pipeline{
+		Rules: []Rule{
+			fixedRule{name: "broken", result: Result{Score: 100}, err: errors.New("db down")},
+			fixedRule{name: "ok", result: Result{Score: 3, Reason: "fine"}},
+		},
+		Threshold: 5,
+	}
+	report := p.Run(context.Background(), &Input{})
+	if report.Score != 3 || report.Spam {
+		t.Errorf("Run = score %v spam %v, want 3 false", report.Score, report.Spam)
+	}
+	want := []RuleResult{
+		{Rule: "broken", Result: Result{Reason: "error: db down"}},
+		{Rule: "ok", Result: Result{Score: 3, Reason: "fine"}},
+	}
+	for i, r := range report.Results {
+		if r != want[i] {
+			t.Errorf("Results[%d] = %+v, want %+v", i, r, want[i])
+		}
+	}
+}
+
+func TestPipelineSetsNow(t *testing.T) {
+	in := &Input{}
+	(&Pipeline{}).Run(context.Background(), in)
+	if in.Now.IsZero() || time.Since(in.Now) > time.Minute {
+		t.Errorf("Now = %v, want the current time", in.Now)
+	}
+}
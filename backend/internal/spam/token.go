@@ -0,0 +1,40 @@
+package spam
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IssueToken コメントフォームを表示した時刻を署名したトークンを発行する
+// 形式は "<unix秒>.<署名>"（署名は投稿IDと時刻の HMAC-SHA256）
+func IssueToken(secret []byte, postID uint, now time.Time) string {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	return ts + "." + sign(secret, postID, ts)
+}
+
+// VerifyToken トークンを検証して発行時刻を返す
+func VerifyToken(secret []byte, token string, postID uint) (time.Time, error) {
+	ts, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return time.Time{}, errors.New("malformed form token")
+	}
+	if !hmac.Equal([]byte(sig), []byte(sign(secret, postID, ts))) {
+		return time.Time{}, errors.New("invalid form token")
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.New("malformed form token")
+	}
+	return time.Unix(unix, 0), nil
+}
+
+func sign(secret []byte, postID uint, ts string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatUint(uint64(postID), 10) + ":" + ts))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
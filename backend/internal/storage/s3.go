@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3 S3 互換のオブジェクトストレージ（AWS S3、MinIO など）に保存する
+type S3 struct {
+	Client  *minio.Client
+	Bucket  string
+	BaseURL string
+}
+
+var _ Storage = (*S3)(nil)
+
+// NewS3 クライアントを作成してバケットがあることを確認する
+// BaseURL を省略した場合はエンドポイントのバケットの URL を公開 URL にする
+func NewS3(ctx context.Context, opts Options) (*S3, error) {
+	if opts.Endpoint == "" || opts.Bucket == "" {
+		return nil, errors.New("storage: S3 endpoint and bucket are required")
+	}
+
+	lookup := minio.BucketLookupAuto
+	if opts.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(opts.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(opts.AccessKeyID, opts.SecretAccessKey, ""),
+		Secure:       opts.UseSSL,
+		Region:       opts.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := client.BucketExists(ctx, opts.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("storage: failed to check bucket %q: %w", opts.Bucket, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("storage: bucket %q does not exist", opts.Bucket)
+	}
+
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		scheme := "http"
+		if opts.UseSSL {
+			scheme = "https"
+		}
+		if opts.PathStyle {
+			baseURL = scheme + "://" + opts.Endpoint + "/" + opts.Bucket
+		} else {
+			baseURL = scheme + "://" + opts.Bucket + "." + opts.Endpoint
+		}
+	}
+	return &S3{Client: client, Bucket: opts.Bucket, BaseURL: baseURL}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.PutObject(ctx, s.Bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadSeekCloser, *ObjectInfo, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	object, err := s.Client.GetObject(ctx, s.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, s.notExist(err)
+	}
+	// GetObject はリクエストを遅延するため、Stat で存在を確認する
+	stat, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, nil, s.notExist(err)
+	}
+	return object, s.info(stat), nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := s.Client.StatObject(ctx, s.Bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, s.notExist(err)
+	}
+	return s.info(stat), nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	err = s.Client.RemoveObject(ctx, s.Bucket, key, minio.RemoveObjectOptions{})
+	if err != nil && !errors.Is(s.notExist(err), ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *S3) URL(key string) string {
+	return joinURL(s.BaseURL, key)
+}
+
+func (s *S3) info(stat minio.ObjectInfo) *ObjectInfo {
+	return &ObjectInfo{
+		Key:         stat.Key,
+		Size:        stat.Size,
+		ContentType: stat.ContentType,
+		ModTime:     stat.LastModified,
+		ETag:        stat.ETag,
+	}
+}
+
+func (s *S3) notExist(err error) error {
+	resp := minio.ToErrorResponse(err)
+	if resp.Code == minio.NoSuchKey || resp.StatusCode == http.StatusNotFound {
+		return ErrNotExist
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SignKey 期限付きでキーに署名する（URL の sig パラメーターに使う）
+func SignKey(secret []byte, key string, expires time.Time) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(expires.Unix(), 10) + "/" + key))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// SignedURL 公開 URL に期限（expires、Unix 秒）と署名（sig）を付ける
+func SignedURL(rawURL string, secret []byte, key string, expires time.Time) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("sig", SignKey(secret, key, expires))
+	return rawURL + "?" + query.Encode()
+}
+
+// VerifySignedKey 署名が正しく期限内かどうか（expires は Unix 秒の文字列）
+func VerifySignedKey(secret []byte, key, expires, signature string, now time.Time) (time.Time, bool) {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || strconv.FormatInt(unix, 10) != expires {
+		return time.Time{}, false
+	}
+	expiresAt := time.Unix(unix, 0)
+	if !now.Before(expiresAt) {
+		return time.Time{}, false
+	}
+	if !hmac.Equal([]byte(signature), []byte(SignKey(secret, key, expiresAt))) {
+		return time.Time{}, false
+	}
+	return expiresAt, true
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSignedURLRoundTrip(t *testing.T) {
+	secret := []byte("secret")
+	const key = "2024/05/0123456789abcdef.jpg"
+	now := time.Unix(1700000000, 0)
+	expires := now.Add(time.Hour)
+
+	signed := SignedURL("https://api.example.com/uploads/"+key, secret, key, expires)
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("url.Parse(%q) error: %v", signed, err)
+	}
+	if u.Path != "/uploads/"+key {
+		t.Errorf("path = %q, want %q", u.Path, "/uploads/"+key)
+	}
+	query := u.Query()
+	got, ok := VerifySignedKey(secret, key, query.Get("expires"), query.Get("sig"), now)
+	if !ok {
+		t.Fatalf("VerifySignedKey(%q) = false, want true", signed)
+	}
+	if !got.Equal(expires) {
+		t.Errorf("VerifySignedKey expires = %v, want %v", got, expires)
+	}
+}
+
+func TestVerifySignedKeyRejects(t *testing.T) {
+	secret := []byte("secret")
+	const key = "2024/05/0123456789abcdef.jpg"
+	now := time.Unix(1700000000, 0)
+	expires := now.Add(time.Hour)
+	unix := strconv.FormatInt(expires.Unix(), 10)
+	sig := SignKey(secret, key, expires)
+
+	tests := []struct {
+		name    string
+		key     string
+		expires string
+		sig     string
+		now     time.Time
+	}{
+		{"at expiry", key, unix, sig, expires},
+		{"after expiry", key, unix, sig, expires.Add(time.Second)},
+		{"other key", "2024/05/fedcba9876543210.jpg", unix, sig, now},
+		{"tampered signature", key, unix, sig[:len(sig)-1] + "A", now},
+		{"truncated signature", key, unix, sig[:len(sig)-1], now},
+		{"empty signature", key, unix, "", now},
+		{"extended expires", key, strconv.FormatInt(expires.Unix()+3600, 10), sig, now},
+		{"other secret", key, unix, SignKey([]byte("other"), key, expires), now},
+		// 同じ時刻を表す別の書き方は、署名が正しくても受け付けない
+		{"plus sign", key, "+" + unix, sig, now},
+		{"leading zero", key, "0" + unix, sig, now},
+		{"leading space", key, " " + unix, sig, now},
+		{"exponent", key, "1.7000036e9", sig, now},
+		{"overflow", key, "99999999999999999999", sig, now},
+		{"empty expires", key, "", sig, now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := VerifySignedKey(secret, tt.key, tt.expires, tt.sig, tt.now); ok {
+				t.Errorf("VerifySignedKey(%q, %q, %q) = %v, true; want false", tt.key, tt.expires, tt.sig, got)
+			}
+		})
+	}
+}
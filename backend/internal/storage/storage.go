@@ -0,0 +1,93 @@
+// Package storage はアップロードされたファイルの保存先を抽象化する。
+//
+// ローカルのディレクトリ（Local）と S3 互換のオブジェクトストレージ（S3、MinIO など）の実装があり、
+// 複数のレプリカやコンテナで動かす場合は S3 を使う。
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotExist 指定したキーのファイルがない
+var ErrNotExist = errors.New("storage: object does not exist")
+
+// Storage ファイルの保存先
+// キーは "/" 区切りの相対パス（例: "2024/05/photo.jpg"）
+type Storage interface {
+	// Put ファイルを保存（同じキーがあれば上書き）
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open ファイルを開く（Range リクエストに応じられるよう Seek できる）
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, *ObjectInfo, error)
+	// Stat ファイルの情報を取得
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Delete ファイルを削除（ないキーはエラーにしない）
+	Delete(ctx context.Context, key string) error
+	// URL 公開用の URL
+	URL(key string) string
+}
+
+// ObjectInfo 保存したファイルの情報
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+	ETag        string
+}
+
+// Options New に渡す設定
+type Options struct {
+	Driver  string // local / s3
+	BaseURL string // 公開 URL の先頭（例: "/uploads"、"https://cdn.example.com/uploads"）
+
+	// local
+	Dir string
+
+	// s3
+	Endpoint        string // 例: "s3.amazonaws.com"、"minio:9000"
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	UseSSL          bool
+	PathStyle       bool // バケット名をホスト名ではなくパスに入れる（MinIO など）
+}
+
+// New 設定から保存先を作成
+func New(ctx context.Context, opts Options) (Storage, error) {
+	switch opts.Driver {
+	case "", "local":
+		return NewLocal(opts.Dir, opts.BaseURL)
+	case "s3":
+		return NewS3(ctx, opts)
+	}
+	return nil, fmt.Errorf("storage: unknown driver %q", opts.Driver)
+}
+
+// CleanKey キーを正規化し、保存先の外を指すキーを拒否する
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.ContainsRune(key, '\\') || strings.ContainsRune(key, 0) {
+		return "", fmt.Errorf("storage: invalid key %q", key)
+	}
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != strings.TrimPrefix(key, "/") {
+		return "", fmt.Errorf("storage: invalid key %q", key)
+	}
+	return cleaned, nil
+}
+
+// joinURL 公開 URL の先頭とキーをつなぐ
+func joinURL(baseURL, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.TrimRight(baseURL, "/") + "/" + strings.Join(segments, "/")
+}
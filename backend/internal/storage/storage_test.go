@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStorage Storage の実装が共通で満たすべき動作を確認する
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+	const key = "2024/05/hello world.txt"
+	content := []byte("hello, storage conformance")
+
+	put := func(key string, data []byte) {
+		t.Helper()
+		if err := s.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "text/plain"); err != nil {
+			t.Fatalf("Put(%q) error: %v", key, err)
+		}
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		if _, err := s.Stat(ctx, key); !errors.Is(err, ErrNotExist) {
+			t.Errorf("Stat(missing) error = %v, want ErrNotExist", err)
+		}
+		if _, _, err := s.Open(ctx, key); !errors.Is(err, ErrNotExist) {
+			t.Errorf("Open(missing) error = %v, want ErrNotExist", err)
+		}
+		if err := s.Delete(ctx, key); err != nil {
+			t.Errorf("Delete(missing) error = %v, want nil", err)
+		}
+	})
+
+	t.Run("put and stat", func(t *testing.T) {
+		put(key, content)
+		info, err := s.Stat(ctx, key)
+		if err != nil {
+			t.Fatalf("Stat error: %v", err)
+		}
+		if info.Key != key || info.Size != int64(len(content)) {
+			t.Errorf("Stat = %+v, want key %q and size %d", info, key, len(content))
+		}
+		if info.ETag == "" || info.ModTime.IsZero() {
+			t.Errorf("Stat = %+v, want ETag and ModTime", info)
+		}
+	})
+
+	t.Run("open and seek", func(t *testing.T) {
+		r, info, err := s.Open(ctx, key)
+		if err != nil {
+			t.Fatalf("Open error: %v", err)
+		}
+		defer r.Close()
+		if info.Size != int64(len(content)) {
+			t.Errorf("Open size = %d, want %d", info.Size, len(content))
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, content) {
+			t.Fatalf("read = %q, %v; want %q", got, err, content)
+		}
+
+		// Range リクエストのために途中から読めること
+		if _, err := r.Seek(7, io.SeekStart); err != nil {
+			t.Fatalf("Seek error: %v", err)
+		}
+		got, err = io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, content[7:]) {
+			t.Errorf("read after Seek = %q, %v; want %q", got, err, content[7:])
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		replaced := []byte("replaced")
+		put(key, replaced)
+		r, _, err := s.Open(ctx, key)
+		if err != nil {
+			t.Fatalf("Open error: %v", err)
+		}
+		defer r.Close()
+		if got, _ := io.ReadAll(r); !bytes.Equal(got, replaced) {
+			t.Errorf("read = %q, want %q", got, replaced)
+		}
+	})
+
+	t.Run("url", func(t *testing.T) {
+		if got := s.URL(key); !strings.HasSuffix(got, "/2024/05/hello%20world.txt") {
+			t.Errorf("URL(%q) = %q, want the escaped key at the end", key, got)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := s.Delete(ctx, key); err != nil {
+			t.Fatalf("Delete error: %v", err)
+		}
+		if _, err := s.Stat(ctx, key); !errors.Is(err, ErrNotExist) {
+			t.Errorf("Stat after Delete error = %v, want ErrNotExist", err)
+		}
+		if err := s.Delete(ctx, key); err != nil {
+			t.Errorf("second Delete error = %v, want nil", err)
+		}
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		for _, bad := range []string{"", "/", "../escape.txt", "a/../../escape.txt", "a//b.txt", `a\b.txt`} {
+			if err := s.Put(ctx, bad, strings.NewReader("x"), 1, "text/plain"); err == nil {
+				t.Errorf("Put(%q) should fail", bad)
+			}
+			if _, err := s.Stat(ctx, bad); err == nil {
+				t.Errorf("Stat(%q) should fail", bad)
+			}
+			if _, _, err := s.Open(ctx, bad); err == nil {
+				t.Errorf("Open(%q) should fail", bad)
+			}
+			if err := s.Delete(ctx, bad); err == nil {
+				t.Errorf("Delete(%q) should fail", bad)
+			}
+		}
+	})
+}
+
+func TestLocal(t *testing.T) {
+	s, err := NewLocal(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, s)
+
+	if got := s.URL("a.txt"); got != "/uploads/a.txt" {
+		t.Errorf("default URL = %q, want /uploads/a.txt", got)
+	}
+	if err := s.Put(context.Background(), "short.txt", strings.NewReader("abc"), 10, "text/plain"); err == nil {
+		t.Error("Put with a wrong size should fail")
+	}
+	if _, err := s.Stat(context.Background(), "short.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("failed Put left a file behind: %v", err)
+	}
+}
+
+func TestS3(t *testing.T) {
+	fake := newFakeS3("uploads")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	opts := Options{
+		Driver:          "s3",
+		Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+		Region:          "us-east-1",
+		Bucket:          "uploads",
+		AccessKeyID:     "access",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	}
+	s, err := New(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, s)
+
+	if got, want := s.URL("a.txt"), server.URL+"/uploads/a.txt"; got != want {
+		t.Errorf("default URL = %q, want %q", got, want)
+	}
+
+	opts.Bucket = "missing"
+	if _, err := New(context.Background(), opts); err == nil {
+		t.Error("New with a missing bucket should fail")
+	}
+}
+
+func TestCleanKey(t *testing.T) {
+	tests := []struct {
+		key, want string
+		ok        bool
+	}{
+		{"a.txt", "a.txt", true},
+		{"2024/05/a.txt", "2024/05/a.txt", true},
+		{"/2024/a.txt", "2024/a.txt", true},
+		{"", "", false},
+		{"/", "", false},
+		{"..", "", false},
+		{"../a.txt", "", false},
+		{"a/../b.txt", "", false},
+		{"a/./b.txt", "", false},
+		{"a//b.txt", "", false},
+		{"a/", "", false},
+		{`a\b.txt`, "", false},
+		{"a\x00.txt", "", false},
+	}
+	for _, tt := range tests {
+		got, err := CleanKey(tt.key)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("CleanKey(%q) = %q, %v; want %q, ok=%v", tt.key, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+// fakeS3 テスト用の S3 互換サーバー（パス形式のバケット1つ、PUT / GET / HEAD / DELETE のみ）
+type fakeS3 struct {
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+func newFakeS3(bucket string) *fakeS3 {
+	return &fakeS3{bucket: bucket, objects: map[string]fakeObject{}}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	if key == "" {
+		// BucketExists（HEAD）とバケットの位置の問い合わせ
+		if r.URL.Query().Has("location") {
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><LocationConstraint>us-east-1</LocationConstraint>`)
+		}
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := readS3Body(r)
+		if err != nil {
+			writeS3Error(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		f.objects[key] = fakeObject{
+			data:        data,
+			contentType: r.Header.Get("Content-Type"),
+			modTime:     time.Now().UTC().Truncate(time.Second),
+		}
+		w.Header().Set("ETag", etag(data))
+	case http.MethodGet, http.MethodHead:
+		object, ok := f.objects[key]
+		if !ok {
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("ETag", etag(object.data))
+		http.ServeContent(w, r, key, object.modTime, bytes.NewReader(object.data))
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeS3Error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+	}
+}
+
+// readS3Body PUT の本文を読む（HTTP では署名付きの aws-chunked 形式で送られる）
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") &&
+		!strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") {
+		return io.ReadAll(r.Body)
+	}
+
+	// "<16進の長さ>;chunk-signature=...\r\n<データ>\r\n" の繰り返しで、長さ 0 のチャンク（とトレーラー）で終わる
+	br := bufio.NewReader(r.Body)
+	var out bytes.Buffer
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		n, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return out.Bytes(), nil
+		}
+		if _, err := io.CopyN(&out, br, n); err != nil {
+			return nil, err
+		}
+		if _, err := br.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
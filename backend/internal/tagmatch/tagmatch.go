@@ -0,0 +1,254 @@
+// Package tagmatch はタグ名の入力補完と、投稿本文からの既存タグの提案を行う。
+//
+// 比較はすべて models.NormalizeTagName で正規化した文字列で行う。英数字のタグ名は単語の境界で、
+// 日本語などの CJK を含むタグ名は部分文字列として本文に現れる回数を数える。
+package tagmatch
+
+import (
+	"blogapp/models"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Candidate 照合対象のタグ
+type Candidate struct {
+	ID       uint
+	Name     string
+	Synonyms []string
+	Usage    int64 // タグが付いている投稿数
+}
+
+// マッチの種類（補完の順位はこの順）
+const (
+	MatchExact    = "exact"
+	MatchPrefix   = "prefix"
+	MatchContains = "contains"
+	MatchFuzzy    = "fuzzy"
+)
+
+// Match 入力補完の候補
+type Match struct {
+	Candidate
+	Kind    string // MatchExact など
+	Matched string // 一致した名前（別名で一致した場合は別名）
+}
+
+// Suggestion 本文から提案するタグ
+type Suggestion struct {
+	Candidate
+	Score   float64
+	Matched []string // 本文に現れた名前・別名
+}
+
+// 本文より重視するタイトル中の出現の重み
+const titleWeight = 3
+
+// Complete 入力中の文字列に一致するタグを、一致の種類 → 使用数 → 名前の順に並べて返す
+// 前方一致・部分一致がない短い誤字はレーベンシュタイン距離で拾う
+func Complete(query string, candidates []Candidate, limit int) []Match {
+	q := models.NormalizeTagName(query)
+	if q == "" {
+		return nil
+	}
+
+	var matches []Match
+	for _, candidate := range candidates {
+		best := Match{}
+		rank := len(kindRank)
+		for _, name := range names(candidate) {
+			kind := matchKind(q, models.NormalizeTagName(name))
+			if kind == "" {
+				continue
+			}
+			if r := kindRank[kind]; r < rank {
+				rank = r
+				best = Match{Candidate: candidate, Kind: kind, Matched: name}
+			}
+		}
+		if best.Kind != "" {
+			matches = append(matches, best)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if kindRank[a.Kind] != kindRank[b.Kind] {
+			return kindRank[a.Kind] < kindRank[b.Kind]
+		}
+		if a.Usage != b.Usage {
+			return a.Usage > b.Usage
+		}
+		return a.Name < b.Name
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+var kindRank = map[string]int{
+	MatchExact:    0,
+	MatchPrefix:   1,
+	MatchContains: 2,
+	MatchFuzzy:    3,
+}
+
+func matchKind(query, name string) string {
+	switch {
+	case name == query:
+		return MatchExact
+	case strings.HasPrefix(name, query):
+		return MatchPrefix
+	case strings.Contains(name, query):
+		return MatchContains
+	}
+
+	// 誤字として許容する距離は入力の長さに応じて（3文字以下は補完しない）
+	n := len([]rune(query))
+	maxDistance := 0
+	switch {
+	case n >= 8:
+		maxDistance = 2
+	case n >= 4:
+		maxDistance = 1
+	}
+	if maxDistance == 0 {
+		return ""
+	}
+	// 入力途中の名前とも比べられるよう、名前の先頭を入力と同じ長さで切り出して比べる
+	prefix := []rune(name)
+	if len(prefix) > n {
+		prefix = prefix[:n]
+	}
+	if levenshtein([]rune(query), prefix) <= maxDistance {
+		return MatchFuzzy
+	}
+	return ""
+}
+
+// Suggest タイトルと本文に名前・別名が現れる既存タグをスコア順に返す
+// exclude のタグ（既に付いているものなど）は除く
+func Suggest(title, content string, candidates []Candidate, exclude map[uint]bool, limit int) []Suggestion {
+	normTitle := models.NormalizeTagName(title)
+	normContent := models.NormalizeTagName(content)
+
+	var suggestions []Suggestion
+	for _, candidate := range candidates {
+		if exclude[candidate.ID] {
+			continue
+		}
+
+		var score float64
+		var matched []string
+		seen := map[string]bool{}
+		for _, name := range names(candidate) {
+			key := models.NormalizeTagName(name)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			count := titleWeight*countTerm(normTitle, key) + countTerm(normContent, key)
+			if count == 0 {
+				continue
+			}
+			score += float64(count)
+			matched = append(matched, name)
+		}
+		if score == 0 {
+			continue
+		}
+		suggestions = append(suggestions, Suggestion{Candidate: candidate, Score: score, Matched: matched})
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Usage != b.Usage {
+			return a.Usage > b.Usage
+		}
+		return a.Name < b.Name
+	})
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// countTerm text 中の term の出現回数
+// CJK を含む語は部分文字列として、それ以外は前後が英数字でない位置のみ数える（"go" が "google" に一致しないように）
+func countTerm(text, term string) int {
+	if containsCJK(term) {
+		return strings.Count(text, term)
+	}
+
+	count := 0
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], term)
+		if i < 0 {
+			return count
+		}
+		start := offset + i
+		end := start + len(term)
+		if isBoundary(text, start, true) && isBoundary(text, end, false) {
+			count++
+		}
+		offset = start + 1
+	}
+}
+
+// isBoundary text の pos の直前（before）または直後が単語の区切りか
+func isBoundary(text string, pos int, before bool) bool {
+	var r rune
+	switch {
+	case before && pos == 0, !before && pos >= len(text):
+		return true
+	case before:
+		r, _ = utf8.DecodeLastRuneInString(text[:pos])
+	default:
+		r, _ = utf8.DecodeRuneInString(text[pos:])
+	}
+	return isCJK(r) || !(unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func containsCJK(s string) bool {
+	for _, r := range s {
+		if isCJK(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+func names(candidate Candidate) []string {
+	return append([]string{candidate.Name}, candidate.Synonyms...)
+}
+
+// levenshtein 2つの文字列（rune 単位）の編集距離
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
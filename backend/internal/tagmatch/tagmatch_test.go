@@ -0,0 +1,152 @@
+package tagmatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+var candidates = []Candidate{
+	{ID: 1, Name: "Go", Usage: 10},
+	{ID: 2, Name: "Golang", Synonyms: []string{"go-lang"}, Usage: 5},
+	{ID: 3, Name: "Google", Usage: 20},
+	{ID: 4, Name: "JavaScript", Synonyms: []string{"JS"}, Usage: 8},
+	{ID: 5, Name: "TypeScript", Usage: 3},
+	{ID: 6, Name: "Kubernetes", Synonyms: []string{"k8s"}, Usage: 4},
+	{ID: 7, Name: "東京", Usage: 2},
+}
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []string // 名前:種類:一致した名前
+	}{
+		{"empty", "  ", 0, nil},
+		{"exact before prefix, then by usage", "go", 0, []string{"Go:exact:Go", "Google:prefix:Google", "Golang:prefix:Golang"}},
+		{"normalized query", " ＧＯ ", 0, []string{"Go:exact:Go", "Google:prefix:Google", "Golang:prefix:Golang"}},
+		{"limit", "go", 2, []string{"Go:exact:Go", "Google:prefix:Google"}},
+		{"synonym", "js", 0, []string{"JavaScript:exact:JS"}},
+		{"best kind of the names", "go-l", 0, []string{"Golang:prefix:go-lang"}},
+		{"contains", "script", 0, []string{"JavaScript:contains:JavaScript", "TypeScript:contains:TypeScript"}},
+		{"fuzzy", "kubernets", 0, []string{"Kubernetes:fuzzy:Kubernetes"}},
+		{"cjk", "東", 0, []string{"東京:prefix:東京"}},
+		{"no match", "rust", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, m := range Complete(tt.query, candidates, tt.limit) {
+				got = append(got, m.Name+":"+m.Kind+":"+m.Matched)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Complete(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+// 誤字として許容する距離は入力が 4〜7 文字なら 1、8 文字以上なら 2（3 文字以下は補完しない）
+func TestMatchKindFuzzyThreshold(t *testing.T) {
+	tests := []struct {
+		query, name string
+		want        string
+	}{
+		{"gox", "go", ""},
+		{"gp", "go", ""},
+		{"abcd", "abxd", MatchFuzzy},
+		{"abcd", "axyd", ""},
+		{"pythn", "python", MatchFuzzy},
+		{"pyhtn", "python", ""},
+		{"kubrnetz", "kubernetes", MatchFuzzy},
+		{"kbrnetzz", "kubernetes", ""},
+		{"pyth", "python", MatchPrefix},
+		{"ytho", "python", MatchContains},
+	}
+	for _, tt := range tests {
+		if got := matchKind(tt.query, tt.name); got != tt.want {
+			t.Errorf("matchKind(%q, %q) = %q, want %q", tt.query, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	const (
+		title   = "Kubernetes入門"
+		content = "Go と google の話。k8s を使う。東京タワーと東京駅"
+	)
+	tests := []struct {
+		name    string
+		exclude map[uint]bool
+		limit   int
+		want    []string
+	}{
+		// タイトル中の出現は 3 倍、同じスコアなら使用数の多い順
+		{"all", nil, 0, []string{"Kubernetes", "東京", "Google", "Go"}},
+		{"exclude", map[uint]bool{3: true}, 0, []string{"Kubernetes", "東京", "Go"}},
+		{"limit", nil, 2, []string{"Kubernetes", "東京"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, s := range Suggest(title, content, candidates, tt.exclude, tt.limit) {
+				got = append(got, s.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Suggest = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	suggestions := Suggest(title, content, candidates, nil, 1)
+	if s := suggestions[0]; s.Score != 4 || !reflect.DeepEqual(s.Matched, []string{"Kubernetes", "k8s"}) {
+		t.Errorf("Suggest()[0] = score %v matched %q, want 4 [Kubernetes k8s]", s.Score, s.Matched)
+	}
+}
+
+func TestCountTerm(t *testing.T) {
+	tests := []struct {
+		text, term string
+		want       int
+	}{
+		{"", "go", 0},
+		{"go to google", "go", 1},
+		{"golang go", "go", 1},
+		{"gogo", "go", 0},
+		{"ago", "go", 0},
+		{"go2 go", "go", 1},
+		{"go-lang", "go", 1},
+		{"(go), go.", "go", 2},
+		// CJK の前後も区切りとする
+		{"goと", "go", 1},
+		{"はgoで", "go", 1},
+		// CJK を含む語は部分文字列として数える
+		{"東京タワー東京", "東京", 2},
+		{"北東京", "東京", 1},
+		{"c++ と c", "c++", 1},
+	}
+	for _, tt := range tests {
+		if got := countTerm(tt.text, tt.term); got != tt.want {
+			t.Errorf("countTerm(%q, %q) = %d, want %d", tt.text, tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"same", "same", 0},
+		{"kitten", "sitting", 3},
+		{"東京", "東都", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package upload
+
+import (
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+
+	_ "golang.org/x/image/webp"
+)
+
+// dimensions ラスター画像の幅と高さ（画像でなければ 0）
+func dimensions(r io.Reader, mimeType string) (width, height int) {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+	default:
+		return 0, 0
+	}
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, 0
+	}
+	return config.Width, config.Height
+}
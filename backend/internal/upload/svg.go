@@ -0,0 +1,78 @@
+package upload
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SVG の中で使えない要素（スクリプトの実行や外部のコンテンツの読み込み）
+var unsafeSVGElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"embed":         true,
+	"object":        true,
+	"handler":       true,
+	"listener":      true,
+}
+
+// checkSVG SVG を XML として読み、スクリプト・イベントハンドラー・外部参照を含むものを拒否する
+func checkSVG(r io.Reader) error {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = true
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrUnsafeSVG, err)
+		}
+
+		switch t := token.(type) {
+		case xml.Directive:
+			// DOCTYPE の実体宣言（外部実体や実体の展開による攻撃）
+			return fmt.Errorf("%w (DOCTYPE)", ErrUnsafeSVG)
+		case xml.ProcInst:
+			if t.Target != "xml" {
+				return fmt.Errorf("%w (processing instruction %s)", ErrUnsafeSVG, t.Target)
+			}
+		case xml.StartElement:
+			if unsafeSVGElements[strings.ToLower(t.Name.Local)] {
+				return fmt.Errorf("%w (<%s>)", ErrUnsafeSVG, t.Name.Local)
+			}
+			for _, attr := range t.Attr {
+				if err := checkSVGAttr(attr); err != nil {
+					return err
+				}
+			}
+		case xml.CharData:
+			// <style> の中の url(javascript:...) や expression() など
+			if text := strings.ToLower(string(t)); strings.Contains(text, "javascript:") || strings.Contains(text, "@import") {
+				return fmt.Errorf("%w (style)", ErrUnsafeSVG)
+			}
+		}
+	}
+}
+
+func checkSVGAttr(attr xml.Attr) error {
+	name := strings.ToLower(attr.Name.Local)
+	value := strings.ToLower(strings.Join(strings.Fields(attr.Value), ""))
+
+	if strings.HasPrefix(name, "on") {
+		return fmt.Errorf("%w (%s attribute)", ErrUnsafeSVG, attr.Name.Local)
+	}
+	if strings.Contains(value, "javascript:") || strings.Contains(value, "vbscript:") {
+		return fmt.Errorf("%w (%s attribute)", ErrUnsafeSVG, attr.Name.Local)
+	}
+	// 参照はファイル内（#id）と埋め込みのラスター画像だけを許す
+	if name == "href" && value != "" && !strings.HasPrefix(value, "#") &&
+		(!strings.HasPrefix(value, "data:image/") || strings.HasPrefix(value, "data:image/svg")) {
+		return fmt.Errorf("%w (external reference %q)", ErrUnsafeSVG, attr.Value)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+// Package upload はアップロードされたファイルを保存する前に検査する。
+//
+// ファイル名の拡張子ではなく中身から種類を判定し、拡張子との食い違い、
+// 画像や PDF の先頭・末尾に HTML・スクリプト・ZIP を埋め込んだポリグロット、スクリプトを含む SVG を拒否する。
+// 保存するファイル名には中身の SHA-256 を使う。
+package upload
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// 検査で拒否した理由
+var (
+	ErrTooLarge          = errors.New("file is too large")
+	ErrTypeNotAllowed    = errors.New("file type is not allowed")
+	ErrExtensionMismatch = errors.New("file extension does not match its content")
+	ErrPolyglot          = errors.New("file contains embedded active content")
+	ErrUnsafeSVG         = errors.New("SVG contains scripts or external references")
+)
+
+// Types 受け付けられるファイルの種類と拡張子（先頭が保存時の拡張子）
+var Types = map[string][]string{
+	"image/jpeg":      {".jpg", ".jpeg"},
+	"image/png":       {".png"},
+	"image/gif":       {".gif"},
+	"image/webp":      {".webp"},
+	"image/svg+xml":   {".svg"},
+	"application/pdf": {".pdf"},
+	"video/mp4":       {".mp4"},
+	"video/webm":      {".webm"},
+}
+
+// Policy ロールごとのアップロードの制限
+type Policy struct {
+	AllowedTypes []string // Types のキー
+	MaxSize      int64    // バイト数
+}
+
+// Allows 種類を受け付けるかどうか
+func (p Policy) Allows(mimeType string) bool {
+	for _, allowed := range p.AllowedTypes {
+		if allowed == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// Result 検査を通ったファイルの情報
+type Result struct {
+	MIME string // 中身から判定した種類
+	Ext  string // 保存時の拡張子
+	Hash string // 中身の SHA-256（16進）
+	Size int64
+
+	Width, Height int // ラスター画像のみ
+}
+
+// Name アップロードしたユーザー（owner）と中身のハッシュから作った保存用のファイル名
+// 同じユーザーの同じ中身なら同じ名前になり、別のユーザーのファイルとは共有しない
+func (r *Result) Name(owner uint) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%d:%s", owner, r.Hash))
+	return hex.EncodeToString(sum[:16]) + r.Ext
+}
+
+// Inspect ファイルを検査する（読み終えたあと先頭に戻す）
+// filename はクライアントが送ったファイル名で、拡張子の確認にだけ使う
+func Inspect(f io.ReadSeeker, filename string, policy Policy) (*Result, error) {
+	head := make([]byte, 3072)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	head = head[:n]
+
+	mimeType := detect(head)
+	if mimeType == "" || !policy.Allows(mimeType) {
+		return nil, ErrTypeNotAllowed
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !contains(Types[mimeType], ext) {
+		return nil, ErrExtensionMismatch
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	scanner := newMarkerScanner(mimeType)
+	size, err := io.Copy(io.MultiWriter(hash, scanner), io.LimitReader(f, policy.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > policy.MaxSize {
+		return nil, ErrTooLarge
+	}
+	if mimeType != "image/svg+xml" {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if mimeType == "image/svg+xml" {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if err := checkSVG(f); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	width, height := dimensions(f, mimeType)
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return &Result{
+		MIME:   mimeType,
+		Ext:    Types[mimeType][0],
+		Hash:   hex.EncodeToString(hash.Sum(nil)),
+		Size:   size,
+		Width:  width,
+		Height: height,
+	}, nil
+}
+
+// detect 先頭のバイト列から Types のいずれかの種類を判定（該当しなければ空文字）
+func detect(head []byte) string {
+	detected := mimetype.Detect(head)
+	for mimeType := range Types {
+		if detected.Is(mimeType) {
+			return mimeType
+		}
+	}
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// markerScanner 埋め込まれたコンテンツの目印を探す io.Writer
+// ブラウザが種類を推測するときに読む先頭と、ZIP などを付け足せる末尾だけを調べる
+// （圧縮された画像・動画の本体はランダムなバイト列なので、全体を調べると短い目印が偶然一致する）
+type markerScanner struct {
+	head   []byte // 先頭 headWindow バイト
+	window []byte // 末尾 tailWindow バイト
+
+	// PDF のアクションはファイルのどこにでも置けるため、PDF だけは全体を調べる
+	pdf   bool
+	tail  []byte // チャンクの境目にまたがる目印のために前のチャンクの末尾を残す
+	found string
+}
+
+// 画像・PDF・動画の先頭・末尾に含まれていればブラウザが HTML やスクリプトとして解釈しうる目印
+// 偶然一致しないよう、短い目印（"<svg" など）は使わない
+var htmlMarkers = []string{"<!doctype html", "<script", "<iframe", "<?php"}
+
+// PDF の中で実行されるアクションと埋め込みファイル
+var pdfMarkers = []string{"/javascript", "/launch", "/embeddedfile"}
+
+const (
+	headWindow = 2048 // ブラウザが種類の推測に使う範囲（最大 1445 バイト）より広く
+	// 末尾の目印を探す範囲
+	tailMarkerWindow = 2048
+	// ZIP の終端レコード（22 バイト + コメント最大 64KB）を置ける範囲
+	tailWindow = 65536 + 22
+)
+
+func newMarkerScanner(mimeType string) *markerScanner {
+	return &markerScanner{pdf: mimeType == "application/pdf"}
+}
+
+func (s *markerScanner) Write(p []byte) (int, error) {
+	if len(s.head) < headWindow {
+		n := min(headWindow-len(s.head), len(p))
+		s.head = append(s.head, p[:n]...)
+	}
+
+	s.window = append(s.window, p...)
+	if len(s.window) > tailWindow {
+		s.window = append(s.window[:0], s.window[len(s.window)-tailWindow:]...)
+	}
+
+	if s.pdf && s.found == "" {
+		chunk := asciiLower(append(s.tail, p...))
+		s.found = findMarker(chunk, pdfMarkers)
+		keep := min(16, len(chunk))
+		s.tail = append(s.tail[:0], chunk[len(chunk)-keep:]...)
+	}
+	return len(p), nil
+}
+
+// Err 目印が見つかっていれば ErrPolyglot を返す（SVG は XML として checkSVG で検査するので呼ばない）
+func (s *markerScanner) Err() error {
+	if s.found != "" {
+		return fmt.Errorf("%w (%q)", ErrPolyglot, s.found)
+	}
+	if marker := findMarker(asciiLower(bytes.Clone(s.head)), htmlMarkers); marker != "" {
+		return fmt.Errorf("%w (%q)", ErrPolyglot, marker)
+	}
+	trailer := s.window[max(0, len(s.window)-tailMarkerWindow):]
+	if marker := findMarker(asciiLower(bytes.Clone(trailer)), htmlMarkers); marker != "" {
+		return fmt.Errorf("%w (%q)", ErrPolyglot, marker)
+	}
+	if hasZipEnd(s.window) {
+		return fmt.Errorf("%w (zip archive)", ErrPolyglot)
+	}
+	return nil
+}
+
+func findMarker(b []byte, markers []string) string {
+	for _, marker := range markers {
+		if bytes.Contains(b, []byte(marker)) {
+			return marker
+		}
+	}
+	return ""
+}
+
+// hasZipEnd 末尾が ZIP の終端レコードかどうか
+// 署名の後ろのコメント長がファイルの終わりまでの長さと一致するものだけを数える
+func hasZipEnd(tail []byte) bool {
+	signature := []byte("PK\x05\x06")
+	for i := bytes.LastIndex(tail, signature); i >= 0; i = bytes.LastIndex(tail[:i], signature) {
+		if i+22 > len(tail) {
+			continue
+		}
+		commentLen := int(tail[i+20]) | int(tail[i+21])<<8
+		if i+22+commentLen == len(tail) {
+			return true
+		}
+	}
+	return false
+}
+
+// asciiLower ASCII の英字だけを小文字にする（バイナリをそのまま扱うため UTF-8 として解釈しない）
+func asciiLower(b []byte) []byte {
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + ('a' - 'A')
+		}
+	}
+	return b
+}
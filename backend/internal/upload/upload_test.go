@@ -0,0 +1,172 @@
+package upload
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+// 先頭の数バイトだけで種類が決まるファイルのヘッダー
+var magicHeaders = []struct {
+	mime, filename string
+	header         []byte
+}{
+	{"image/png", "a.png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")},
+	{"image/jpeg", "a.jpg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00")},
+	{"image/gif", "a.gif", []byte("GIF89a")},
+	{"video/mp4", "a.mp4", []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom")},
+	{"video/webm", "a.webm", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\xf7\x81\x01\x42\xf2\x81\x04\x42\xf3\x81\x08\x42\x82\x84webm\x42\x87\x81\x04\x42\x85\x81\x02")},
+	{"application/pdf", "a.pdf", []byte("%PDF-1.7\n")},
+}
+
+func allowAll(maxSize int64) Policy {
+	policy := Policy{MaxSize: maxSize}
+	for mimeType := range Types {
+		policy.AllowedTypes = append(policy.AllowedTypes, mimeType)
+	}
+	return policy
+}
+
+func randomBytes(seed uint64, n int) []byte {
+	r := rand.New(rand.NewPCG(seed, seed))
+	b := make([]byte, n)
+	for i := 0; i+8 <= n; i += 8 {
+		v := r.Uint64()
+		for j := 0; j < 8; j++ {
+			b[i+j] = byte(v >> (8 * j))
+		}
+	}
+	return b
+}
+
+// 圧縮された画像・動画の本体はランダムなバイト列と同じなので、偶然の一致で拒否しないこと
+func TestInspectAcceptsRandomPayload(t *testing.T) {
+	const size = 16 << 20
+	for i, tt := range magicHeaders {
+		t.Run(tt.mime, func(t *testing.T) {
+			data := append(append([]byte(nil), tt.header...), randomBytes(uint64(i+1), size)...)
+			result, err := Inspect(bytes.NewReader(data), tt.filename, allowAll(int64(len(data))))
+			if err != nil {
+				t.Fatalf("Inspect error: %v", err)
+			}
+			if result.MIME != tt.mime || result.Size != int64(len(data)) {
+				t.Errorf("Inspect = %+v, want %s of %d bytes", result, tt.mime, len(data))
+			}
+		})
+	}
+}
+
+func TestInspectRejectsPolyglots(t *testing.T) {
+	payload := randomBytes(42, 1<<20)
+
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	entry, _ := w.Create("evil.html")
+	entry.Write([]byte("<html></html>"))
+	w.SetComment("comment")
+	w.Close()
+
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	png := magicHeaders[0].header
+
+	tests := []struct {
+		name, filename string
+		data           []byte
+	}{
+		{"script in header", "a.png", join(png, []byte("<SCRIPT>alert(1)</script>"), payload)},
+		{"doctype in header", "a.png", join(png, []byte("<!DOCTYPE html><p>"), payload)},
+		{"php in trailer", "a.png", join(png, payload, []byte("<?php system($_GET['c']); ?>"))},
+		{"iframe in trailer", "a.mp4", join(magicHeaders[3].header, payload, []byte("<iframe src=//x>"))},
+		{"zip appended", "a.png", join(png, payload, archive.Bytes())},
+		{"pdf javascript in body", "a.pdf", join([]byte("%PDF-1.7\n"), payload, []byte("<< /S /JavaScript /JS (app.alert(1)) >>"), payload)},
+		{"pdf launch across chunks", "a.pdf", join([]byte("%PDF-1.7\n"), payload[:32*1024-3-9], []byte("/Launch"), payload)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Inspect(bytes.NewReader(tt.data), tt.filename, allowAll(int64(len(tt.data))))
+			if !errors.Is(err, ErrPolyglot) {
+				t.Errorf("Inspect error = %v, want ErrPolyglot", err)
+			}
+		})
+	}
+}
+
+func TestHasZipEnd(t *testing.T) {
+	record := func(commentLen int) []byte {
+		r := make([]byte, 22)
+		copy(r, "PK\x05\x06")
+		r[20], r[21] = byte(commentLen), byte(commentLen>>8)
+		return r
+	}
+
+	tests := []struct {
+		name string
+		tail []byte
+		want bool
+	}{
+		{"record at end", append([]byte("data"), record(0)...), true},
+		{"record with comment", append(append([]byte("data"), record(3)...), "abc"...), true},
+		{"signature in the middle", append(append([]byte("data"), record(0)...), "more data"...), false},
+		{"comment length mismatch", append(append([]byte("data"), record(10)...), "abc"...), false},
+		{"truncated record", []byte("dataPK\x05\x06\x00\x00"), false},
+		{"no signature", []byte("data"), false},
+	}
+	for _, tt := range tests {
+		if got := hasZipEnd(tt.tail); got != tt.want {
+			t.Errorf("%s: hasZipEnd = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInspectPolicy(t *testing.T) {
+	var buf bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, color.White)
+	png.Encode(&buf, img)
+	data := buf.Bytes()
+
+	result, err := Inspect(bytes.NewReader(data), "photo.PNG", allowAll(1<<20))
+	if err != nil {
+		t.Fatalf("Inspect error: %v", err)
+	}
+	if result.Width != 3 || result.Height != 2 || result.Ext != ".png" || len(result.Hash) != 64 {
+		t.Errorf("Inspect = %+v", result)
+	}
+	name := result.Name(1)
+	if len(name) != 36 || !strings.HasSuffix(name, ".png") || name != result.Name(1) {
+		t.Errorf("Name(1) = %q, want 32 hex characters and the extension", name)
+	}
+	if other := result.Name(2); other == name {
+		t.Errorf("Name(2) = %q, want a name different from another uploader", other)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		filename string
+		policy   Policy
+		want     error
+	}{
+		{"too large", data, "a.png", allowAll(int64(len(data) - 1)), ErrTooLarge},
+		{"type not allowed", data, "a.png", Policy{AllowedTypes: []string{"image/jpeg"}, MaxSize: 1 << 20}, ErrTypeNotAllowed},
+		{"unknown type", []byte("plain text"), "a.png", allowAll(1 << 20), ErrTypeNotAllowed},
+		{"html", []byte("<!DOCTYPE html><html></html>"), "a.png", allowAll(1 << 20), ErrTypeNotAllowed},
+		{"extension mismatch", data, "a.jpg", allowAll(1 << 20), ErrExtensionMismatch},
+		{"no extension", data, "png", allowAll(1 << 20), ErrExtensionMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Inspect(bytes.NewReader(tt.data), tt.filename, tt.policy); !errors.Is(err, tt.want) {
+				t.Errorf("Inspect error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,21 @@
 package middleware
 
 import (
+	"blogapp/utils"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// tokens トークンの検証に使う署名鍵（SetJWTSecret でセットするまではすべて拒否する）
+var tokens *utils.JWT
+
+// SetJWTSecret トークンの署名鍵をセット（サーバー起動時に呼ぶ）
+func SetJWTSecret(secret string) {
+	tokens = utils.NewJWT(secret)
+}
+
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -18,33 +27,50 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Bearer トークンの形式をチェック
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
+		if msg, ok := authenticate(c, authHeader); !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid authorization header format",
+				"error": msg,
 			})
 			c.Abort()
 			return
 		}
 
-		token := parts[1]
+		c.Next()
+	}
+}
 
-		// TODO: JWTトークンの検証を実装
-		// 現在はダミーの実装
-		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid token",
-			})
-			c.Abort()
-			return
+// OptionalAuth 有効なトークンがあればユーザー情報をセットし、なくても通過させる
+// 公開エンドポイントで投稿者・編集者向けの情報（下書きなど）を出し分けるために使用
+// 不正・期限切れのトークンは未認証として扱う
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			authenticate(c, authHeader)
 		}
+		c.Next()
+	}
+}
 
-		// トークンが有効な場合、ユーザー情報をコンテキストにセット
-		c.Set("user_id", "1")
-		c.Set("user_email", "test@example.com")
-		c.Set("user_role", "admin") // TODO: トークンから実際のロールを取得
+// authenticate Bearer トークンを検証し、有効ならユーザー情報をコンテキストにセット
+func authenticate(c *gin.Context, authHeader string) (string, bool) {
+	// Bearer トークンの形式をチェック
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" || parts[1] == "" {
+		return "Invalid authorization header format", false
+	}
 
-		c.Next()
+	if tokens == nil {
+		return "Invalid token", false
+	}
+	claims, err := tokens.ValidateToken(parts[1])
+	if err != nil || claims.UserID == "" || claims.Role == "" {
+		return "Invalid token", false
 	}
+
+	// トークンが有効な場合、ユーザー情報をコンテキストにセット
+	c.Set("user_id", claims.UserID)
+	c.Set("user_email", claims.Email)
+	c.Set("user_role", claims.Role)
+
+	return "", true
 }
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"blogapp/models"
+	"blogapp/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestRouter(auth gin.HandlerFunc, roles ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handlers := []gin.HandlerFunc{auth}
+	if len(roles) > 0 {
+		handlers = append(handlers, RequireRole(roles...))
+	}
+	handlers = append(handlers, func(c *gin.Context) {
+		c.String(http.StatusOK, "%s:%s", c.GetString("user_id"), c.GetString("user_role"))
+	})
+	router.GET("/", handlers...)
+	return router
+}
+
+func serve(router *gin.Engine, authHeader string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key any, claims utils.Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString error: %v", err)
+	}
+	return token
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	SetJWTSecret("secret")
+	t.Cleanup(func() { tokens = nil })
+
+	valid := func(role string) string {
+		token, err := utils.NewJWT("secret").GenerateToken("42", "user@example.com", role)
+		if err != nil {
+			t.Fatalf("GenerateToken error: %v", err)
+		}
+		return "Bearer " + token
+	}
+	expired := signToken(t, jwt.SigningMethodHS256, []byte("secret"), utils.Claims{
+		UserID: "42", Role: models.RoleAdmin,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))},
+	})
+	unsigned := signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, utils.Claims{UserID: "42", Role: models.RoleAdmin})
+	noRole := signToken(t, jwt.SigningMethodHS256, []byte("secret"), utils.Claims{UserID: "42"})
+	otherSecret, _ := utils.NewJWT("other").GenerateToken("42", "user@example.com", models.RoleAdmin)
+
+	tests := []struct {
+		name       string
+		header     string
+		roles      []string
+		wantStatus int
+		wantBody   string
+	}{
+		{"author token", valid(models.RoleAuthor), nil, http.StatusOK, "42:author"},
+		{"admin token", valid(models.RoleAdmin), nil, http.StatusOK, "42:admin"},
+		{"no header", "", nil, http.StatusUnauthorized, ""},
+		{"not bearer", "Basic " + otherSecret, nil, http.StatusUnauthorized, ""},
+		{"empty bearer", "Bearer ", nil, http.StatusUnauthorized, ""},
+		{"arbitrary token", "Bearer dummy-jwt-token", nil, http.StatusUnauthorized, ""},
+		{"other secret", "Bearer " + otherSecret, nil, http.StatusUnauthorized, ""},
+		{"expired", "Bearer " + expired, nil, http.StatusUnauthorized, ""},
+		{"alg none", "Bearer " + unsigned, nil, http.StatusUnauthorized, ""},
+		{"no role", "Bearer " + noRole, nil, http.StatusUnauthorized, ""},
+		// ロールはトークンに入れたものだけを使う
+		{"editor on staff route", valid(models.RoleEditor), []string{models.RoleAdmin, models.RoleEditor}, http.StatusOK, "42:editor"},
+		{"author on staff route", valid(models.RoleAuthor), []string{models.RoleAdmin, models.RoleEditor}, http.StatusForbidden, ""},
+		{"editor on admin route", valid(models.RoleEditor), []string{models.RoleAdmin}, http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(newTestRouter(AuthMiddleware(), tt.roles...), tt.header)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareWithoutSecret(t *testing.T) {
+	tokens = nil
+	token, _ := utils.NewJWT("").GenerateToken("42", "user@example.com", models.RoleAdmin)
+	if w := serve(newTestRouter(AuthMiddleware()), "Bearer "+token); w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	SetJWTSecret("secret")
+	t.Cleanup(func() { tokens = nil })
+	token, _ := utils.NewJWT("secret").GenerateToken("7", "editor@example.com", models.RoleEditor)
+	otherSecret, _ := utils.NewJWT("other").GenerateToken("7", "editor@example.com", models.RoleAdmin)
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"anonymous", "", ":"},
+		{"valid token", "Bearer " + token, "7:editor"},
+		// 不正なトークンは拒否せず未認証として扱う
+		{"invalid token", "Bearer dummy-jwt-token", ":"},
+		{"other secret", "Bearer " + otherSecret, ":"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(newTestRouter(OptionalAuth()), tt.header)
+			if w.Code != http.StatusOK || w.Body.String() != tt.want {
+				t.Errorf("got %d %q, want 200 %q", w.Code, w.Body.String(), tt.want)
+			}
+		})
+	}
+}
@@ -14,8 +14,14 @@ type Category struct {
 	Name        string `gorm:"uniqueIndex;not null" json:"name"`
 	Slug        string `gorm:"uniqueIndex;not null" json:"slug"`
 	Description string `json:"description"`
-	
-	Posts []Post `gorm:"many2many:post_categories;" json:"-"`
+
+	// 階層構造（親カテゴリーと兄弟間の並び順）
+	ParentID *uint      `gorm:"index" json:"parent_id"`
+	Position int        `gorm:"not null;default:0" json:"position"`
+	Parent   *Category  `gorm:"foreignKey:ParentID" json:"-"`
+	Children []Category `gorm:"foreignKey:ParentID" json:"-"`
+
+	Posts []Post `gorm:"foreignKey:CategoryID" json:"-"`
 }
 
 func (Category) TableName() string {
@@ -1,25 +1,150 @@
 package models
 
 import (
+	"blogapp/internal/fieldcrypt"
+	"blogapp/internal/spam"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
 	"time"
+
 	"gorm.io/gorm"
 )
 
+// コメントのモデレーション状態
+const (
+	CommentStatusPending  = "pending"
+	CommentStatusApproved = "approved"
+	CommentStatusSpam     = "spam"
+	CommentStatusTrash    = "trash"
+)
+
 type Comment struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	
-	PostID   uint   `gorm:"not null" json:"post_id"`
+
+	PostID   uint   `gorm:"not null;index" json:"post_id"`
 	Author   string `gorm:"not null" json:"author"`
-	Email    string `gorm:"not null" json:"email"`
+	Email    string `gorm:"type:text;not null;serializer:encrypted" json:"email,omitempty"` // 暗号化して保存、一般の閲覧者には返さない
 	Content  string `gorm:"type:text;not null" json:"content"`
-	Approved bool   `gorm:"default:false" json:"approved"`
-	
-	Post Post `gorm:"foreignKey:PostID" json:"post"`
+	Approved bool   `gorm:"default:false;index" json:"approved"` // Status == approved と同期
+
+	// メールアドレスの検索用ハッシュ（CommentEmailHash）と、レスポンス用のアバターのハッシュ
+	EmailHash string      `gorm:"size:64;index" json:"-"`
+	Avatar    *AvatarHash `gorm:"-" json:"avatar,omitempty"`
+
+	// モデレーション
+	Status string `gorm:"size:20;not null;default:pending;index" json:"status"`
+
+	// スパム判定（投稿時のルールごとのスコアと、モデレーターの判定で学習した結果）
+	IP         string       `gorm:"type:text;serializer:encrypted" json:"ip,omitempty"` // 暗号化して保存、保存期間を過ぎたら消す
+	UserAgent  string       `gorm:"size:255" json:"user_agent,omitempty"`
+	SpamScore  float64      `gorm:"not null;default:0" json:"spam_score"`
+	SpamReport *spam.Report `gorm:"type:jsonb;serializer:json" json:"spam_report,omitempty"`
+	TrainedAs  string       `gorm:"size:10" json:"-"` // spam / ham（学習済みでなければ空）
+
+	// 返信（トップレベルのコメントは ParentID が nil、Depth が 0）
+	ParentID *uint `gorm:"index" json:"parent_id"`
+	Depth    int   `gorm:"not null;default:0" json:"depth"`
+
+	// 返信のメール通知（NotifyReplies はコメントした人が選ぶ、ReplyNotifiedAt はこのコメントを親に通知した日時）
+	NotifyReplies   bool       `gorm:"not null;default:false" json:"notify_replies"`
+	ReplyNotifiedAt *time.Time `json:"-"`
+
+	Post  *Post         `gorm:"foreignKey:PostID" json:"post,omitempty"`
+	Notes []CommentNote `gorm:"constraint:OnDelete:CASCADE" json:"notes,omitempty"`
 }
 
 func (Comment) TableName() string {
 	return "comments"
-}
\ No newline at end of file
+}
+
+// BeforeSave - Approved フラグをステータスと同期（保存前フック）
+func (c *Comment) BeforeSave(tx *gorm.DB) error {
+	if c.Status == "" {
+		if c.Approved {
+			c.Status = CommentStatusApproved
+		} else {
+			c.Status = CommentStatusPending
+		}
+	}
+	c.Approved = c.Status == CommentStatusApproved
+	hash, err := CommentEmailHash(c.Email)
+	if err != nil {
+		return err
+	}
+	c.EmailHash = hash
+	c.Avatar = NewAvatarHash(c.Email)
+	return nil
+}
+
+// AfterFind - メールアドレスからアバターのハッシュを計算（検索後フック）
+func (c *Comment) AfterFind(tx *gorm.DB) error {
+	c.Avatar = NewAvatarHash(c.Email)
+	return nil
+}
+
+// normalizeEmail - ハッシュを計算する前にメールアドレスを正規化（前後の空白を除いて小文字に）
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// CommentEmailHash - 暗号化したメールアドレスを一致検索するためのハッシュ
+func CommentEmailHash(email string) (string, error) {
+	if email == "" {
+		return "", nil
+	}
+	return fieldcrypt.BlindIndex(normalizeEmail(email))
+}
+
+// AvatarHash - Gravatar 互換のアバター用ハッシュ（メールアドレスの代わりにレスポンスに含める）
+type AvatarHash struct {
+	MD5    string `json:"md5"`
+	SHA256 string `json:"sha256"`
+}
+
+// NewAvatarHash - メールアドレスからアバターのハッシュを計算（空なら nil）
+func NewAvatarHash(email string) *AvatarHash {
+	email = normalizeEmail(email)
+	if email == "" {
+		return nil
+	}
+	md5Sum := md5.Sum([]byte(email))
+	sha256Sum := sha256.Sum256([]byte(email))
+	return &AvatarHash{
+		MD5:    hex.EncodeToString(md5Sum[:]),
+		SHA256: hex.EncodeToString(sha256Sum[:]),
+	}
+}
+
+// IsValidCommentStatus - 定義済みのコメントのステータスか
+func IsValidCommentStatus(status string) bool {
+	switch status {
+	case CommentStatusPending, CommentStatusApproved, CommentStatusSpam, CommentStatusTrash:
+		return true
+	}
+	return false
+}
+
+// CommentStatuses - すべてのコメントのステータス（一覧の集計などで使う順）
+func CommentStatuses() []string {
+	return []string{CommentStatusPending, CommentStatusApproved, CommentStatusSpam, CommentStatusTrash}
+}
+
+// CommentNote - モデレーターがコメントに残すメモ（投稿者には表示しない）
+type CommentNote struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	CommentID uint   `gorm:"not null;index" json:"comment_id"`
+	AuthorID  uint   `gorm:"not null" json:"author_id"`
+	Author    *User  `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+	Body      string `gorm:"type:text;not null" json:"body"`
+}
+
+func (CommentNote) TableName() string {
+	return "comment_notes"
+}
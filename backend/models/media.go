@@ -0,0 +1,121 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Media - アップロードされたファイル（メディアライブラリ）
+type Media struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UploaderID uint  `gorm:"not null;index" json:"uploader_id"`
+	Uploader   *User `gorm:"foreignKey:UploaderID" json:"uploader,omitempty"`
+
+	// 保存先のキー（MediaKey の形式）と、クライアントが送ったファイル名
+	Key          string `gorm:"size:255;uniqueIndex;not null" json:"key"`
+	Filename     string `gorm:"size:255;not null" json:"filename"`
+	OriginalName string `gorm:"size:255" json:"original_name"`
+
+	MIME   string `gorm:"column:mime;size:100;not null;index" json:"mime"`
+	Size   int64  `gorm:"not null" json:"size"`
+	Width  int    `json:"width,omitempty"` // 画像のみ
+	Height int    `json:"height,omitempty"`
+	Hash   string `gorm:"size:64;not null;index" json:"hash"` // アップロードされた中身の SHA-256
+
+	AltText string `gorm:"size:500" json:"alt_text"`
+	Caption string `gorm:"type:text" json:"caption"`
+
+	// 画像から作った縮小画像（幅の小さい順）
+	Variants []MediaVariant `gorm:"constraint:OnDelete:CASCADE" json:"variants"`
+
+	// このファイルを使っている投稿（アイキャッチ画像または本文）
+	Posts []Post `gorm:"many2many:post_media;constraint:OnDelete:CASCADE" json:"posts,omitempty"`
+
+	URL    string `gorm:"-" json:"url"`              // 公開 URL（保存先の設定から作る）
+	Srcset string `gorm:"-" json:"srcset,omitempty"` // img 要素の srcset（切り抜いていない縮小画像と元の画像）
+}
+
+func (Media) TableName() string {
+	return "media"
+}
+
+// MediaVariant - 画像から作った縮小画像（サムネイルなど）
+type MediaVariant struct {
+	ID      uint   `gorm:"primarykey" json:"-"`
+	MediaID uint   `gorm:"not null;uniqueIndex:idx_media_variants_media_name" json:"-"`
+	Name    string `gorm:"size:50;not null;uniqueIndex:idx_media_variants_media_name" json:"name"`
+	Key     string `gorm:"size:255;uniqueIndex;not null" json:"key"`
+	MIME    string `gorm:"column:mime;size:100;not null" json:"mime"`
+	Size    int64  `gorm:"not null" json:"size"`
+	Width   int    `gorm:"not null" json:"width"`
+	Height  int    `gorm:"not null" json:"height"`
+	Cropped bool   `gorm:"not null" json:"cropped"` // 切り抜いたので元の画像と縦横比が違う
+
+	URL string `gorm:"-" json:"url"`
+}
+
+// TotalSize - 元のファイルと縮小画像の合計のバイト数（容量の計算に使う）
+func (m *Media) TotalSize() int64 {
+	size := m.Size
+	for _, v := range m.Variants {
+		size += v.Size
+	}
+	return size
+}
+
+// SetURLs - 元の画像と縮小画像の公開 URL、srcset をセット
+func (m *Media) SetURLs(url func(key string) string) {
+	m.URL = url(m.Key)
+	var srcset []string
+	for i := range m.Variants {
+		variant := &m.Variants[i]
+		variant.URL = url(variant.Key)
+		if !variant.Cropped {
+			srcset = append(srcset, fmt.Sprintf("%s %dw", variant.URL, variant.Width))
+		}
+	}
+	m.Srcset = ""
+	if len(srcset) > 0 && m.Width > 0 {
+		m.Srcset = strings.Join(append(srcset, fmt.Sprintf("%s %dw", m.URL, m.Width)), ", ")
+	}
+}
+
+// mediaKeyPattern - MediaKey・MediaVariantKey で作ったキー（URL の中から探すのに使う）
+var mediaKeyPattern = regexp.MustCompile(`\b\d{4}/\d{2}/[0-9a-f]{32}(?:-[a-z0-9_]+)?\.[a-z0-9]+\b`)
+
+// MediaKey - アップロードしたファイルの保存先のキー（年月ごとのディレクトリに分ける）
+func MediaKey(uploadedAt time.Time, filename string) string {
+	return path.Join(uploadedAt.Format("2006/01"), filename)
+}
+
+// IsMediaKey - MediaKey・MediaVariantKey で作ったキーか（中身のハッシュの名前なので、同じキーの中身は変わらない）
+func IsMediaKey(key string) bool {
+	loc := mediaKeyPattern.FindStringIndex(key)
+	return loc != nil && loc[0] == 0 && loc[1] == len(key)
+}
+
+// MediaVariantKey - 縮小画像の保存先のキー（元の画像のキーに名前を付け足す）
+func MediaVariantKey(key, name, ext string) string {
+	return strings.TrimSuffix(key, path.Ext(key)) + "-" + name + ext
+}
+
+// MediaKeysIn - アイキャッチ画像の URL や本文に含まれるメディアのキー（重複なし）
+func MediaKeysIn(texts ...string) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, text := range texts {
+		for _, key := range mediaKeyPattern.FindAllString(text, -1) {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// NotificationPreference - ユーザーごとのメール通知の設定（行がなければ既定値を使う）
+type NotificationPreference struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID       uint `gorm:"uniqueIndex;not null" json:"user_id"`
+	NewComments  bool `gorm:"not null" json:"new_comments"`  // 自分の投稿への新着コメント（承認待ち・自動承認）
+	SpamComments bool `gorm:"not null" json:"spam_comments"` // スパムと判定されたコメントも通知する
+}
+
+func (NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
+
+// DefaultNotificationPreference - 設定を保存していないユーザーの既定値
+// （false を保存できるよう、カラムには default を付けずここで既定値を決める）
+func DefaultNotificationPreference(userID uint) NotificationPreference {
+	return NotificationPreference{UserID: userID, NewComments: true}
+}
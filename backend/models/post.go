@@ -1,34 +1,213 @@
 package models
 
 import (
+	"blogapp/internal/markdown"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"time"
+
 	"gorm.io/gorm"
 )
 
+// 投稿ステータス
+const (
+	PostStatusDraft         = "draft"
+	PostStatusPendingReview = "pending_review"
+	PostStatusScheduled     = "scheduled"
+	PostStatusPublished     = "published"
+	PostStatusArchived      = "archived"
+)
+
 type Post struct {
 	ID         uint           `gorm:"primarykey" json:"id"`
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
 	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
-	
+
 	Title     string `gorm:"not null" json:"title"`
 	Slug      string `gorm:"uniqueIndex;not null" json:"slug"`
 	Content   string `gorm:"type:text" json:"content"`
 	Excerpt   string `json:"excerpt"`
 	ImageURL  string `json:"image_url"`
 	Published bool   `gorm:"default:false" json:"published"`
-	
+
+	// Markdown のレンダリング結果（保存時に生成してキャッシュ）
+	ContentHTML   string `gorm:"type:text" json:"content_html"`
+	TOC           TOC    `gorm:"type:jsonb" json:"toc"`
+	RenderVersion int    `gorm:"default:0" json:"-"`
+
+	// ContentHTML を生成したときの本文（読み込み時・レンダリング時に記録し、変更の検出に使う）
+	renderedContent string
+
+	// 公開ワークフロー
+	Status      string     `gorm:"size:20;not null;default:draft;index" json:"status"`
+	PublishedAt *time.Time `gorm:"index" json:"published_at"`
+
 	// 外部キー
-	AuthorID   uint `gorm:"not null" json:"author_id"`
-	CategoryID uint `json:"category_id"`
-	
+	AuthorID   uint  `gorm:"not null" json:"author_id"`
+	CategoryID *uint `gorm:"index" json:"category_id"` // nil なら未分類
+
 	// リレーション
 	Author   User     `gorm:"foreignKey:AuthorID" json:"author"`
-	Category Category `gorm:"foreignKey:CategoryID" json:"category"`
+	Category *Category `gorm:"foreignKey:CategoryID" json:"category"`
 	Tags     []Tag    `gorm:"many2many:post_tags;" json:"tags"`
 	Comments []Comment `gorm:"foreignKey:PostID" json:"comments"`
+	Media    []Media   `gorm:"many2many:post_media;" json:"-"` // アイキャッチ画像と本文で使っているメディア
 }
 
 func (Post) TableName() string {
 	return "posts"
-}
\ No newline at end of file
+}
+
+// AfterFind - 読み込んだ本文を記録（読み込み後フック）
+func (p *Post) AfterFind(tx *gorm.DB) error {
+	p.renderedContent = p.Content
+	return nil
+}
+
+// BeforeSave - Published フラグをステータスと同期し、本文が変わっていればレンダリング（保存前フック）
+func (p *Post) BeforeSave(tx *gorm.DB) error {
+	if p.NeedsRender() || p.Content != p.renderedContent {
+		if err := p.Render(); err != nil {
+			return err
+		}
+	}
+
+	if p.Status == "" {
+		if p.Published {
+			p.Status = PostStatusPublished
+		} else {
+			p.Status = PostStatusDraft
+		}
+	}
+	p.Published = p.Status == PostStatusPublished
+	if p.Published && p.PublishedAt == nil {
+		now := time.Now()
+		p.PublishedAt = &now
+	}
+	return nil
+}
+
+// BeforeUpdate - スラッグが変わった場合は旧スラッグを履歴に残す（更新前フック）
+func (p *Post) BeforeUpdate(tx *gorm.DB) error {
+	if p.ID == 0 || p.Slug == "" {
+		return nil
+	}
+
+	db := tx.Session(&gorm.Session{NewDB: true})
+	var oldSlugs []string
+	if err := db.Unscoped().Model(&Post{}).Where("id = ?", p.ID).Pluck("slug", &oldSlugs).Error; err != nil {
+		return err
+	}
+	if len(oldSlugs) == 0 {
+		return nil
+	}
+	return RecordSlugChange(db, SlugEntityPost, p.ID, oldSlugs[0], p.Slug)
+}
+
+// Render - Markdown の本文から HTML と目次を生成
+func (p *Post) Render() error {
+	result, err := markdown.Render(p.Content)
+	if err != nil {
+		return err
+	}
+	p.ContentHTML = result.HTML
+	p.TOC = result.TOC
+	p.RenderVersion = markdown.Version
+	p.renderedContent = p.Content
+	return nil
+}
+
+// NeedsRender - キャッシュ済み HTML が古いレンダラーで生成されているか
+func (p *Post) NeedsRender() bool {
+	return p.RenderVersion != markdown.Version
+}
+
+// TOC - 投稿の目次（jsonb として保存）
+type TOC []markdown.Heading
+
+func (t TOC) Value() (driver.Value, error) {
+	if t == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+func (t *TOC) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return errors.New("unsupported type for TOC")
+	}
+	return json.Unmarshal(b, t)
+}
+
+// ApplyStatus - ステータスを変更し、公開日時を整合させる
+// scheduled の場合 publishAt は必須（呼び出し側で検証する）
+func (p *Post) ApplyStatus(status string, publishAt *time.Time, now time.Time) {
+	switch status {
+	case PostStatusPublished:
+		if publishAt != nil {
+			p.PublishedAt = publishAt
+		} else if p.Status != PostStatusPublished || p.PublishedAt == nil {
+			p.PublishedAt = &now
+		}
+	case PostStatusScheduled:
+		p.PublishedAt = publishAt
+	case PostStatusArchived:
+		// 公開履歴として PublishedAt は残す
+	default:
+		p.PublishedAt = nil
+	}
+	p.Status = status
+	p.Published = status == PostStatusPublished
+}
+
+// IsValidPostStatus - 定義済みのステータスかどうか
+func IsValidPostStatus(status string) bool {
+	switch status {
+	case PostStatusDraft, PostStatusPendingReview, PostStatusScheduled,
+		PostStatusPublished, PostStatusArchived:
+		return true
+	}
+	return false
+}
+
+type postTransition struct {
+	from, to string
+}
+
+// postTransitionRoles - 許可されるステータス遷移と、その遷移を実行できるロール
+var postTransitionRoles = map[postTransition][]string{
+	{PostStatusDraft, PostStatusPendingReview}:     {RoleAuthor, RoleEditor, RoleAdmin},
+	{PostStatusPendingReview, PostStatusDraft}:     {RoleAuthor, RoleEditor, RoleAdmin},
+	{PostStatusDraft, PostStatusScheduled}:         {RoleEditor, RoleAdmin},
+	{PostStatusDraft, PostStatusPublished}:         {RoleEditor, RoleAdmin},
+	{PostStatusPendingReview, PostStatusScheduled}: {RoleEditor, RoleAdmin},
+	{PostStatusPendingReview, PostStatusPublished}: {RoleEditor, RoleAdmin},
+	{PostStatusScheduled, PostStatusScheduled}:     {RoleEditor, RoleAdmin},
+	{PostStatusScheduled, PostStatusDraft}:         {RoleEditor, RoleAdmin},
+	{PostStatusScheduled, PostStatusPublished}:     {RoleEditor, RoleAdmin},
+	{PostStatusPublished, PostStatusDraft}:         {RoleEditor, RoleAdmin},
+	{PostStatusPublished, PostStatusArchived}:      {RoleEditor, RoleAdmin},
+	{PostStatusArchived, PostStatusDraft}:          {RoleEditor, RoleAdmin},
+}
+
+// CanTransitionPost - 指定ロールが from から to へステータスを変更できるか
+func CanTransitionPost(from, to, role string) bool {
+	for _, r := range postTransitionRoles[postTransition{from, to}] {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// PostRevision - 投稿の更新履歴（更新ごとに本文のスナップショットを保存）
+type PostRevision struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	PostID  uint   `gorm:"not null;index" json:"post_id"`
+	Title   string `gorm:"not null" json:"title"`
+	Content string `gorm:"type:text" json:"content,omitempty"`
+	Excerpt string `json:"excerpt"`
+
+	// 編集者
+	EditorID uint `gorm:"not null" json:"editor_id"`
+	Editor   User `gorm:"foreignKey:EditorID" json:"editor"`
+}
+
+func (PostRevision) TableName() string {
+	return "post_revisions"
+}
+
+// NewPostRevision - 投稿の現在の内容からリビジョンを作成
+func NewPostRevision(post *Post, editorID uint) *PostRevision {
+	return &PostRevision{
+		PostID:   post.ID,
+		Title:    post.Title,
+		Content:  post.Content,
+		Excerpt:  post.Excerpt,
+		EditorID: editorID,
+	}
+}
+
+// SameContent - リビジョン対象のフィールドが同じかどうか
+func (r *PostRevision) SameContent(post *Post) bool {
+	return r.Title == post.Title && r.Content == post.Content && r.Excerpt == post.Excerpt
+}
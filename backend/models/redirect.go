@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// Redirect - 管理者が登録する任意パスのリダイレクト
+type Redirect struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	FromPath   string `gorm:"uniqueIndex;not null" json:"from_path"`
+	ToPath     string `gorm:"not null" json:"to_path"`
+	StatusCode int    `gorm:"not null;default:301" json:"status_code"`
+
+	// アクセス数
+	Hits      int64      `gorm:"not null;default:0" json:"hits"`
+	LastHitAt *time.Time `json:"last_hit_at"`
+}
+
+func (Redirect) TableName() string {
+	return "redirects"
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// 検査の結果（ScanLog.Result）
+const (
+	ScanResultClean    = "clean"
+	ScanResultInfected = "infected"
+	ScanResultError    = "error" // 検査できなかった
+)
+
+// 検査後の扱い（ScanLog.Action）
+const (
+	ScanActionAccepted    = "accepted"
+	ScanActionRejected    = "rejected"
+	ScanActionQuarantined = "quarantined"
+)
+
+// ScanLog - アップロードされたファイルのマルウェア検査の記録（検査ごとに1件）
+type ScanLog struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	UserID uint `gorm:"not null;index" json:"user_id"`
+
+	// 検査したファイル
+	Key      string `gorm:"size:255" json:"key"` // 保存する場合のキー
+	Filename string `gorm:"size:255" json:"filename"`
+	MIME     string `gorm:"column:mime;size:100" json:"mime"`
+	Size     int64  `json:"size"`
+	Hash     string `gorm:"size:64;index" json:"hash"`
+
+	Scanner   string `gorm:"size:50" json:"scanner"`
+	Result    string `gorm:"size:20;not null;index" json:"result"`
+	Signature string `gorm:"size:255" json:"signature,omitempty"` // 検出したマルウェアの名前
+	Error     string `gorm:"size:500" json:"error,omitempty"`
+	Action    string `gorm:"size:20;not null" json:"action"`
+
+	// 隔離したファイルのパス（QUARANTINE_DIR からの相対パス、削除したら空）
+	QuarantinePath string `gorm:"size:255" json:"quarantine_path,omitempty"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// スラッグ履歴の対象
+const (
+	SlugEntityPost = "post"
+	SlugEntityTag  = "tag"
+)
+
+// SlugHistory - 変更前のスラッグ（旧URLから現在のURLへリダイレクトするため）
+type SlugHistory struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	EntityType string `gorm:"size:20;not null;uniqueIndex:idx_slug_history_entity_slug" json:"entity_type"`
+	EntityID   uint   `gorm:"not null;index" json:"entity_id"`
+	Slug       string `gorm:"not null;uniqueIndex:idx_slug_history_entity_slug" json:"slug"`
+}
+
+func (SlugHistory) TableName() string {
+	return "slug_history"
+}
+
+// RecordSlugChange - 旧スラッグを履歴に追加する
+// 新しいスラッグが履歴に残っていれば（元に戻した場合など）その行は削除する
+func RecordSlugChange(tx *gorm.DB, entityType string, entityID uint, oldSlug, newSlug string) error {
+	if oldSlug == "" || oldSlug == newSlug {
+		return nil
+	}
+
+	if err := tx.Where("entity_type = ? AND slug = ?", entityType, newSlug).
+		Delete(&SlugHistory{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "entity_type"}, {Name: "slug"}},
+		DoUpdates: clause.AssignmentColumns([]string{"entity_id", "created_at"}),
+	}).Create(&SlugHistory{
+		EntityType: entityType,
+		EntityID:   entityID,
+		Slug:       oldSlug,
+	}).Error
+}
+
+// FindSlugHistory - 旧スラッグから現在のエンティティIDを探す
+func FindSlugHistory(db *gorm.DB, entityType, slug string) (uint, error) {
+	var history SlugHistory
+	if err := db.Where("entity_type = ? AND slug = ?", entityType, slug).
+		First(&history).Error; err != nil {
+		return 0, err
+	}
+	return history.EntityID, nil
+}
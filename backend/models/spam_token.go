@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SpamToken - コメントのスパム判定（単純ベイズ）の学習データ
+// トークンごとにスパム・非スパムとして学習したコメントに現れた回数を持つ
+type SpamToken struct {
+	Token     string    `gorm:"primaryKey;size:100" json:"token"`
+	Spam      int64     `gorm:"not null;default:0" json:"spam"`
+	Ham       int64     `gorm:"not null;default:0" json:"ham"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (SpamToken) TableName() string {
+	return "spam_tokens"
+}
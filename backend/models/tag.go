@@ -2,6 +2,7 @@ package models
 
 import (
 	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -10,13 +11,40 @@ type Tag struct {
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	
+
 	Name string `gorm:"uniqueIndex;not null" json:"name"`
 	Slug string `gorm:"uniqueIndex;not null" json:"slug"`
-	
+	// 比較用に正規化した名前（NormalizeTagName、保存時に設定）
+	NameKey string `gorm:"size:255;index" json:"-"`
+
+	Synonyms []TagSynonym `gorm:"constraint:OnDelete:CASCADE" json:"synonyms,omitempty"`
+
 	Posts []Post `gorm:"many2many:post_tags;" json:"-"`
 }
 
 func (Tag) TableName() string {
 	return "tags"
-}
\ No newline at end of file
+}
+
+// BeforeSave - 比較用の名前を設定（保存前フック）
+func (t *Tag) BeforeSave(tx *gorm.DB) error {
+	t.NameKey = NormalizeTagName(t.Name)
+	return nil
+}
+
+// BeforeUpdate - スラッグが変わった場合は旧スラッグを履歴に残す（更新前フック）
+func (t *Tag) BeforeUpdate(tx *gorm.DB) error {
+	if t.ID == 0 || t.Slug == "" {
+		return nil
+	}
+
+	db := tx.Session(&gorm.Session{NewDB: true})
+	var oldSlugs []string
+	if err := db.Unscoped().Model(&Tag{}).Where("id = ?", t.ID).Pluck("slug", &oldSlugs).Error; err != nil {
+		return err
+	}
+	if len(oldSlugs) == 0 {
+		return nil
+	}
+	return RecordSlugChange(db, SlugEntityTag, t.ID, oldSlugs[0], t.Slug)
+}
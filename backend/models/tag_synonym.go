@@ -0,0 +1,39 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// TagSynonym - タグの別名（"golang" → "Go" のように入力を正規のタグへ寄せる）
+type TagSynonym struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	TagID uint   `gorm:"not null;index" json:"tag_id"`
+	Name  string `gorm:"not null" json:"name"`
+	// 比較用に正規化した名前（NormalizeTagName）
+	Key string `gorm:"size:255;not null;uniqueIndex" json:"-"`
+}
+
+func (TagSynonym) TableName() string {
+	return "tag_synonyms"
+}
+
+// NewTagSynonym - 別名を作成（比較用のキーも設定する）
+func NewTagSynonym(tagID uint, name string) *TagSynonym {
+	name = strings.TrimSpace(name)
+	return &TagSynonym{
+		TagID: tagID,
+		Name:  name,
+		Key:   NormalizeTagName(name),
+	}
+}
+
+// NormalizeTagName - タグ名を比較用に正規化（全角・半角の統一、小文字化、空白の圧縮）
+func NormalizeTagName(name string) string {
+	name = norm.NFKC.String(name)
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// TusUpload - 再開可能なアップロード（tus）の途中経過
+// 受け取ったデータは TUS_UPLOAD_DIR の ID の名前のファイルに追記し、すべて受け取ったらメディアとして保存する
+type TusUpload struct {
+	ID        string    `gorm:"primaryKey;size:32" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID uint `gorm:"not null;index" json:"user_id"`
+
+	Length int64 `gorm:"not null" json:"length"`
+	Offset int64 `gorm:"column:upload_offset;not null" json:"offset"` // 受け取ったバイト数
+
+	// Upload-Metadata で送られた情報
+	Filename string `gorm:"size:255;not null" json:"filename"`
+	AltText  string `gorm:"size:500" json:"alt_text"`
+	Caption  string `gorm:"type:text" json:"caption"`
+
+	// 完了したら作成したメディア、検査で拒否したら理由
+	MediaID *uint  `gorm:"index" json:"media_id,omitempty"`
+	Media   *Media `gorm:"constraint:OnDelete:SET NULL" json:"media,omitempty"`
+	Error   string `gorm:"size:255" json:"error,omitempty"`
+
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"` // これを過ぎても完了しなければ削除する
+}
+
+// Completed - すべて受け取ってメディアを作成したか
+func (u *TusUpload) Completed() bool {
+	return u.MediaID != nil
+}
@@ -7,6 +7,18 @@ import (
 	"gorm.io/gorm"
 )
 
+// ロール（認証ミドルウェアが user_role としてコンテキストにセットする値）
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleAuthor = "author"
+)
+
+// IsStaffRole - 他人の投稿を編集・公開できるロールかどうか
+func IsStaffRole(role string) bool {
+	return role == RoleAdmin || role == RoleEditor
+}
+
 type User struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -24,20 +36,36 @@ type User struct {
 	Avatar      string `json:"avatar"`
 
 	// 権限
-	IsAdmin    bool `gorm:"default:false" json:"is_admin"`
-	IsVerified bool `gorm:"default:false" json:"is_verified"`
+	Role       string `gorm:"size:20;index" json:"role"` // RoleAdmin / RoleEditor / RoleAuthor（空なら IsAdmin から決める）
+	IsAdmin    bool   `gorm:"default:false" json:"is_admin"`
+	IsVerified bool   `gorm:"default:false" json:"is_verified"`
 	
 	// パスワードリセット用
 	ResetPasswordToken   string    `gorm:"index" json:"-"`
 	ResetPasswordExpires time.Time `json:"-"`
 	PasswordChangedAt    time.Time `json:"password_changed_at"`	
 
+	// アップロードの容量の上限（バイト数、nil ならロールの既定値、0 なら無制限）
+	StorageQuota *int64 `json:"storage_quota"`
+
 	// リレーション
 	Posts []Post `gorm:"foreignKey:AuthorID" json:"-"`
 }
 
-// BeforeCreate - パスワードのハッシュ化（作成前フック）
+// EffectiveRole - ユーザーのロール（Role が未設定なら is_admin から管理者か投稿者とする）
+func (u *User) EffectiveRole() string {
+	switch {
+	case u.Role != "":
+		return u.Role
+	case u.IsAdmin:
+		return RoleAdmin
+	}
+	return RoleAuthor
+}
+
+// BeforeCreate - パスワードのハッシュ化とロールの設定（作成前フック）
 func (u *User) BeforeCreate(tx *gorm.DB) error {
+	u.Role = u.EffectiveRole()
 	if u.Password != "" {
 		hashedPassword, err := bcrypt.GenerateFromPassword(
 			[]byte(u.Password),
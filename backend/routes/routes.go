@@ -3,6 +3,7 @@ package routes
 import (
 	"blogapp/handlers"
 	"blogapp/middleware"
+	"blogapp/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,8 +12,34 @@ func SetupRoutes(router *gin.Engine) {
 	// CORS middleware
 	router.Use(middleware.CORSMiddleware())
 
+	// 画像の変換（署名付き URL）
+	router.GET("/img/:sig/:params/*path", handlers.ServeImage)
+	router.HEAD("/img/:sig/:params/*path", handlers.ServeImage)
+
+	// アップロードしたファイル（公開していない投稿のメディアは署名付き URL のみ）
+	router.GET("/uploads/*path", handlers.ServeUpload)
+	router.HEAD("/uploads/*path", handlers.ServeUpload)
+
+	// フィード（RSS / Atom / JSON Feed、カテゴリー・タグ・著者ごと）
+	router.GET("/feed.xml", handlers.RSSFeed)
+	router.GET("/atom.xml", handlers.AtomFeed)
+	router.GET("/feed.json", handlers.JSONFeed)
+	router.GET("/categories/:category/feed.xml", handlers.RSSFeed)
+	router.GET("/categories/:category/atom.xml", handlers.AtomFeed)
+	router.GET("/categories/:category/feed.json", handlers.JSONFeed)
+	router.GET("/tags/:tag/feed.xml", handlers.RSSFeed)
+	router.GET("/tags/:tag/atom.xml", handlers.AtomFeed)
+	router.GET("/tags/:tag/feed.json", handlers.JSONFeed)
+	router.GET("/authors/:author/feed.xml", handlers.RSSFeed)
+	router.GET("/authors/:author/atom.xml", handlers.AtomFeed)
+	router.GET("/authors/:author/feed.json", handlers.JSONFeed)
+
+	// tus の対応状況の確認（認証不要）
+	router.OPTIONS("/api/uploads", handlers.TusOptions)
+
 	// Public routes
 	api := router.Group("/api")
+	api.Use(middleware.OptionalAuth())
 	{
 		// Auth routes
 		api.POST("/auth/register", handlers.Register)
@@ -23,16 +50,32 @@ func SetupRoutes(router *gin.Engine) {
 		api.GET("/posts/:id", handlers.GetPost)
 		api.GET("/posts/slug/:slug", handlers.GetPostBySlug)
 
+		// Markdown
+		api.GET("/markdown/highlight.css", handlers.GetHighlightCSS)
+
 		// Categories
 		api.GET("/categories", handlers.GetCategories)
+		api.GET("/categories/tree", handlers.GetCategoryTree)
 		api.GET("/categories/:id", handlers.GetCategory)
+		// :id はスラッグまたはID（gin では同じ位置のパラメーターは同じ名前にする必要があるため :slug にはしない）
+		api.GET("/categories/:id/posts", handlers.GetCategoryPosts)
 
 		// Tags
 		api.GET("/tags", handlers.GetTags)
 		api.GET("/tags/:id", handlers.GetTag)
+		api.GET("/tags/:id/posts", handlers.GetTagPosts) // :id はスラッグまたはID
 
 		// Comments
 		api.GET("/posts/:id/comments", handlers.GetComments)
+		api.POST("/posts/:id/comments", handlers.CreateComment)
+		api.GET("/posts/:id/comments/token", handlers.GetCommentFormToken)
+
+		// メール通知の配信停止（署名付きトークン）
+		api.GET("/unsubscribe", handlers.ShowUnsubscribe)
+		api.POST("/unsubscribe", handlers.Unsubscribe)
+
+		// Redirects
+		api.GET("/redirects/resolve", handlers.ResolveRedirect)
 	}
 
 	// Protected routes
@@ -43,23 +86,98 @@ func SetupRoutes(router *gin.Engine) {
 		protected.POST("/posts", handlers.CreatePost)
 		protected.PUT("/posts/:id", handlers.UpdatePost)
 		protected.DELETE("/posts/:id", handlers.DeletePost)
+		protected.PUT("/posts/:id/status", handlers.ChangePostStatus)
 
-		// Categories
-		protected.POST("/categories", handlers.CreateCategory)
-		protected.PUT("/categories/:id", handlers.UpdateCategory)
-		protected.DELETE("/categories/:id", handlers.DeleteCategory)
+		protected.POST("/markdown/preview", handlers.PreviewMarkdown)
+
+		// Post revisions
+		protected.GET("/posts/:id/revisions", handlers.ListPostRevisions)
+		protected.GET("/posts/:id/revisions/diff", handlers.DiffPostRevisions)
+		protected.GET("/posts/:id/revisions/:revisionId", handlers.GetPostRevision)
+		protected.POST("/posts/:id/revisions/:revisionId/restore", handlers.RestorePostRevision)
 
 		// Tags
 		protected.POST("/tags", handlers.CreateTag)
 		protected.PUT("/tags/:id", handlers.UpdateTag)
 		protected.DELETE("/tags/:id", handlers.DeleteTag)
-
-		// Comments
-		protected.POST("/posts/:id/comments", handlers.CreateComment)
-		protected.PUT("/comments/:id", handlers.UpdateComment)
-		protected.DELETE("/comments/:id", handlers.DeleteComment)
+		protected.GET("/tags/autocomplete", handlers.AutocompleteTags)
+		protected.POST("/tags/suggest", handlers.SuggestTags)
 
 		// Upload
 		protected.POST("/upload", handlers.UploadFile)
+
+		// メディアライブラリ
+		protected.GET("/media", handlers.ListMedia)
+		protected.GET("/media/:id", handlers.GetMedia)
+		protected.PUT("/media/:id", handlers.UpdateMedia)
+		protected.DELETE("/media/:id", handlers.DeleteMedia)
+		protected.POST("/media/:id/signed-url", handlers.SignMediaURL)
+
+		// 再開可能なアップロード（tus 1.0）
+		protected.POST("/uploads", handlers.CreateTusUpload)
+		protected.GET("/uploads/:id", handlers.GetTusUpload)
+		protected.HEAD("/uploads/:id", handlers.HeadTusUpload)
+		protected.PATCH("/uploads/:id", handlers.PatchTusUpload)
+		protected.DELETE("/uploads/:id", handlers.DeleteTusUpload)
+
+		// 画像の変換 URL の署名
+		protected.POST("/images/sign", handlers.SignImageURL)
+
+		// メール通知の設定
+		protected.GET("/me/notifications", handlers.GetNotificationPreferences)
+		protected.PUT("/me/notifications", handlers.UpdateNotificationPreferences)
+
+		// 自分の使用容量
+		protected.GET("/me/storage", handlers.GetMyStorage)
+	}
+
+	// Staff routes (editor / admin)
+	staff := protected.Group("/")
+	staff.Use(middleware.RequireRole(models.RoleAdmin, models.RoleEditor))
+	{
+		// Categories
+		staff.POST("/categories", handlers.CreateCategory)
+		staff.PUT("/categories/reorder", handlers.ReorderCategories)
+		staff.PUT("/categories/:id", handlers.UpdateCategory)
+		staff.DELETE("/categories/:id", handlers.DeleteCategory)
+
+		// Redirects
+		staff.GET("/redirects", handlers.GetRedirects)
+		staff.POST("/redirects", handlers.CreateRedirect)
+		staff.PUT("/redirects/:id", handlers.UpdateRedirect)
+		staff.DELETE("/redirects/:id", handlers.DeleteRedirect)
+
+		// Tag administration
+		staff.GET("/tags/unused", handlers.GetUnusedTags)
+		staff.POST("/tags/unused/delete", handlers.DeleteUnusedTags)
+		staff.POST("/tags/:id/merge", handlers.MergeTags)
+		staff.POST("/tags/:id/synonyms", handlers.AddTagSynonym)
+		staff.DELETE("/tags/:id/synonyms/:synonymId", handlers.DeleteTagSynonym)
+
+		// Comment moderation
+		staff.GET("/comments", handlers.GetModerationQueue)
+		staff.POST("/comments/bulk", handlers.BulkModerateComments)
+		staff.GET("/comments/:id", handlers.GetComment)
+		staff.PUT("/comments/:id", handlers.UpdateComment)
+		staff.PUT("/comments/:id/approve", handlers.ApproveComment)
+		staff.PUT("/comments/:id/status", handlers.ChangeCommentStatus)
+		staff.POST("/comments/:id/notes", handlers.AddCommentNote)
+		staff.DELETE("/comments/:id", handlers.DeleteComment)
 	}
+
+	// Admin routes (admin only)
+	admin := protected.Group("/admin")
+	admin.Use(middleware.RequireRole(models.RoleAdmin))
+	{
+		// 使用容量
+		admin.GET("/storage", handlers.GetStorageReport)
+		admin.PUT("/users/:id/storage-quota", handlers.UpdateUserStorageQuota)
+
+		// マルウェア検査の記録
+		admin.GET("/scans", handlers.GetScanLogs)
+		admin.DELETE("/scans/:id/quarantine", handlers.DeleteQuarantinedFile)
+	}
+
+	// 未定義のパスは管理画面で登録したリダイレクトを適用
+	router.NoRoute(handlers.RedirectFallback)
 }
@@ -2,8 +2,10 @@ package utils
 
 import (
 	"fmt"
+	"mime"
 	"net/smtp"
 	"os"
+	"strings"
 )
 
 // EmailConfig - メール設定
@@ -212,6 +214,49 @@ func SendWelcomeEmail(toEmail, username string) error {
 	)
 }
 
+// SendEmail - HTML メールを送信（headers で List-Unsubscribe などのヘッダーを追加できる）
+func SendEmail(toEmail, subject, body string, headers map[string]string) error {
+	config := GetEmailConfig()
+
+	if config.SMTPHost == "" || config.SMTPPort == "" {
+		return fmt.Errorf("SMTP設定が不完全です")
+	}
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s <%s>\r\n", mime.QEncoding.Encode("UTF-8", config.FromName), config.FromEmail)
+	fmt.Fprintf(&message, "To: %s\r\n", toEmail)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	for key, value := range headers {
+		// ヘッダーインジェクションを防ぐため改行を含む値は送らない
+		if strings.ContainsAny(key+value, "\r\n") {
+			return fmt.Errorf("不正なメールヘッダー: %s", key)
+		}
+		fmt.Fprintf(&message, "%s: %s\r\n", key, value)
+	}
+	message.WriteString("\r\n")
+	message.WriteString(body)
+
+	auth := smtp.PlainAuth(
+		"",
+		config.SMTPUsername,
+		config.SMTPPassword,
+		config.SMTPHost,
+	)
+
+	if err := smtp.SendMail(
+		config.SMTPHost+":"+config.SMTPPort,
+		auth,
+		config.FromEmail,
+		[]string{toEmail},
+		[]byte(message.String()),
+	); err != nil {
+		return fmt.Errorf("メール送信失敗: %v", err)
+	}
+	return nil
+}
+
 /*
 使用例:
 